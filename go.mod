@@ -7,3 +7,5 @@ toolchain go1.24.2
 require github.com/matryer/is v1.4.1
 
 require github.com/CarlLindqvist/xmltokenizer v0.0.10
+
+require golang.org/x/sync v0.16.0
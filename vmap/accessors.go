@@ -0,0 +1,827 @@
+package vmap
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ClickThroughURL returns the URL of the first linear ClickThrough in the ad,
+// or an empty string if there is none.
+func (a *Ad) ClickThroughURL() string {
+	if a.InLine == nil {
+		return ""
+	}
+	for i := range a.InLine.Creatives {
+		l := a.InLine.Creatives[i].Linear
+		if l != nil && l.ClickThrough != nil {
+			return l.ClickThrough.Text
+		}
+	}
+	return ""
+}
+
+// ClickTrackingURLs returns the URLs of all ClickTracking and CustomClick
+// elements of the ad's linear creatives, in document order.
+func (a *Ad) ClickTrackingURLs() []string {
+	if a.InLine == nil {
+		return nil
+	}
+	var urls []string
+	for i := range a.InLine.Creatives {
+		l := a.InLine.Creatives[i].Linear
+		if l == nil {
+			continue
+		}
+		for j := range l.ClickTracking {
+			urls = append(urls, l.ClickTracking[j].Text)
+		}
+		for j := range l.CustomClick {
+			urls = append(urls, l.CustomClick[j].Text)
+		}
+	}
+	return urls
+}
+
+// HasSeat reports whether the ad identifies the DSP seat that bought the impression.
+func (a *Ad) HasSeat() bool {
+	return a.SeatId != ""
+}
+
+// IsAudio reports whether the ad is an audio ad.
+func (a *Ad) IsAudio() bool {
+	return a.AdType == AdTypeAudio
+}
+
+// IsVideo reports whether the ad is a video ad, which is the default when
+// adType is not given.
+func (a *Ad) IsVideo() bool {
+	return a.AdType == "" || a.AdType == AdTypeVideo
+}
+
+// IsHybrid reports whether the ad is a hybrid ad, playable as audio or video.
+func (a *Ad) IsHybrid() bool {
+	return a.AdType == AdTypeHybrid
+}
+
+// IsConditional reports whether the ad is marked with conditionalAd. An ad
+// without the attribute is not conditional.
+func (a *Ad) IsConditional() bool {
+	return a.ConditionalAd != nil && *a.ConditionalAd
+}
+
+// HasCategory reports whether the ad is classified with the category value
+// from the given authority. It is safe to call on a nil InLine.
+func (il *InLine) HasCategory(authority, value string) bool {
+	if il == nil {
+		return false
+	}
+	for i := range il.Categories {
+		if il.Categories[i].Authority == authority && il.Categories[i].Value == value {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAdServingId reports whether the ad carries the AdServingId that VAST 4.1
+// requires for deduplication. It is safe to call on a nil InLine.
+func (il *InLine) HasAdServingId() bool {
+	return il != nil && il.AdServingId != ""
+}
+
+// NewAdServingId returns a random version 4 UUID for use as the AdServingId
+// of a generated ad.
+func NewAdServingId() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// HasAdvertiser reports whether the ad names its advertiser. It is safe to
+// call on a nil InLine.
+func (il *InLine) HasAdvertiser() bool {
+	return il != nil && il.Advertiser.String() != ""
+}
+
+// HasDescription reports whether the ad has a description. It is safe to
+// call on a nil InLine.
+func (il *InLine) HasDescription() bool {
+	return il != nil && il.Description != ""
+}
+
+// GetUniversalAdId returns the UniversalAdId from the given registry, e.g.
+// "ad-id.org", or nil. The result points into c.UniversalAdIds.
+func (c *Creative) GetUniversalAdId(registry string) *UniversalAdId {
+	for i := range c.UniversalAdIds {
+		if c.UniversalAdIds[i].IdRegistry == registry {
+			return &c.UniversalAdIds[i]
+		}
+	}
+	return nil
+}
+
+// UniversalAdID returns the id from the given registry, or an empty string.
+func (c *Creative) UniversalAdID(registry string) string {
+	if uaid := c.GetUniversalAdId(registry); uaid != nil {
+		return uaid.Id
+	}
+	return ""
+}
+
+// UniversalIdFor returns the id from the given registry and whether the
+// creative has a UniversalAdId from it, which may have an empty id.
+func (c *Creative) UniversalIdFor(registry string) (string, bool) {
+	if uaid := c.GetUniversalAdId(registry); uaid != nil {
+		return uaid.Id, true
+	}
+	return "", false
+}
+
+// mediaTypes maps the file extensions of common ad media to their MIME type.
+var mediaTypes = map[string]string{
+	".mp4":  "video/mp4",
+	".m4v":  "video/mp4",
+	".webm": "video/webm",
+	".mov":  "video/quicktime",
+	".3gp":  "video/3gpp",
+	".ogv":  "video/ogg",
+	".m3u8": "application/x-mpegURL",
+	".mpd":  "application/dash+xml",
+	".mp3":  "audio/mpeg",
+	".m4a":  "audio/mp4",
+	".aac":  "audio/aac",
+	".ogg":  "audio/ogg",
+}
+
+// MimeType returns the MIME type of the media file: its type attribute, or
+// else the type inferred from the extension of the URL path, e.g.
+// "application/x-mpegURL" for ".m3u8". It returns an empty string if the
+// type is unknown.
+func (m *MediaFile) MimeType() string {
+	if m.MediaType != "" {
+		return m.MediaType
+	}
+	p := m.Text
+	if u, err := url.Parse(m.Text); err == nil {
+		p = u.Path
+	}
+	return mediaTypes[strings.ToLower(path.Ext(p))]
+}
+
+// IsStreaming reports whether the media file is streamed: its delivery is
+// "streaming", or it is an HLS or DASH manifest.
+func (m *MediaFile) IsStreaming() bool {
+	if m.Delivery == DeliveryStreaming {
+		return true
+	}
+	switch strings.ToLower(m.MimeType()) {
+	case "application/x-mpegurl", "application/vnd.apple.mpegurl", "application/dash+xml":
+		return true
+	}
+	return false
+}
+
+// BitrateRange returns the range of bitrates, in kbps, of the media file.
+// That is Bitrate at both ends when it is set, and otherwise MinBitrate and
+// MaxBitrate, either of which stands in for the other if it is missing.
+func (m *MediaFile) BitrateRange() (lo, hi int) {
+	if m.Bitrate > 0 {
+		return m.Bitrate, m.Bitrate
+	}
+	lo, hi = m.MinBitrate, m.MaxBitrate
+	if lo <= 0 {
+		lo = hi
+	}
+	if hi <= 0 {
+		hi = lo
+	}
+	return lo, hi
+}
+
+// SelectMediaFile returns the media file with the highest bitrate among those
+// delivered as delivery, or nil if there is none. A media file without a
+// bitrate is ranked by the top of its BitrateRange. Of media files with the
+// same bitrate the first is returned. The result points into l.MediaFiles.
+// It is safe to call on a nil Linear.
+func (l *Linear) SelectMediaFile(delivery Delivery) *MediaFile {
+	if l == nil {
+		return nil
+	}
+	var best *MediaFile
+	bestRate := 0
+	for i := range l.MediaFiles {
+		m := &l.MediaFiles[i]
+		if m.Delivery != delivery {
+			continue
+		}
+		if _, hi := m.BitrateRange(); best == nil || hi > bestRate {
+			best, bestRate = m, hi
+		}
+	}
+	return best
+}
+
+// SelectAudioFile returns the audio media file, by MIME type, with the
+// highest bitrate not above maxBitrate, in kbps, or with the highest bitrate
+// if maxBitrate is not positive. A media file without a bitrate is taken at
+// the highest bitrate of its BitrateRange that is not above maxBitrate.
+// Dimensions are ignored. If every audio file exceeds maxBitrate, the one
+// with the lowest bitrate is returned. Of files with the same bitrate the
+// first is returned. It returns nil if there are no audio files. It is safe
+// to call on a nil Linear.
+func (l *Linear) SelectAudioFile(maxBitrate int) *MediaFile {
+	if l == nil {
+		return nil
+	}
+	var best, lowest *MediaFile
+	bestRate, lowestRate := 0, 0
+	for i := range l.MediaFiles {
+		m := &l.MediaFiles[i]
+		if !strings.HasPrefix(strings.ToLower(m.MimeType()), "audio/") {
+			continue
+		}
+		lo, hi := m.BitrateRange()
+		if lowest == nil || lo < lowestRate {
+			lowest, lowestRate = m, lo
+		}
+		if maxBitrate > 0 {
+			if lo > maxBitrate {
+				continue
+			}
+			hi = min(hi, maxBitrate)
+		}
+		if best == nil || hi > bestRate {
+			best, bestRate = m, hi
+		}
+	}
+	if best == nil {
+		return lowest
+	}
+	return best
+}
+
+// SelectInteractiveCreativeFile returns the first interactive creative file
+// with the given apiFramework, e.g. APIFrameworkSIMID, compared without
+// regard to case, or the first file of any framework if apiFramework is
+// empty. It returns nil if there is none. The result points into
+// l.InteractiveCreativeFiles, which SelectMediaFile never looks at. It is
+// safe to call on a nil Linear.
+func (l *Linear) SelectInteractiveCreativeFile(apiFramework string) *InteractiveCreativeFile {
+	if l == nil {
+		return nil
+	}
+	for i := range l.InteractiveCreativeFiles {
+		f := &l.InteractiveCreativeFiles[i]
+		if apiFramework == "" || strings.EqualFold(f.ApiFramework, apiFramework) {
+			return f
+		}
+	}
+	return nil
+}
+
+// GetExtensionByType returns the first extension of the given type, or nil.
+// The result points into il.Extensions.
+func (il *InLine) GetExtensionByType(extType string) *Extension {
+	for i := range il.Extensions {
+		if il.Extensions[i].ExtensionType == extType {
+			return &il.Extensions[i]
+		}
+	}
+	return nil
+}
+
+// GetCreativeParameter returns the first creative parameter with the given
+// creative id and name, or nil. The result points into e.CreativeParameters.
+func (e *Extension) GetCreativeParameter(creativeId, name string) *CreativeParameter {
+	for i := range e.CreativeParameters {
+		p := &e.CreativeParameters[i]
+		if p.CreativeId == creativeId && p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// GetCreativeParametersByCreativeId returns the creative parameters that
+// apply to the creative with the given id.
+func (e *Extension) GetCreativeParametersByCreativeId(id string) []CreativeParameter {
+	var params []CreativeParameter
+	for _, p := range e.CreativeParameters {
+		if p.CreativeId == id {
+			params = append(params, p)
+		}
+	}
+	return params
+}
+
+// CreativeParameter returns the value of the named creative parameter of the
+// creative with the given id, searching the CreativeParameters of every
+// extension in document order. The second result reports whether it was
+// found.
+func (il *InLine) CreativeParameter(creativeId, name string) (string, bool) {
+	for i := range il.Extensions {
+		if p := il.Extensions[i].GetCreativeParameter(creativeId, name); p != nil {
+			return p.Value, true
+		}
+	}
+	return "", false
+}
+
+// CreativeParametersFor returns the creative parameters of the creative with
+// the given id, from every extension, as a map from name to value. If a name
+// appears more than once the first value is kept, as with CreativeParameter.
+// It returns nil if the creative has no parameters.
+func (il *InLine) CreativeParametersFor(creativeId string) map[string]string {
+	var params map[string]string
+	for _, ext := range il.Extensions {
+		for _, p := range ext.CreativeParameters {
+			if p.CreativeId != creativeId {
+				continue
+			}
+			if params == nil {
+				params = make(map[string]string)
+			}
+			if _, ok := params[p.Name]; !ok {
+				params[p.Name] = p.Value
+			}
+		}
+	}
+	return params
+}
+
+// HasBreakType reports whether the break accepts ads of type t. The breakType
+// attribute may list several types, e.g. "linear,nonlinear".
+func (ab *AdBreak) HasBreakType(t BreakType) bool {
+	return slices.Contains(ab.BreakType.Split(), t)
+}
+
+// IsLinearBreak reports whether the break accepts linear ads.
+func (ab *AdBreak) IsLinearBreak() bool {
+	return ab.HasBreakType(BreakTypeLinear)
+}
+
+// IsNonLinearBreak reports whether the break accepts nonlinear ads.
+func (ab *AdBreak) IsNonLinearBreak() bool {
+	return ab.HasBreakType(BreakTypeNonLinear)
+}
+
+// IsDisplayBreak reports whether the break accepts display ads.
+func (ab *AdBreak) IsDisplayBreak() bool {
+	return ab.HasBreakType(BreakTypeDisplay)
+}
+
+// AllTrackingURLs returns the URLs of the break's tracking events keyed by
+// event name, in document order.
+func (ab *AdBreak) AllTrackingURLs() map[string][]string {
+	return trackingURLs(ab.TrackingEvents)
+}
+
+// AllTrackingURLs returns the URLs of the creative's tracking events keyed by
+// event name, in document order. Progress events at different offsets share
+// the "progress" key.
+func (l *Linear) AllTrackingURLs() map[string][]string {
+	return trackingURLs(l.TrackingEvents)
+}
+
+// Verifications returns the Open Measurement verifications of the ad: those
+// of its InLine or Wrapper, followed by those of the wrappers in its
+// WrapperChain, outermost first. VAST 4.1 asks players to load the
+// verifications found along the whole wrapper chain.
+func (a *Ad) Verifications() []Verification {
+	var vs []Verification
+	switch {
+	case a.InLine != nil:
+		vs = append(vs, a.InLine.AdVerifications...)
+	case a.Wrapper != nil:
+		vs = append(vs, a.Wrapper.AdVerifications...)
+	}
+	for i := range a.WrapperChain {
+		vs = append(vs, a.WrapperChain[i].AdVerifications...)
+	}
+	return vs
+}
+
+// AllTrackingURLs returns the tracking URLs of the ad in document order,
+// keyed as follows: "impression" for its impressions; "error" for its Error
+// URLs; "viewable", "notViewable" and "viewUndetermined" for its
+// ViewableImpression; the event name for the tracking events of its
+// verifications and linear creatives; "clickTracking" and "customClick" for
+// their video click tracking; and "iconClickTracking" and
+// "iconViewTracking" for the tracking of their icons. The keys are the event
+// types given to RewriteTrackingURLs.
+func (il *InLine) AllTrackingURLs() map[string][]string {
+	urls := make(map[string][]string)
+	for _, imp := range il.Impression {
+		urls["impression"] = append(urls["impression"], imp.Text)
+	}
+	for _, e := range il.Error {
+		urls["error"] = append(urls["error"], e.Value)
+	}
+	if vi := il.ViewableImpression; vi != nil {
+		if len(vi.Viewable) > 0 {
+			urls["viewable"] = slices.Clone(vi.Viewable)
+		}
+		if len(vi.NotViewable) > 0 {
+			urls["notViewable"] = slices.Clone(vi.NotViewable)
+		}
+		if len(vi.ViewUndetermined) > 0 {
+			urls["viewUndetermined"] = slices.Clone(vi.ViewUndetermined)
+		}
+	}
+	for i := range il.AdVerifications {
+		for _, t := range il.AdVerifications[i].TrackingEvents {
+			urls[t.Event] = append(urls[t.Event], t.Text)
+		}
+	}
+	for i := range il.Creatives {
+		if l := il.Creatives[i].Linear; l != nil {
+			for _, t := range l.TrackingEvents {
+				urls[t.Event] = append(urls[t.Event], t.Text)
+			}
+			for _, ct := range l.ClickTracking {
+				urls["clickTracking"] = append(urls["clickTracking"], ct.Text)
+			}
+			for _, cc := range l.CustomClick {
+				urls["customClick"] = append(urls["customClick"], cc.Text)
+			}
+			for j := range l.Icons {
+				ic := &l.Icons[j]
+				if ic.IconClicks != nil && len(ic.IconClicks.IconClickTracking) > 0 {
+					urls["iconClickTracking"] = append(urls["iconClickTracking"], ic.IconClicks.IconClickTracking...)
+				}
+				if len(ic.IconViewTracking) > 0 {
+					urls["iconViewTracking"] = append(urls["iconViewTracking"], ic.IconViewTracking...)
+				}
+			}
+		}
+	}
+	return urls
+}
+
+func trackingURLs(events []TrackingEvent) map[string][]string {
+	urls := make(map[string][]string)
+	for _, t := range events {
+		urls[t.Event] = append(urls[t.Event], t.Text)
+	}
+	return urls
+}
+
+// IsRepeating reports whether the break repeats at the interval given by RepeatAfter.
+func (ab *AdBreak) IsRepeating() bool {
+	return ab.RepeatAfter != nil && ab.RepeatAfter.Duration > 0
+}
+
+// NextBreakTime returns the first occurrence of the break after current.
+// Occurrences are TimeOffset and every RepeatAfter interval after it. If the
+// break does not repeat, or either offset is not a duration or the start of
+// the content, the break's TimeOffset is returned.
+func (ab *AdBreak) NextBreakTime(current TimeOffset) TimeOffset {
+	first, ok := ab.TimeOffset.contentTime()
+	if !ok || !ab.IsRepeating() {
+		return ab.TimeOffset
+	}
+	now, ok := current.contentTime()
+	if !ok || now < first {
+		return ab.TimeOffset
+	}
+	interval := ab.RepeatAfter.Duration
+	next := Duration{first + ((now-first)/interval+1)*interval}
+	return TimeOffset{Duration: &next}
+}
+
+// contentTime returns the offset into the content for a duration or start offset.
+func (to TimeOffset) contentTime() (time.Duration, bool) {
+	switch {
+	case to.Duration != nil:
+		return to.Duration.Duration, true
+	case to.Position == OffsetStart:
+		return 0, true
+	}
+	return 0, false
+}
+
+// RangeOptions gives AdBreaksInRange the context needed to place offsets of
+// different kinds on the same timeline.
+type RangeOptions struct {
+	// ContentDuration is the duration of the content. When set, percentage
+	// offsets and the end offset are converted to durations before comparing.
+	ContentDuration Duration
+}
+
+// AdBreaksInRange returns the breaks whose TimeOffset falls strictly between
+// start and end, in playback order, such as the breaks skipped by a seek.
+//
+// Offsets are ordered with TimeOffset.Compare, which cannot order percentage
+// offsets against durations. Callers must either pass start and end of the
+// same kind as the break offsets, or pass RangeOptions with the content
+// duration so that percentages can be converted. Breaks whose offset still
+// cannot be ordered against both bounds, such as position offsets, are never
+// returned.
+func (v *VMAP) AdBreaksInRange(start, end TimeOffset, opts ...RangeOptions) []AdBreak {
+	var o RangeOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	start, end = o.normalize(start), o.normalize(end)
+
+	var breaks []AdBreak
+	for i := range v.AdBreaks {
+		to := o.normalize(v.AdBreaks[i].TimeOffset)
+		if !orderable(start, to) || !orderable(to, end) {
+			continue
+		}
+		if start.Compare(to) < 0 && to.Compare(end) < 0 {
+			breaks = append(breaks, v.AdBreaks[i])
+		}
+	}
+	slices.SortStableFunc(breaks, func(a, b AdBreak) int {
+		return o.normalize(a.TimeOffset).Compare(o.normalize(b.TimeOffset))
+	})
+	return breaks
+}
+
+// normalize converts start to a zero duration and, when the content duration
+// is known, percentages and end to durations.
+func (o RangeOptions) normalize(to TimeOffset) TimeOffset {
+	switch to.kind() {
+	case offsetKindPosition:
+		return to
+	case offsetKindPercent, offsetKindEnd:
+		if o.ContentDuration.Duration <= 0 {
+			return to
+		}
+	}
+	d, _ := to.resolve(o.ContentDuration.Duration)
+	return TimeOffset{Duration: &Duration{d}}
+}
+
+// ErrNoContentDuration is returned by ResolveOffsets when a break has a
+// percentage or end offset and the content duration is not known.
+var ErrNoContentDuration = errors.New("content duration is needed to resolve offset")
+
+// ResolvedBreak is an AdBreak with its offset resolved by ResolveOffsets.
+type ResolvedBreak struct {
+	// AdBreak points into the AdBreaks of the VMAP.
+	AdBreak *AdBreak
+	// Offset is the time into the content at which the break plays. It is
+	// zero for position offsets.
+	Offset time.Duration
+	// IsPosition is set for position offsets such as "#2", which refer to
+	// points in the content that the VMAP document does not place in time.
+	// The position is in AdBreak.TimeOffset.Position.
+	IsPosition bool
+}
+
+// ResolveOffsets returns the breaks of the VMAP, in document order, with
+// their offsets resolved to a time into the content as by
+// TimeOffset.ToSeconds: start is 0, percentages are resolved against
+// contentDuration and end is contentDuration. An error wrapping
+// ErrNoContentDuration is returned if a percentage or end offset is found
+// and contentDuration is not positive. An end offset is rejected too, since
+// resolving it to 0 would schedule a post-roll before the content.
+func (v *VMAP) ResolveOffsets(contentDuration time.Duration) ([]ResolvedBreak, error) {
+	resolved := make([]ResolvedBreak, len(v.AdBreaks))
+	for i := range v.AdBreaks {
+		ab := &v.AdBreaks[i]
+		resolved[i].AdBreak = ab
+		switch ab.TimeOffset.kind() {
+		case offsetKindPosition:
+			resolved[i].IsPosition = true
+			continue
+		case offsetKindPercent, offsetKindEnd:
+			if contentDuration <= 0 {
+				return nil, fmt.Errorf("%s: %w", adBreakPath(ab, i), ErrNoContentDuration)
+			}
+		}
+		resolved[i].Offset, _ = ab.TimeOffset.resolve(contentDuration)
+	}
+	return resolved, nil
+}
+
+// orderable reports whether Compare gives the playback order of a and b.
+func orderable(a, b TimeOffset) bool {
+	ka, kb := a.kind(), b.kind()
+	return ka == kb || ka == offsetKindStart || ka == offsetKindEnd || kb == offsetKindStart || kb == offsetKindEnd
+}
+
+// FilterAdBreaks returns a copy of the VMAP holding only the breaks for which
+// keep returns true. The receiver is not modified.
+func (v *VMAP) FilterAdBreaks(keep func(AdBreak) bool) *VMAP {
+	c := v.Clone()
+	c.AdBreaks = slices.DeleteFunc(c.AdBreaks, func(ab AdBreak) bool {
+		return !keep(ab)
+	})
+	return c
+}
+
+// RemoveEmptyAdBreaks returns a copy of the VMAP without the breaks whose
+// AdSource carries inline VAST data without any ads, e.g. a VAST response
+// holding only Error elements. Breaks with an AdTagURI or CustomAdData, and
+// breaks without an AdSource, are kept. The receiver is not modified.
+func (v *VMAP) RemoveEmptyAdBreaks() *VMAP {
+	return v.FilterAdBreaks(func(ab AdBreak) bool {
+		as := ab.AdSource
+		if as == nil || as.VASTData == nil {
+			return true
+		}
+		return as.VASTData.VAST.hasAds()
+	})
+}
+
+// Advertisers returns the distinct advertiser names of the InLine ads in the
+// breaks' inline VAST, in the order they first appear.
+func (v *VMAP) Advertisers() []string {
+	var names []string
+	for i := range v.AdBreaks {
+		as := v.AdBreaks[i].AdSource
+		if as == nil || as.VASTData == nil || as.VASTData.VAST == nil {
+			continue
+		}
+		for _, ad := range as.VASTData.VAST.Ad {
+			if name := ad.InLine.advertiser(); name != "" && !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// advertiser returns the advertiser name of the InLine. It is safe to call
+// on a nil InLine.
+func (il *InLine) advertiser() string {
+	if il == nil {
+		return ""
+	}
+	return il.Advertiser.String()
+}
+
+// RemoveAdBreakByID removes the first break with the given id from the VMAP
+// and reports whether one was found. Unlike FilterAdBreaks it modifies the
+// receiver; the later breaks are shifted down, which is O(n), and the freed
+// slot at the end of the slice is zeroed so it does not keep data alive.
+func (v *VMAP) RemoveAdBreakByID(id string) bool {
+	i := slices.IndexFunc(v.AdBreaks, func(ab AdBreak) bool { return ab.Id == id })
+	if i < 0 {
+		return false
+	}
+	v.AdBreaks = slices.Delete(v.AdBreaks, i, i+1)
+	return true
+}
+
+// RemoveAdBreaksByType removes the breaks that accept ads of the given type,
+// as reported by HasBreakType, from the VMAP and returns how many were
+// removed. Like RemoveAdBreakByID it modifies the receiver in O(n).
+func (v *VMAP) RemoveAdBreaksByType(breakType BreakType) int {
+	n := len(v.AdBreaks)
+	v.AdBreaks = slices.DeleteFunc(v.AdBreaks, func(ab AdBreak) bool {
+		return ab.HasBreakType(breakType)
+	})
+	return n - len(v.AdBreaks)
+}
+
+// AdBreakCount returns the number of ad breaks in the VMAP. It is safe to
+// call on a nil VMAP.
+func (v *VMAP) AdBreakCount() int {
+	if v == nil {
+		return 0
+	}
+	return len(v.AdBreaks)
+}
+
+// IsEmpty reports whether the VMAP has no ad breaks. It is safe to call on a
+// nil VMAP.
+func (v *VMAP) IsEmpty() bool {
+	return v.AdBreakCount() == 0
+}
+
+// HasVAST reports whether the break carries an inline VAST document in its
+// AdSource, as opposed to an AdTagURI or CustomAdData.
+func (ab *AdBreak) HasVAST() bool {
+	return ab.AdSource != nil && ab.AdSource.VASTData != nil && ab.AdSource.VASTData.VAST != nil
+}
+
+// AdCount returns the number of InLine ads in the VAST document carried by the
+// break. Wrapper ads are not counted, since their ads are in another response,
+// and neither are the ads behind an AdTagURI.
+func (ab *AdBreak) AdCount() int {
+	if !ab.HasVAST() {
+		return 0
+	}
+	n := 0
+	for i := range ab.AdSource.VASTData.VAST.Ad {
+		if ab.AdSource.VASTData.VAST.Ad[i].InLine != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// hasAds reports whether the VAST holds at least one InLine or Wrapper ad.
+func (v *VAST) hasAds() bool {
+	if v == nil {
+		return false
+	}
+	for i := range v.Ad {
+		if v.Ad[i].InLine != nil || v.Ad[i].Wrapper != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFilled reports whether the break's inline VAST holds an ad that can be
+// played: an InLine ad with a Linear creative, or a Wrapper ad with a
+// VASTAdTagURI to resolve. Breaks whose VAST only carries Error elements, and
+// breaks with an AdTagURI or CustomAdData that has not been fetched, are not
+// filled.
+func (ab *AdBreak) IsFilled() bool {
+	if ab.AdSource == nil || ab.AdSource.VASTData == nil || ab.AdSource.VASTData.VAST == nil {
+		return false
+	}
+	for _, ad := range ab.AdSource.VASTData.VAST.Ad {
+		if ad.Wrapper != nil && ad.Wrapper.VASTAdTagURI != "" {
+			return true
+		}
+		if ad.InLine != nil {
+			for _, c := range ad.InLine.Creatives {
+				if c.Linear != nil {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// ErrorURLs returns the non-empty Error URLs at the root of the break's
+// inline VAST, which are the beacons to fire when the break is not filled.
+func (ab *AdBreak) ErrorURLs() []string {
+	if ab.AdSource == nil || ab.AdSource.VASTData == nil {
+		return nil
+	}
+	return ab.AdSource.VASTData.VAST.ErrorURLs()
+}
+
+// ErrorURLs returns the non-empty root level Error URLs of the VAST, which an
+// ad server sends in a response without ads. It is safe to call on a nil VAST.
+func (v *VAST) ErrorURLs() []string {
+	if v == nil {
+		return nil
+	}
+	var urls []string
+	for _, e := range v.Error {
+		if u := strings.TrimSpace(e.Value); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// ViolatesBlockedCategories returns the categories of the InLine ads in the
+// VAST that are blocked by the BlockedAdCategories of the wrappers that led to
+// them, as "authority code" strings. Each InLine is only checked against the
+// Wrapper and WrapperChain of its own Ad, as filled in by ResolveWrappers, so
+// the blocks of one ad in a pod do not apply to the others. Blocked codes
+// without an authority match a category from any authority.
+func (v *VAST) ViolatesBlockedCategories() []string {
+	type blocked struct{ authority, code string }
+	var violations []string
+	for i := range v.Ad {
+		ad := &v.Ad[i]
+		if ad.InLine == nil {
+			continue
+		}
+		var blocks []blocked
+		addBlocks := func(w *Wrapper) {
+			for _, b := range w.BlockedAdCategories {
+				for _, code := range b.Codes() {
+					blocks = append(blocks, blocked{strings.TrimSpace(b.Authority), code})
+				}
+			}
+		}
+		if ad.Wrapper != nil {
+			addBlocks(ad.Wrapper)
+		}
+		for j := range ad.WrapperChain {
+			addBlocks(&ad.WrapperChain[j])
+		}
+		for _, c := range ad.InLine.Categories {
+			authority, code := strings.TrimSpace(c.Authority), strings.TrimSpace(c.Value)
+			for _, b := range blocks {
+				if b.code == code && (b.authority == "" || b.authority == authority) {
+					violations = append(violations, strings.TrimSpace(authority+" "+code))
+					break
+				}
+			}
+		}
+	}
+	return violations
+}
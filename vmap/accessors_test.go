@@ -0,0 +1,755 @@
+package vmap
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestAdClickURLs(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVast.xml")
+	is.NoErr(err)
+
+	vast, err := DecodeVast(doc)
+	is.NoErr(err)
+
+	ad := vast.Ad[0]
+	is.Equal(ad.ClickThroughURL(), "https://github.com/Eyevinn/test-adserver")
+	is.Equal(len(ad.ClickTrackingURLs()), 0)
+
+	doc, err = os.ReadFile("sample-vmap/testVast2.xml")
+	is.NoErr(err)
+	vast, err = DecodeVast(doc)
+	is.NoErr(err)
+
+	ad = vast.Ad[0]
+	is.Equal(ad.ClickThroughURL(), "")
+	urls := ad.ClickTrackingURLs()
+	is.Equal(len(urls), 1)
+	is.True(strings.HasPrefix(urls[0], "https://80276.v.fwmrm.net/ad/l/1?"))
+
+	var empty Ad
+	is.Equal(empty.ClickThroughURL(), "")
+	is.Equal(len(empty.ClickTrackingURLs()), 0)
+}
+
+func TestAdBreakHasBreakType(t *testing.T) {
+	is := is.New(t)
+	single := AdBreak{BreakType: BreakTypeLinear}
+	is.True(single.HasBreakType(BreakTypeLinear))
+	is.True(!single.HasBreakType(BreakTypeNonLinear))
+
+	multi := AdBreak{BreakType: " linear , nonlinear"}
+	is.True(multi.HasBreakType(BreakTypeLinear))
+	is.True(multi.HasBreakType(BreakTypeNonLinear))
+	is.True(!multi.HasBreakType(BreakTypeDisplay))
+
+	is.True(!(&AdBreak{}).HasBreakType(""))
+
+	is.True(single.IsLinearBreak() && !single.IsNonLinearBreak() && !single.IsDisplayBreak())
+	is.True(multi.IsLinearBreak() && multi.IsNonLinearBreak() && !multi.IsDisplayBreak())
+	is.True((&AdBreak{BreakType: BreakTypeDisplay}).IsDisplayBreak())
+}
+
+func TestAllTrackingURLs(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVmap.xml")
+	is.NoErr(err)
+	v, err := DecodeVmap(doc)
+	is.NoErr(err)
+
+	ab := &v.AdBreaks[0]
+	urls := ab.AllTrackingURLs()
+	is.Equal(len(urls[EventBreakStart]), 1)
+	is.Equal(urls[EventBreakStart][0], ab.TrackingEvents[0].Text)
+
+	l := &Linear{TrackingEvents: []TrackingEvent{
+		{Event: EventStart, Text: "https://a.example.com/start"},
+		{Event: EventProgress, Text: "https://a.example.com/5s"},
+		{Event: EventStart, Text: "https://b.example.com/start"},
+		{Event: EventProgress, Text: "https://a.example.com/10s"},
+	}}
+	is.Equal(l.AllTrackingURLs(), map[string][]string{
+		EventStart:    {"https://a.example.com/start", "https://b.example.com/start"},
+		EventProgress: {"https://a.example.com/5s", "https://a.example.com/10s"},
+	})
+	is.Equal(len((&Linear{}).AllTrackingURLs()), 0)
+
+	doc, err = os.ReadFile("sample-vmap/testVastViewable.xml")
+	is.NoErr(err)
+	vast, err := DecodeVast(doc)
+	is.NoErr(err)
+	is.Equal(vast.Ad[0].InLine.AllTrackingURLs(), map[string][]string{
+		"impression": {"https://adserver.example.com/impression?ad=1"},
+		"viewable": {
+			"https://viewability.example.com/viewable?ad=1",
+			"https://adserver.example.com/viewable?ad=1&v=1",
+		},
+		"notViewable":      {"https://viewability.example.com/notviewable?ad=1"},
+		"viewUndetermined": {"https://viewability.example.com/undetermined?ad=1"},
+		EventStart:         {"https://adserver.example.com/start?ad=1"},
+	})
+	is.Equal(len(vast.Ad[1].InLine.AllTrackingURLs()), 0)
+
+	doc, err = os.ReadFile("sample-vmap/testVastIcons.xml")
+	is.NoErr(err)
+	vast, err = DecodeVastScan(doc)
+	is.NoErr(err)
+	is.Equal(vast.Ad[0].InLine.AllTrackingURLs(), map[string][]string{
+		"impression": {"https://adserver.example.com/impression?ad=icons1"},
+		"iconClickTracking": {
+			"https://adserver.example.com/icon/click?ad=icons1&p=adchoices",
+			"https://thirdparty.example.com/icon/click?ad=icons1",
+		},
+		"iconViewTracking": {"https://adserver.example.com/icon/view?ad=icons1"},
+	})
+
+	doc = []byte(`<VAST version="4.1"><Ad id="1"><InLine>` +
+		`<Error>https://t.example.com/error?c=[ERRORCODE]</Error>` +
+		`<Impression>https://t.example.com/imp</Impression>` +
+		`<AdVerifications><Verification vendor="v"><TrackingEvents>` +
+		`<Tracking event="verificationNotExecuted">https://t.example.com/ne</Tracking>` +
+		`</TrackingEvents></Verification></AdVerifications>` +
+		`<Creatives><Creative><Linear><Duration>00:00:10</Duration><VideoClicks>` +
+		`<ClickTracking>https://t.example.com/click</ClickTracking>` +
+		`<CustomClick>https://t.example.com/custom</CustomClick>` +
+		`</VideoClicks></Linear></Creative></Creatives></InLine></Ad></VAST>`)
+	vast = VAST{}
+	is.NoErr(xml.Unmarshal(doc, &vast))
+	rewritten := make(map[string][]string)
+	vast.RewriteTrackingURLs(func(eventType, url string) string {
+		rewritten[eventType] = append(rewritten[eventType], url)
+		return url
+	})
+	is.Equal(vast.Ad[0].InLine.AllTrackingURLs(), rewritten)
+	is.Equal(rewritten["customClick"], []string{"https://t.example.com/custom"})
+	is.Equal(len(rewritten), 5)
+}
+
+func TestAdBreakNextBreakTime(t *testing.T) {
+	is := is.New(t)
+	at := func(d time.Duration) TimeOffset {
+		return TimeOffset{Duration: &Duration{d}}
+	}
+	ab := AdBreak{TimeOffset: at(10 * time.Minute), RepeatAfter: &Duration{15 * time.Minute}}
+	is.True(ab.IsRepeating())
+
+	is.Equal(ab.NextBreakTime(at(0)), at(10*time.Minute))
+	is.Equal(ab.NextBreakTime(at(10*time.Minute)), at(25*time.Minute))
+	is.Equal(ab.NextBreakTime(at(24*time.Minute)), at(25*time.Minute))
+	is.Equal(ab.NextBreakTime(at(25*time.Minute)), at(40*time.Minute))
+	is.Equal(ab.NextBreakTime(TimeOffset{Position: OffsetStart}), at(10*time.Minute))
+
+	fromStart := AdBreak{TimeOffset: TimeOffset{Position: OffsetStart}, RepeatAfter: &Duration{5 * time.Minute}}
+	is.Equal(fromStart.NextBreakTime(at(7*time.Minute)), at(10*time.Minute))
+
+	// Not repeating, or offsets that cannot be placed on the content timeline
+	once := AdBreak{TimeOffset: at(10 * time.Minute)}
+	is.True(!once.IsRepeating())
+	is.Equal(once.NextBreakTime(at(20*time.Minute)), at(10*time.Minute))
+	percent := AdBreak{TimeOffset: TimeOffset{Percent: 0.5}, RepeatAfter: &Duration{time.Minute}}
+	is.Equal(percent.NextBreakTime(at(20*time.Minute)), TimeOffset{Percent: 0.5})
+	is.Equal(ab.NextBreakTime(TimeOffset{Position: OffsetEnd}), at(10*time.Minute))
+}
+
+func TestExtensionHelpers(t *testing.T) {
+	is := is.New(t)
+	il := InLine{Extensions: []Extension{
+		{ExtensionType: "Other"},
+		{ExtensionType: "FreeWheel", CreativeParameters: []CreativeParameter{
+			{CreativeId: "1", Name: "AdType", Value: "bumper"},
+			{CreativeId: "2", Name: "AdType", Value: "commercial"},
+			{CreativeId: "1", Name: "Brand", Value: "Example"},
+		}},
+	}}
+
+	ext := il.GetExtensionByType("FreeWheel")
+	is.True(ext == &il.Extensions[1])
+	is.Equal(il.GetExtensionByType("Missing"), nil)
+
+	is.Equal(ext.GetCreativeParameter("2", "AdType").Value, "commercial")
+	is.Equal(ext.GetCreativeParameter("2", "Brand"), nil)
+	is.Equal(len(ext.GetCreativeParametersByCreativeId("1")), 2)
+	is.Equal(ext.GetCreativeParametersByCreativeId("3"), nil)
+}
+
+func TestInLineCreativeParameters(t *testing.T) {
+	is := is.New(t)
+	doc := []byte(`<VAST version="4.1"><Ad id="1"><InLine><AdTitle>fw</AdTitle><Extensions>` +
+		`<Extension type="FreeWheel"><CreativeParameters>` +
+		`<CreativeParameter creativeId="101" name="AdType" type="Linear">bumper</CreativeParameter>` +
+		`<CreativeParameter creativeId="102" name="AdType" type="Linear">commercial</CreativeParameter>` +
+		`<CreativeParameter creativeId="101" name="Brand" type="Linear">Example</CreativeParameter>` +
+		`</CreativeParameters></Extension>` +
+		`<Extension type="Other"><CreativeParameters>` +
+		`<CreativeParameter creativeId="101" name="AdType" type="Linear">ignored</CreativeParameter>` +
+		`<CreativeParameter creativeId="102" name="Category" type="Linear">Cars</CreativeParameter>` +
+		`</CreativeParameters></Extension>` +
+		`</Extensions></InLine></Ad></VAST>`)
+	for _, decode := range []func([]byte) (VAST, error){DecodeVast, DecodeVastScan} {
+		v, err := decode(doc)
+		is.NoErr(err)
+		il := v.Ad[0].InLine
+
+		val, ok := il.CreativeParameter("101", "AdType")
+		is.True(ok)
+		is.Equal(val, "bumper") // the first extension wins
+		val, ok = il.CreativeParameter("102", "Category")
+		is.True(ok)
+		is.Equal(val, "Cars")
+		_, ok = il.CreativeParameter("102", "Brand")
+		is.True(!ok)
+		_, ok = il.CreativeParameter("103", "AdType")
+		is.True(!ok)
+
+		is.Equal(il.CreativeParametersFor("101"), map[string]string{"AdType": "bumper", "Brand": "Example"})
+		is.Equal(il.CreativeParametersFor("102"), map[string]string{"AdType": "commercial", "Category": "Cars"})
+		is.Equal(il.CreativeParametersFor("103"), nil)
+	}
+}
+
+func TestAdTypeAndConditional(t *testing.T) {
+	is := is.New(t)
+	doc := []byte(`<VAST version="4.1">` +
+		`<Ad id="plain"><InLine><AdTitle>plain</AdTitle></InLine></Ad>` +
+		`<Ad id="hybrid" conditionalAd="true" adType="hybrid"><InLine><AdTitle>hybrid</AdTitle></InLine></Ad>` +
+		`</VAST>`)
+	for _, decode := range []func([]byte) (VAST, error){DecodeVast, DecodeVastScan} {
+		v, err := decode(doc)
+		is.NoErr(err)
+
+		plain, hybrid := &v.Ad[0], &v.Ad[1]
+		is.Equal(plain.ConditionalAd, nil)
+		is.Equal(plain.AdType, "")
+		is.True(!plain.IsConditional())
+		is.True(plain.IsVideo())
+		is.True(!plain.IsHybrid())
+		is.True(hybrid.IsConditional())
+		is.True(hybrid.IsHybrid())
+		is.True(!hybrid.IsVideo() && !hybrid.IsAudio())
+
+		out, err := MarshalVast(&v)
+		is.NoErr(err)
+		is.True(strings.Contains(string(out), `<Ad id="plain"><InLine>`))
+		is.True(strings.Contains(string(out), `<Ad id="hybrid" conditionalAd="true" adType="hybrid">`))
+	}
+	is.True(!(&Ad{ConditionalAd: new(bool)}).IsConditional())
+}
+
+func TestGetUniversalAdId(t *testing.T) {
+	is := is.New(t)
+	c := Creative{UniversalAdIds: []UniversalAdId{
+		{IdRegistry: "ad-id.org", Id: "CNPA0484000H"},
+		{IdRegistry: "broadcaster.example.com", Id: "BRD-2024-0042"},
+	}}
+	is.Equal(c.GetUniversalAdId("broadcaster.example.com").Id, "BRD-2024-0042")
+	is.Equal(c.GetUniversalAdId("unknown"), nil)
+	is.Equal(c.UniversalAdID("ad-id.org"), "CNPA0484000H")
+	is.Equal(c.UniversalAdID("unknown"), "")
+
+	id, ok := c.UniversalIdFor("broadcaster.example.com")
+	is.True(ok)
+	is.Equal(id, "BRD-2024-0042")
+	c.UniversalAdIds = append(c.UniversalAdIds, UniversalAdId{IdRegistry: "unknown"})
+	id, ok = c.UniversalIdFor("unknown")
+	is.True(ok)
+	is.Equal(id, "")
+	_, ok = c.UniversalIdFor("example.com")
+	is.True(!ok)
+}
+
+func TestUniversalAdIdsRoundTrip(t *testing.T) {
+	is := is.New(t)
+	data, err := os.ReadFile("sample-vmap/testVast41Namespaced.xml")
+	is.NoErr(err)
+	v, err := DecodeVast(data)
+	is.NoErr(err)
+	want := []UniversalAdId{
+		{IdRegistry: "ad-id.org", Id: "CNPA0484000H"},
+		{IdRegistry: "broadcaster.example.com", Id: "BRD-2024-0042"},
+	}
+	is.Equal(v.Ad[0].InLine.Creatives[0].UniversalAdIds, want)
+
+	out, err := xml.Marshal(v)
+	is.NoErr(err)
+	is.True(bytes.Index(out, []byte(`idRegistry="ad-id.org"`)) <
+		bytes.Index(out, []byte(`idRegistry="broadcaster.example.com"`)))
+	var back VAST
+	is.NoErr(xml.Unmarshal(out, &back))
+	is.Equal(back.Ad[0].InLine.Creatives[0].UniversalAdIds, want)
+
+	j, err := json.Marshal(v.Ad[0].InLine.Creatives[0])
+	is.NoErr(err)
+	var c Creative
+	is.NoErr(json.Unmarshal(j, &c))
+	is.Equal(c.UniversalAdIds, want)
+}
+
+func TestMediaFileMimeType(t *testing.T) {
+	is := is.New(t)
+	for _, tc := range []struct {
+		file      MediaFile
+		mimeType  string
+		streaming bool
+	}{
+		{MediaFile{Text: "https://cdn.example.com/ad.mp4", Delivery: "progressive"}, "video/mp4", false},
+		{MediaFile{Text: "https://cdn.example.com/AD.MP4?token=a.m3u8"}, "video/mp4", false},
+		{MediaFile{Text: "https://cdn.example.com/ad/master.m3u8?cb=1"}, "application/x-mpegURL", true},
+		{MediaFile{Text: "https://cdn.example.com/ad/manifest.mpd"}, "application/dash+xml", true},
+		{MediaFile{Text: "https://cdn.example.com/ad.mp4", MediaType: "video/webm"}, "video/webm", false},
+		{MediaFile{Text: "https://cdn.example.com/ad", MediaType: "application/vnd.apple.mpegurl"},
+			"application/vnd.apple.mpegurl", true},
+		{MediaFile{Text: "https://cdn.example.com/ad.mp4", Delivery: "streaming"}, "video/mp4", true},
+		{MediaFile{Text: "https://cdn.example.com/ad"}, "", false},
+		{MediaFile{}, "", false},
+	} {
+		is.Equal(tc.file.MimeType(), tc.mimeType)
+		is.Equal(tc.file.IsStreaming(), tc.streaming)
+	}
+}
+
+func TestSelectMediaFile(t *testing.T) {
+	is := is.New(t)
+	l := &Linear{MediaFiles: []MediaFile{
+		{Text: "https://cdn.example.com/ad-low.mp4", Delivery: DeliveryProgressive, Bitrate: 800},
+		{Text: "https://cdn.example.com/ad/master.m3u8", Delivery: DeliveryStreaming},
+		{Text: "https://cdn.example.com/ad-high.mp4", Delivery: DeliveryProgressive, Bitrate: 2500},
+		{Text: "https://cdn.example.com/ad-high.webm", Delivery: DeliveryProgressive, Bitrate: 2500},
+		{Text: "https://cdn.example.com/ad.flv", Delivery: "progresive", Bitrate: 9000},
+	}}
+	is.Equal(l.SelectMediaFile(DeliveryProgressive).Text, "https://cdn.example.com/ad-high.mp4")
+	is.Equal(l.SelectMediaFile(DeliveryStreaming).Text, "https://cdn.example.com/ad/master.m3u8")
+	is.Equal(l.SelectMediaFile(DeliveryStreaming), &l.MediaFiles[1])
+	is.Equal((&Linear{}).SelectMediaFile(DeliveryStreaming), nil)
+	is.Equal((*Linear)(nil).SelectMediaFile(DeliveryProgressive), nil)
+}
+
+func TestSelectMediaFileBitrateRange(t *testing.T) {
+	is := is.New(t)
+	is.Equal(pair((&MediaFile{Bitrate: 800, MinBitrate: 300, MaxBitrate: 3000}).BitrateRange()), [2]int{800, 800})
+	is.Equal(pair((&MediaFile{MinBitrate: 300, MaxBitrate: 3000}).BitrateRange()), [2]int{300, 3000})
+	is.Equal(pair((&MediaFile{MaxBitrate: 3000}).BitrateRange()), [2]int{3000, 3000})
+	is.Equal(pair((&MediaFile{MinBitrate: 300}).BitrateRange()), [2]int{300, 300})
+	is.Equal(pair((&MediaFile{}).BitrateRange()), [2]int{0, 0})
+
+	l := &Linear{MediaFiles: []MediaFile{
+		{Text: "https://cdn.example.com/ad/hd.m3u8", Delivery: DeliveryStreaming, MinBitrate: 800, MaxBitrate: 6000},
+		{Text: "https://cdn.example.com/ad/sd.m3u8", Delivery: DeliveryStreaming, Bitrate: 2000},
+	}}
+	is.Equal(l.SelectMediaFile(DeliveryStreaming), &l.MediaFiles[0])
+
+	audio := &Linear{MediaFiles: []MediaFile{
+		{Text: "https://cdn.example.com/ad/audio.m3u8", MediaType: "audio/mp4", MinBitrate: 64, MaxBitrate: 256},
+		{Text: "https://cdn.example.com/ad/audio-96k.mp3", MediaType: "audio/mpeg", Bitrate: 96},
+		{Text: "https://cdn.example.com/ad/audio-48k.mp3", MediaType: "audio/mpeg", Bitrate: 48},
+	}}
+	is.Equal(audio.SelectAudioFile(0), &audio.MediaFiles[0])
+	is.Equal(audio.SelectAudioFile(128), &audio.MediaFiles[0]) // taken at 128
+	is.Equal(audio.SelectAudioFile(80), &audio.MediaFiles[0])  // taken at 80, above 48
+	is.Equal(audio.SelectAudioFile(60), &audio.MediaFiles[2])  // below the range
+	is.Equal(audio.SelectAudioFile(32), &audio.MediaFiles[2])  // lowest
+}
+
+func pair(a, b int) [2]int { return [2]int{a, b} }
+
+func TestSelectAudioFile(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVastAudio.xml")
+	is.NoErr(err)
+	vast, err := DecodeVast(doc)
+	is.NoErr(err)
+	is.True(vast.Ad[0].IsAudio())
+	l := vast.Ad[0].InLine.Creatives[0].Linear
+
+	for maxBitrate, want := range map[int]string{
+		0:    "https://cdn.example.com/ads/audio-30s-192k.mp3",
+		1000: "https://cdn.example.com/ads/audio-30s-192k.mp3",
+		160:  "https://cdn.example.com/ads/audio-30s-128k.mp3",
+		100:  "https://cdn.example.com/ads/audio-30s-64k.mp3",
+		32:   "https://cdn.example.com/ads/audio-30s-64k.mp3",
+	} {
+		m := l.SelectAudioFile(maxBitrate)
+		is.True(m != nil)
+		is.Equal(m.Text, want)
+		is.Equal(m.Width, 0)
+	}
+
+	// the MIME type may be inferred from the URL
+	untyped := &Linear{MediaFiles: []MediaFile{
+		{Text: "https://cdn.example.com/ads/cover.mp4", Bitrate: 96},
+		{Text: "https://cdn.example.com/ads/spot.mp3", Bitrate: 96},
+	}}
+	is.Equal(untyped.SelectAudioFile(0), &untyped.MediaFiles[1])
+
+	is.Equal((&Linear{MediaFiles: []MediaFile{{MediaType: "video/mp4"}}}).SelectAudioFile(0), nil)
+	is.Equal((*Linear)(nil).SelectAudioFile(0), nil)
+}
+
+func TestSelectInteractiveCreativeFile(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVast4MediaFiles.xml")
+	is.NoErr(err)
+	vast, err := DecodeVast(doc)
+	is.NoErr(err)
+	l := vast.Ad[0].InLine.Creatives[0].Linear
+
+	f := l.SelectInteractiveCreativeFile(APIFrameworkSIMID)
+	is.True(f != nil)
+	is.Equal(f.Text, "https://cdn.example.com/ads/simid/index.html")
+	is.True(f.VariableDuration != nil && *f.VariableDuration)
+	is.Equal(l.SelectInteractiveCreativeFile("simid"), &l.InteractiveCreativeFiles[0])
+	is.Equal(l.SelectInteractiveCreativeFile(""), &l.InteractiveCreativeFiles[0])
+	is.Equal(l.SelectInteractiveCreativeFile("VPAID"), nil)
+	// the interactive file is not a media file
+	is.Equal(l.SelectMediaFile(DeliveryProgressive).Text, l.MediaFiles[0].Text)
+	is.Equal((*Linear)(nil).SelectInteractiveCreativeFile(""), nil)
+}
+
+func TestNewAdServingId(t *testing.T) {
+	is := is.New(t)
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	id := NewAdServingId()
+	is.True(uuid.MatchString(id))
+	is.True(id != NewAdServingId())
+
+	vast := VAST{Version: "4.1", Ad: []Ad{{Id: "1", InLine: &InLine{AdServingId: id}}}}
+	is.Equal(len(vast.Validate()), 0)
+}
+
+func TestTimeOffsetCompare(t *testing.T) {
+	is := is.New(t)
+	at := func(d time.Duration) TimeOffset {
+		return TimeOffset{Duration: &Duration{d}}
+	}
+	start := TimeOffset{Position: OffsetStart}
+	end := TimeOffset{Position: OffsetEnd}
+
+	is.Equal(at(time.Minute).Compare(at(2*time.Minute)), -1)
+	is.Equal(at(time.Minute).Compare(at(time.Minute)), 0)
+	is.Equal(TimeOffset{Percent: 0.5}.Compare(TimeOffset{Percent: 0.25}), 1)
+	is.Equal(TimeOffset{Position: 2}.Compare(TimeOffset{Position: 3}), -1)
+	is.Equal(start.Compare(at(0)), -1)
+	is.Equal(end.Compare(at(time.Hour)), 1)
+	is.Equal(end.Compare(end), 0)
+	is.Equal(at(time.Hour).Compare(TimeOffset{Percent: 0.1}), -1)
+}
+
+func TestTimeOffsetToSeconds(t *testing.T) {
+	is := is.New(t)
+	content := Duration{10 * time.Minute}
+	for _, tc := range []struct {
+		offset TimeOffset
+		want   float64
+	}{
+		{TimeOffset{Position: OffsetStart}, 0},
+		{TimeOffset{Duration: &Duration{90*time.Second + 500*time.Millisecond}}, 90.5},
+		{TimeOffset{Percent: 0.25}, 150},
+		{TimeOffset{Position: OffsetEnd}, 600},
+	} {
+		got, err := tc.offset.ToSeconds(content)
+		is.NoErr(err)
+		is.Equal(got, tc.want)
+	}
+
+	_, err := TimeOffset{Position: 2}.ToSeconds(content)
+	is.True(err != nil)
+}
+
+func TestAdBreaksInRange(t *testing.T) {
+	is := is.New(t)
+	at := func(d time.Duration) TimeOffset {
+		return TimeOffset{Duration: &Duration{d}}
+	}
+	v := VMAP{AdBreaks: []AdBreak{
+		{Id: "post", TimeOffset: TimeOffset{Position: OffsetEnd}},
+		{Id: "pre", TimeOffset: TimeOffset{Position: OffsetStart}},
+		{Id: "mid-10", TimeOffset: at(10 * time.Minute)},
+		{Id: "mid-5", TimeOffset: at(5 * time.Minute)},
+		{Id: "half", TimeOffset: TimeOffset{Percent: 0.5}},
+		{Id: "pod-2", TimeOffset: TimeOffset{Position: 2}},
+	}}
+	ids := func(breaks []AdBreak) []string {
+		var ids []string
+		for _, ab := range breaks {
+			ids = append(ids, ab.Id)
+		}
+		return ids
+	}
+
+	// Bounds are exclusive
+	is.Equal(ids(v.AdBreaksInRange(at(5*time.Minute), at(10*time.Minute))), []string(nil))
+	is.Equal(ids(v.AdBreaksInRange(at(5*time.Minute-1), at(10*time.Minute))), []string{"mid-5"})
+	is.Equal(ids(v.AdBreaksInRange(at(5*time.Minute-1), at(10*time.Minute+1))), []string{"mid-5", "mid-10"})
+
+	// Start is at zero, so a seek from the very beginning does not include the preroll
+	is.Equal(ids(v.AdBreaksInRange(at(0), at(6*time.Minute))), []string{"mid-5"})
+	is.Equal(ids(v.AdBreaksInRange(at(-1), at(6*time.Minute))), []string{"pre", "mid-5"})
+
+	// Without the content duration percentages and end cannot be placed among durations
+	is.Equal(ids(v.AdBreaksInRange(at(time.Minute), at(time.Hour))), []string{"mid-5", "mid-10"})
+
+	opts := RangeOptions{ContentDuration: Duration{30 * time.Minute}}
+	is.Equal(ids(v.AdBreaksInRange(at(time.Minute), at(time.Hour), opts)),
+		[]string{"mid-5", "mid-10", "half", "post"})
+	is.Equal(ids(v.AdBreaksInRange(at(time.Minute), TimeOffset{Percent: 0.5}, opts)),
+		[]string{"mid-5", "mid-10"})
+
+	// Percentages compare among themselves
+	is.Equal(ids(v.AdBreaksInRange(TimeOffset{Percent: 0.25}, TimeOffset{Percent: 0.75})), []string{"half"})
+}
+
+func TestResolveOffsets(t *testing.T) {
+	is := is.New(t)
+	v := VMAP{AdBreaks: []AdBreak{
+		{Id: "pre", TimeOffset: TimeOffset{Position: OffsetStart}},
+		{Id: "mid-5", TimeOffset: TimeOffset{Duration: &Duration{5 * time.Minute}}},
+		{Id: "quarter", TimeOffset: TimeOffset{Percent: 0.25}},
+		{Id: "pod-2", TimeOffset: TimeOffset{Position: 2}},
+		{Id: "post", TimeOffset: TimeOffset{Position: OffsetEnd}},
+	}}
+
+	resolved, err := v.ResolveOffsets(40 * time.Minute)
+	is.NoErr(err)
+	is.Equal(len(resolved), 5)
+	for i, want := range []time.Duration{0, 5 * time.Minute, 10 * time.Minute, 0, 40 * time.Minute} {
+		is.Equal(resolved[i].AdBreak, &v.AdBreaks[i])
+		is.Equal(resolved[i].Offset, want)
+		is.Equal(resolved[i].IsPosition, i == 3)
+	}
+
+	_, err = v.ResolveOffsets(0)
+	is.True(errors.Is(err, ErrNoContentDuration))
+	is.Equal(err.Error(), "AdBreak[quarter]: content duration is needed to resolve offset")
+
+	v.AdBreaks = v.AdBreaks[:2]
+	resolved, err = v.ResolveOffsets(0)
+	is.NoErr(err)
+	is.Equal(resolved[1].Offset, 5*time.Minute)
+}
+
+func TestFilterAdBreaks(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVmap.xml")
+	is.NoErr(err)
+	v, err := DecodeVmap(doc)
+	is.NoErr(err)
+	original := v.Clone()
+
+	filtered := v.FilterAdBreaks(func(ab AdBreak) bool {
+		return ab.Id != "midroll.ad-2"
+	})
+	is.Equal(len(filtered.AdBreaks), 2)
+	is.Equal(filtered.AdBreaks[0].Id, "midroll.ad-1")
+	is.Equal(filtered.AdBreaks[1].Id, "midroll.ad-3")
+	is.Equal(v, *original) // receiver is untouched
+
+	filtered.AdBreaks[0].AdSource.VASTData.VAST.Ad[0].Id = "changed"
+	is.Equal(v, *original)
+}
+
+func TestRemoveEmptyAdBreaks(t *testing.T) {
+	is := is.New(t)
+	v := VMAP{AdBreaks: []AdBreak{
+		{Id: "filled", AdSource: &AdSource{VASTData: &VASTData{VAST: &VAST{Ad: []Ad{{InLine: &InLine{}}}}}}},
+		{Id: "wrapper", AdSource: &AdSource{VASTData: &VASTData{VAST: &VAST{Ad: []Ad{{Wrapper: &Wrapper{}}}}}}},
+		{Id: "no-ads", AdSource: &AdSource{VASTData: &VASTData{VAST: &VAST{Version: "3.0"}}}},
+		{Id: "empty-ad", AdSource: &AdSource{VASTData: &VASTData{VAST: &VAST{Ad: []Ad{{Id: "1"}}}}}},
+		{Id: "no-vast", AdSource: &AdSource{VASTData: &VASTData{}}},
+		{Id: "tag", AdSource: &AdSource{AdTagURI: &AdTagURI{URI: "https://adserver.example.com/vast"}}},
+		{Id: "tracking-only"},
+	}}
+
+	cleaned := v.RemoveEmptyAdBreaks()
+	var ids []string
+	for _, ab := range cleaned.AdBreaks {
+		ids = append(ids, ab.Id)
+	}
+	is.Equal(ids, []string{"filled", "wrapper", "tag", "tracking-only"})
+	is.Equal(len(v.AdBreaks), 7)
+}
+
+func TestAdBreakCounts(t *testing.T) {
+	is := is.New(t)
+	v := VMAP{AdBreaks: []AdBreak{
+		{Id: "pod", AdSource: &AdSource{VASTData: &VASTData{VAST: &VAST{Ad: []Ad{
+			{InLine: &InLine{}}, {InLine: &InLine{}}, {Wrapper: &Wrapper{}},
+		}}}}},
+		{Id: "no-ads", AdSource: &AdSource{VASTData: &VASTData{VAST: &VAST{Version: "3.0"}}}},
+		{Id: "no-vast", AdSource: &AdSource{VASTData: &VASTData{}}},
+		{Id: "tag", AdSource: &AdSource{AdTagURI: &AdTagURI{URI: "https://adserver.example.com/vast"}}},
+		{Id: "tracking-only"},
+	}}
+	is.Equal(v.AdBreakCount(), 5)
+	is.True(!v.IsEmpty())
+
+	var counts []int
+	var hasVAST []bool
+	for i := range v.AdBreaks {
+		counts = append(counts, v.AdBreaks[i].AdCount())
+		hasVAST = append(hasVAST, v.AdBreaks[i].HasVAST())
+	}
+	is.Equal(counts, []int{2, 0, 0, 0, 0})
+	is.Equal(hasVAST, []bool{true, true, false, false, false})
+
+	is.True((&VMAP{}).IsEmpty())
+	is.True((*VMAP)(nil).IsEmpty())
+	is.Equal((*VMAP)(nil).AdBreakCount(), 0)
+}
+
+func TestVMAPAdvertisers(t *testing.T) {
+	is := is.New(t)
+	vast := func(names ...string) *AdSource {
+		v := &VAST{}
+		for _, name := range names {
+			v.Ad = append(v.Ad, Ad{InLine: &InLine{Advertiser: &Advertiser{Name: name}}})
+		}
+		v.Ad = append(v.Ad, Ad{InLine: &InLine{}}, Ad{Wrapper: &Wrapper{}})
+		return &AdSource{VASTData: &VASTData{VAST: v}}
+	}
+	v := VMAP{AdBreaks: []AdBreak{
+		{Id: "pre", AdSource: vast("Acme Corp", "Globex")},
+		{Id: "tag", AdSource: &AdSource{AdTagURI: &AdTagURI{URI: "https://adserver.example.com/vast"}}},
+		{Id: "mid", AdSource: vast("Globex", "", "Initech")},
+		{Id: "post"},
+	}}
+	is.Equal(v.Advertisers(), []string{"Acme Corp", "Globex", "Initech"})
+	is.Equal((&VMAP{}).Advertisers(), nil)
+}
+
+func TestRemoveAdBreakByID(t *testing.T) {
+	is := is.New(t)
+	v := VMAP{AdBreaks: []AdBreak{
+		{Id: "pre", BreakType: BreakTypeLinear},
+		{Id: "mid", BreakType: "linear,nonlinear"},
+		{Id: "mid", BreakType: BreakTypeDisplay},
+		{Id: "post", BreakType: BreakTypeLinear},
+	}}
+	ids := func() []string {
+		var ids []string
+		for _, ab := range v.AdBreaks {
+			ids = append(ids, ab.Id)
+		}
+		return ids
+	}
+
+	backing := v.AdBreaks
+	is.True(v.RemoveAdBreakByID("mid"))
+	is.Equal(ids(), []string{"pre", "mid", "post"})
+	is.Equal(v.AdBreaks[1].BreakType, BreakTypeDisplay)
+	is.Equal(backing[3], AdBreak{}) // no stale copy of the last break
+	is.True(!v.RemoveAdBreakByID("missing"))
+	is.Equal(len(v.AdBreaks), 3)
+
+	v.AdBreaks = append(v.AdBreaks, AdBreak{Id: "overlay", BreakType: "nonlinear, linear"})
+	is.Equal(v.RemoveAdBreaksByType(BreakTypeLinear), 3)
+	is.Equal(ids(), []string{"mid"})
+	is.Equal(v.RemoveAdBreaksByType(BreakTypeNonLinear), 0)
+	is.Equal(v.RemoveAdBreaksByType(BreakTypeDisplay), 1)
+	is.Equal(len(v.AdBreaks), 0)
+}
+
+func TestViolatesBlockedCategories(t *testing.T) {
+	is := is.New(t)
+	v := VAST{Ad: []Ad{
+		{InLine: &InLine{Categories: []Category{
+			{Authority: "https://iabtechlab.com/", Value: "IAB8-18"},
+			{Authority: "https://other.example.com/", Value: "IAB8-5"},
+			{Authority: "https://other.example.com/", Value: " IAB25 "},
+			{Authority: "https://iabtechlab.com/", Value: "IAB1"},
+		}}, WrapperChain: []Wrapper{{BlockedAdCategories: []BlockedAdCategory{
+			{Authority: "https://iabtechlab.com/", Value: "IAB8-5, IAB8-18"},
+			{Value: "IAB25"},
+		}}}},
+	}}
+	is.Equal(v.ViolatesBlockedCategories(), []string{
+		"https://iabtechlab.com/ IAB8-18",
+		"https://other.example.com/ IAB25",
+	})
+
+	v.Ad[0].WrapperChain[0].BlockedAdCategories = nil
+	is.Equal(v.ViolatesBlockedCategories(), nil)
+
+	v.Ad[0].WrapperChain = append(v.Ad[0].WrapperChain,
+		Wrapper{BlockedAdCategories: []BlockedAdCategory{{Value: "IAB1"}}})
+	is.Equal(v.ViolatesBlockedCategories(), []string{"https://iabtechlab.com/ IAB1"})
+}
+
+func TestViolatesBlockedCategoriesPerAd(t *testing.T) {
+	is := is.New(t)
+	blocksIAB1 := []Wrapper{{BlockedAdCategories: []BlockedAdCategory{{Value: "IAB1"}}}}
+	iab1 := []Category{{Authority: "https://iabtechlab.com/", Value: "IAB1"}}
+	v := VAST{Ad: []Ad{
+		{InLine: &InLine{Categories: iab1}, WrapperChain: blocksIAB1},
+		{InLine: &InLine{Categories: iab1}},
+		// a wrapper ad that was not resolved blocks nothing in its siblings
+		{Wrapper: &Wrapper{BlockedAdCategories: []BlockedAdCategory{{Value: "IAB1"}}}},
+	}}
+	is.Equal(v.ViolatesBlockedCategories(), []string{"https://iabtechlab.com/ IAB1"})
+
+	v.Ad[0].WrapperChain = nil
+	is.Equal(v.ViolatesBlockedCategories(), nil)
+}
+
+func TestAdBreakIsFilled(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVmapNoAd.xml")
+	is.NoErr(err)
+	v, err := DecodeVmap(doc)
+	is.NoErr(err)
+
+	is.True(v.AdBreaks[0].IsFilled())
+	is.Equal(v.AdBreaks[0].ErrorURLs(), nil)
+
+	is.True(!v.AdBreaks[1].IsFilled())
+	is.Equal(v.AdBreaks[1].ErrorURLs(), []string{
+		"https://adserver.example.com/noad?code=[ERRORCODE]&pos=mid",
+		"https://thirdparty.example.com/noad?pos=mid",
+	})
+
+	is.True(v.AdBreaks[2].IsFilled())
+	is.Equal(v.AdBreaks[2].AdSource.VASTData.VAST.ErrorURLs(), nil)
+	var nilVAST *VAST
+	is.Equal(nilVAST.ErrorURLs(), nil)
+
+	noLinear := AdBreak{AdSource: &AdSource{VASTData: &VASTData{VAST: &VAST{
+		Ad: []Ad{{InLine: &InLine{Creatives: []Creative{{}}}}, {Wrapper: &Wrapper{}}},
+	}}}}
+	is.True(!noLinear.IsFilled())
+	tag := AdBreak{AdSource: &AdSource{AdTagURI: &AdTagURI{URI: "https://adserver.example.com/vast"}}}
+	is.True(!tag.IsFilled())
+	is.Equal(tag.ErrorURLs(), nil)
+}
+
+func TestAdVerifications(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVastAdVerifications.xml")
+	is.NoErr(err)
+	v, err := DecodeVast(doc)
+	is.NoErr(err)
+
+	inline, wrapper := v.Ad[0], v.Ad[1]
+	vendors := func(vs []Verification) []string {
+		var names []string
+		for _, vf := range vs {
+			names = append(names, vf.Vendor)
+		}
+		return names
+	}
+	is.Equal(vendors(inline.Verifications()), []string{"integralads.com-omid", "moat.com-omid"})
+	is.Equal(vendors(wrapper.Verifications()), []string{"doubleverify.com-omid", "empty.example.com"})
+
+	inline.WrapperChain = []Wrapper{*wrapper.Wrapper}
+	is.Equal(vendors(inline.Verifications()), []string{
+		"integralads.com-omid", "moat.com-omid", "doubleverify.com-omid", "empty.example.com",
+	})
+	is.Equal(len((&Ad{}).Verifications()), 0)
+}
@@ -0,0 +1,132 @@
+package vmap
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// CachingTransport is an http.RoundTripper that remembers the ETag and
+// Last-Modified validators of GET responses, together with their bodies,
+// and sends them as If-None-Match and If-Modified-Since on later requests
+// for the same URL. When the server answers 304 Not Modified, the cached
+// response is returned in its place, so that Fetch sees the same document
+// without downloading it again.
+//
+// Responses larger than MaxFetchSize are not cached.
+type CachingTransport struct {
+	// Inner makes the requests. If nil, http.DefaultTransport is used.
+	Inner http.RoundTripper
+	// Cache maps request URLs to their cached responses.
+	Cache sync.Map
+}
+
+// NewCachingTransport returns a CachingTransport that sends its requests
+// through inner.
+func NewCachingTransport(inner http.RoundTripper) *CachingTransport {
+	return &CachingTransport{Inner: inner}
+}
+
+// cachedResponse is a response stored by CachingTransport.
+type cachedResponse struct {
+	etag         string
+	lastModified string
+	statusCode   int
+	header       http.Header
+	body         []byte
+}
+
+func (t *CachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	inner := t.Inner
+	if inner == nil {
+		inner = http.DefaultTransport
+	}
+	if !cacheable(req) {
+		return inner.RoundTrip(req)
+	}
+
+	key := req.URL.String()
+	var cached *cachedResponse
+	if v, ok := t.Cache.Load(key); ok {
+		cached = v.(*cachedResponse)
+		req = req.Clone(req.Context())
+		if cached.etag != "" {
+			req.Header.Set("If-None-Match", cached.etag)
+		}
+		if cached.lastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.lastModified)
+		}
+	}
+
+	resp, err := inner.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return cached.response(req), nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if etag == "" && lastModified == "" {
+		t.Cache.Delete(key)
+		return resp, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxFetchSize+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if int64(len(body)) > MaxFetchSize {
+		// Hand back what was read followed by the rest, uncached.
+		resp.Body = readCloser{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		t.Cache.Delete(key)
+		return resp, nil
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	t.Cache.Store(key, &cachedResponse{
+		etag:         etag,
+		lastModified: lastModified,
+		statusCode:   resp.StatusCode,
+		header:       resp.Header.Clone(),
+		body:         body,
+	})
+	return resp, nil
+}
+
+// cacheable reports whether CachingTransport may answer req from its cache.
+// Requests that carry their own validators are passed through untouched.
+func cacheable(req *http.Request) bool {
+	return req.Method == http.MethodGet &&
+		req.Header.Get("If-None-Match") == "" &&
+		req.Header.Get("If-Modified-Since") == "" &&
+		req.Header.Get("Range") == ""
+}
+
+// response returns a new response for req holding the cached body.
+func (c *cachedResponse) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", c.statusCode, http.StatusText(c.statusCode)),
+		StatusCode:    c.statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        c.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(c.body)),
+		ContentLength: int64(len(c.body)),
+		Request:       req,
+	}
+}
+
+// readCloser reads from Reader and closes Closer.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
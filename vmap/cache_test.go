@@ -0,0 +1,73 @@
+package vmap
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestCachingTransportNotModified(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVmap.xml")
+	is.NoErr(err)
+
+	const etag = `"v1"`
+	const lastModified = "Mon, 02 Jan 2006 15:04:05 GMT"
+	var requests, notModified atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.URL.Path {
+		case "/etag":
+			w.Header().Set("ETag", etag)
+			if r.Header.Get("If-None-Match") == etag {
+				notModified.Add(1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/last-modified":
+			w.Header().Set("Last-Modified", lastModified)
+			if r.Header.Get("If-Modified-Since") == lastModified {
+				notModified.Add(1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		_, _ = w.Write(doc)
+	}))
+	defer srv.Close()
+
+	transport := NewCachingTransport(srv.Client().Transport)
+	client := &http.Client{Transport: transport}
+
+	for _, path := range []string{"/etag", "/last-modified"} {
+		requests.Store(0)
+		notModified.Store(0)
+		first, err := Fetch(context.Background(), client, srv.URL+path)
+		is.NoErr(err)
+		second, err := Fetch(context.Background(), client, srv.URL+path)
+		is.NoErr(err)
+
+		is.Equal(requests.Load(), int32(2))
+		is.Equal(notModified.Load(), int32(1))
+		is.Equal(len(second.AdBreaks), 3)
+		is.True(first.Equal(second))
+	}
+
+	// Responses without validators are not cached.
+	requests.Store(0)
+	notModified.Store(0)
+	for range 2 {
+		_, err := Fetch(context.Background(), client, srv.URL+"/plain")
+		is.NoErr(err)
+	}
+	is.Equal(requests.Load(), int32(2))
+	is.Equal(notModified.Load(), int32(0))
+	_, ok := transport.Cache.Load(srv.URL + "/plain")
+	is.True(!ok)
+}
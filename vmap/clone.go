@@ -0,0 +1,238 @@
+package vmap
+
+import (
+	"maps"
+	"slices"
+)
+
+// Clone returns a deep copy of the VMAP. Mutating the copy, including its
+// ad breaks, embedded VAST documents and time offsets, does not affect v.
+func (v *VMAP) Clone() *VMAP {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	c.Extensions = slices.Clone(v.Extensions)
+	if v.AdBreaks != nil {
+		c.AdBreaks = make([]AdBreak, len(v.AdBreaks))
+		for i := range v.AdBreaks {
+			c.AdBreaks[i] = v.AdBreaks[i].clone()
+		}
+	}
+	return &c
+}
+
+func (ab *AdBreak) clone() AdBreak {
+	c := *ab
+	c.TimeOffset = ab.TimeOffset.clone()
+	c.RepeatAfter = clonePtr(ab.RepeatAfter)
+	c.TrackingEvents = cloneTrackingEvents(ab.TrackingEvents)
+	c.Extensions = slices.Clone(ab.Extensions)
+	c.ExtraAttrs = maps.Clone(ab.ExtraAttrs)
+	if as := ab.AdSource; as != nil {
+		c.AdSource = &AdSource{
+			AdTagURI:     clonePtr(as.AdTagURI),
+			CustomAdData: clonePtr(as.CustomAdData),
+		}
+		if as.VASTData != nil {
+			c.AdSource.VASTData = &VASTData{VAST: as.VASTData.VAST.clone()}
+		}
+	}
+	return c
+}
+
+func (to TimeOffset) clone() TimeOffset {
+	to.Duration = clonePtr(to.Duration)
+	return to
+}
+
+func (v *VAST) clone() *VAST {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	c.Error = slices.Clone(v.Error)
+	if v.Ad != nil {
+		c.Ad = make([]Ad, len(v.Ad))
+		for i := range v.Ad {
+			c.Ad[i] = v.Ad[i].clone()
+		}
+	}
+	return &c
+}
+
+func (ad *Ad) clone() Ad {
+	c := *ad
+	c.Sequence = clonePtr(ad.Sequence)
+	c.ConditionalAd = clonePtr(ad.ConditionalAd)
+	if il := ad.InLine; il != nil {
+		cil := *il
+		cil.AdSystem = clonePtr(il.AdSystem)
+		cil.Impression = slices.Clone(il.Impression)
+		cil.Categories = slices.Clone(il.Categories)
+		cil.Advertiser = clonePtr(il.Advertiser)
+		cil.Pricing = clonePtr(il.Pricing)
+		cil.Survey = clonePtr(il.Survey)
+		if il.ViewableImpression != nil {
+			vi := *il.ViewableImpression
+			vi.Viewable = slices.Clone(vi.Viewable)
+			vi.NotViewable = slices.Clone(vi.NotViewable)
+			vi.ViewUndetermined = slices.Clone(vi.ViewUndetermined)
+			cil.ViewableImpression = &vi
+		}
+		cil.AdVerifications = cloneVerifications(il.AdVerifications)
+		if il.Creatives != nil {
+			cil.Creatives = make([]Creative, len(il.Creatives))
+			for i := range il.Creatives {
+				cil.Creatives[i] = il.Creatives[i].clone()
+			}
+		}
+		cil.Extensions = cloneExtensions(il.Extensions)
+		cil.Error = slices.Clone(il.Error)
+		c.InLine = &cil
+	}
+	if w := ad.Wrapper; w != nil {
+		cw := *w
+		cw.AdSystem = clonePtr(w.AdSystem)
+		cw.Error = slices.Clone(w.Error)
+		cw.Impression = slices.Clone(w.Impression)
+		cw.BlockedAdCategories = slices.Clone(w.BlockedAdCategories)
+		cw.Pricing = clonePtr(w.Pricing)
+		cw.AdVerifications = cloneVerifications(w.AdVerifications)
+		if w.Creatives != nil {
+			cw.Creatives = make([]WrapperCreative, len(w.Creatives))
+			for i := range w.Creatives {
+				cw.Creatives[i] = w.Creatives[i].clone()
+			}
+		}
+		cw.Extensions = cloneExtensions(w.Extensions)
+		cw.FollowAdditionalWrappers = clonePtr(w.FollowAdditionalWrappers)
+		cw.AllowMultipleAds = clonePtr(w.AllowMultipleAds)
+		cw.FallbackOnNoAd = clonePtr(w.FallbackOnNoAd)
+		c.Wrapper = &cw
+	}
+	if ad.WrapperChain != nil {
+		c.WrapperChain = make([]Wrapper, len(ad.WrapperChain))
+		for i := range ad.WrapperChain {
+			c.WrapperChain[i] = *(&Ad{Wrapper: &ad.WrapperChain[i]}).clone().Wrapper
+		}
+	}
+	return c
+}
+
+func (cr *Creative) clone() Creative {
+	c := *cr
+	c.UniversalAdIds = slices.Clone(cr.UniversalAdIds)
+	c.CreativeExtensions = slices.Clone(cr.CreativeExtensions)
+	if l := cr.Linear; l != nil {
+		cl := *l
+		if l.AdParameters != nil {
+			ap := *l.AdParameters
+			ap.XMLEncoded = clonePtr(ap.XMLEncoded)
+			cl.AdParameters = &ap
+		}
+		cl.TrackingEvents = cloneTrackingEvents(l.TrackingEvents)
+		cl.MediaFiles = slices.Clone(l.MediaFiles)
+		for i := range cl.MediaFiles {
+			m := &cl.MediaFiles[i]
+			m.Scalable = clonePtr(m.Scalable)
+			m.MaintainAspectRatio = clonePtr(m.MaintainAspectRatio)
+		}
+		cl.Mezzanine = slices.Clone(l.Mezzanine)
+		cl.InteractiveCreativeFiles = slices.Clone(l.InteractiveCreativeFiles)
+		for i := range cl.InteractiveCreativeFiles {
+			f := &cl.InteractiveCreativeFiles[i]
+			f.VariableDuration = clonePtr(f.VariableDuration)
+		}
+		cl.ClickThrough = clonePtr(l.ClickThrough)
+		cl.ClickTracking = slices.Clone(l.ClickTracking)
+		cl.CustomClick = slices.Clone(l.CustomClick)
+		cl.Icons = cloneIcons(l.Icons)
+		if l.SkipOffset != nil {
+			skip := l.SkipOffset.clone()
+			cl.SkipOffset = &skip
+		}
+		c.Linear = &cl
+	}
+	return c
+}
+
+func (cr *WrapperCreative) clone() WrapperCreative {
+	c := *cr
+	if l := cr.Linear; l != nil {
+		c.Linear = &WrapperLinear{
+			TrackingEvents: cloneTrackingEvents(l.TrackingEvents),
+			ClickTracking:  slices.Clone(l.ClickTracking),
+		}
+	}
+	c.CompanionAds = clonePtr(cr.CompanionAds)
+	return c
+}
+
+func cloneTrackingEvents(events []TrackingEvent) []TrackingEvent {
+	c := slices.Clone(events)
+	for i := range c {
+		if c[i].Offset != nil {
+			to := c[i].Offset.clone()
+			c[i].Offset = &to
+		}
+	}
+	return c
+}
+
+func cloneVerifications(vs []Verification) []Verification {
+	c := slices.Clone(vs)
+	for i := range c {
+		c[i].JavaScriptResources = slices.Clone(vs[i].JavaScriptResources)
+		for j := range c[i].JavaScriptResources {
+			r := &c[i].JavaScriptResources[j]
+			r.BrowserOptional = clonePtr(r.BrowserOptional)
+		}
+		c[i].ExecutableResources = slices.Clone(vs[i].ExecutableResources)
+		c[i].TrackingEvents = cloneTrackingEvents(vs[i].TrackingEvents)
+	}
+	return c
+}
+
+func cloneIcons(icons Icons) Icons {
+	c := slices.Clone(icons)
+	for i := range c {
+		ic := &c[i]
+		ic.Duration = clonePtr(ic.Duration)
+		if ic.Offset != nil {
+			to := ic.Offset.clone()
+			ic.Offset = &to
+		}
+		ic.StaticResources = slices.Clone(ic.StaticResources)
+		ic.IFrameResources = slices.Clone(ic.IFrameResources)
+		ic.HTMLResources = slices.Clone(ic.HTMLResources)
+		if ic.IconClicks != nil {
+			icc := *ic.IconClicks
+			icc.IconClickTracking = slices.Clone(icc.IconClickTracking)
+			ic.IconClicks = &icc
+		}
+		ic.IconViewTracking = slices.Clone(ic.IconViewTracking)
+	}
+	return c
+}
+
+func cloneExtensions(exts []Extension) []Extension {
+	if exts == nil {
+		return nil
+	}
+	c := make([]Extension, len(exts))
+	for i := range exts {
+		c[i] = exts[i]
+		c[i].CreativeParameters = slices.Clone(exts[i].CreativeParameters)
+	}
+	return c
+}
+
+// clonePtr returns a pointer to a shallow copy of *p, or nil if p is nil.
+func clonePtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	c := *p
+	return &c
+}
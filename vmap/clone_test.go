@@ -0,0 +1,75 @@
+package vmap
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestVMAPClone(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVmap.xml")
+	is.NoErr(err)
+
+	v, err := DecodeVmap(doc)
+	is.NoErr(err)
+	clone := v.Clone()
+	is.Equal(*clone, v)
+
+	original := *v.AdBreaks[1].TimeOffset.Duration
+	clone.AdBreaks[1].TimeOffset.Duration.Duration += 10 * time.Second
+	clone.AdBreaks[2].TimeOffset = TimeOffset{Position: -2}
+	clone.AdBreaks[0].TrackingEvents[0].Text = "https://changed.example.com/"
+	clone.AdBreaks[0].AdSource.VASTData.VAST.Ad[0].Id = "changed"
+	clone.AdBreaks[0].AdSource.VASTData.VAST.Ad[0].InLine.Creatives[0].Linear.MediaFiles[0].Width = 1
+	clone.AdBreaks = append(clone.AdBreaks[:1], clone.AdBreaks[2:]...)
+
+	is.Equal(*v.AdBreaks[1].TimeOffset.Duration, original)
+	is.True(v.AdBreaks[2].TimeOffset.Duration != nil)
+	is.True(v.AdBreaks[0].TrackingEvents[0].Text != "https://changed.example.com/")
+	vast := v.AdBreaks[0].AdSource.VASTData.VAST
+	is.True(vast.Ad[0].Id != "changed")
+	is.True(vast.Ad[0].InLine.Creatives[0].Linear.MediaFiles[0].Width != 1)
+	is.Equal(len(v.AdBreaks), len(clone.AdBreaks)+1)
+
+	var nilVMAP *VMAP
+	is.Equal(nilVMAP.Clone(), nil)
+}
+
+func TestVMAPCloneWrapper(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVastWrapper.xml")
+	is.NoErr(err)
+	vast, err := DecodeVast(doc)
+	is.NoErr(err)
+
+	v := &VMAP{AdBreaks: []AdBreak{{AdSource: &AdSource{VASTData: &VASTData{VAST: &vast}}}}}
+	clone := v.Clone()
+	is.Equal(clone, v)
+
+	w := clone.AdBreaks[0].AdSource.VASTData.VAST.Ad[0].Wrapper
+	w.Creatives[0].Linear.TrackingEvents[0].Text = "https://changed.example.com/"
+	w.Extensions[0].CreativeParameters[0].Value = "changed"
+	w.AdSystem.Name = "changed"
+
+	orig := vast.Ad[0].Wrapper
+	is.True(orig.Creatives[0].Linear.TrackingEvents[0].Text != "https://changed.example.com/")
+	is.True(orig.Extensions[0].CreativeParameters[0].Value != "changed")
+	is.True(orig.AdSystem.Name != "changed")
+}
+
+func TestVMAPCloneTrackingOffset(t *testing.T) {
+	is := is.New(t)
+	offset := TimeOffset{Duration: &Duration{5 * time.Second}}
+	events := []TrackingEvent{{Event: EventProgress, Offset: &offset, Text: "https://t.example.com/5s"}}
+	vast := &VAST{Ad: []Ad{{InLine: &InLine{Creatives: []Creative{{Linear: &Linear{TrackingEvents: events}}}}}}}
+	v := &VMAP{AdBreaks: []AdBreak{{AdSource: &AdSource{VASTData: &VASTData{VAST: vast}}}}}
+
+	clone := v.Clone()
+	is.Equal(clone, v)
+	cloned := clone.AdBreaks[0].AdSource.VASTData.VAST.Ad[0].InLine.Creatives[0].Linear.TrackingEvents[0]
+	cloned.Offset.Duration.Duration = time.Second
+	is.Equal(offset.Duration.Duration, 5*time.Second)
+}
@@ -3,15 +3,23 @@ package vmap
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"io"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/CarlLindqvist/xmltokenizer"
 )
 
+// ErrMultipleAdSources is returned when an AdBreak has more than one
+// AdSource, which the VMAP spec does not allow.
+var ErrMultipleAdSources = errors.New("more than one AdSource in AdBreak")
+
 func DecodeVast(input []byte) (VAST, error) {
 	var vast VAST
 	found := false
+	input, lifted := liftVerbatim(input)
 	f := bytes.NewReader([]byte(input))
 
 	tok := xmltokenizer.New(f, xmltokenizer.WithAttrBufferSize(5))
@@ -43,6 +51,7 @@ func DecodeVast(input []byte) (VAST, error) {
 	if !found {
 		return vast, errors.New("no VAST token found in document")
 	}
+	lifted.restore(&vast)
 	return vast, nil
 }
 
@@ -50,6 +59,7 @@ func DecodeVmap(input []byte) (VMAP, error) {
 	var vmap VMAP
 	found := false
 
+	input, lifted := liftVerbatim(input)
 	f := bytes.NewReader([]byte(input))
 
 	tok := xmltokenizer.New(f, xmltokenizer.WithAttrBufferSize(5))
@@ -87,19 +97,142 @@ func DecodeVmap(input []byte) (VMAP, error) {
 				return vmap, err
 			}
 			vmap.AdBreaks = append(vmap.AdBreaks, adBreak)
+		case "Extension":
+			var ext VMAPExtension
+			// Reuse Token object in the sync.Pool since we only use it temporarily.
+			se := xmltokenizer.GetToken().Copy(token)
+			se.WasCDATA = token.WasCDATA // Copy does not carry the CDATA flag.
+			err = ext.UnmarshalToken(tok, se)
+			xmltokenizer.PutToken(se) // Put back to sync.Pool.
+			if err != nil {
+				return vmap, err
+			}
+			vmap.Extensions = append(vmap.Extensions, ext)
 		}
 	}
 
 	if !found {
 		return vmap, errors.New("no VMAP token found in document")
 	}
+	for i := range vmap.AdBreaks {
+		if as := vmap.AdBreaks[i].AdSource; as != nil && as.VASTData != nil && as.VASTData.VAST != nil {
+			lifted.restore(as.VASTData.VAST)
+		}
+	}
 	return vmap, nil
 }
 
-func (adBreak *AdBreak) UnmarshalToken(tok *xmltokenizer.Tokenizer, se *xmltokenizer.Token) error {
-	adBreak.AdSource = &AdSource{
-		VASTData: &VASTData{},
+// verbatim holds the content of the elements that are kept verbatim, lifted
+// out of a document before it is tokenized. The tokenizer trims the
+// whitespace around character data and tags, and loses track of the tags
+// after a CDATA section split over two, as encoders do for a "]]>" in the
+// content.
+type verbatim struct {
+	contents []string
+}
+
+// verbatimElements are the elements that liftVerbatim lifts.
+var verbatimElements = []string{"AdParameters", "VerificationParameters", "CreativeExtension"}
+
+// liftVerbatim returns input with the content of each of the
+// verbatimElements replaced by its index in the returned contents, which
+// restore puts back into the decoded document. The content is read as
+// DecodeVastScan does, and elements that the decoders keep as raw XML are
+// left alone. Input without such elements is returned as is, with a nil
+// *verbatim.
+func liftVerbatim(input []byte) ([]byte, *verbatim) {
+	if !slices.ContainsFunc(verbatimElements, func(name string) bool {
+		return bytes.Contains(input, []byte(name))
+	}) {
+		return input, nil
+	}
+	v := &verbatim{}
+	var out []byte
+	last := 0
+	s := scan{data: input}
+	for {
+		name, isEnd, selfClose := s.next()
+		if name == nil {
+			break
+		}
+		if isEnd || selfClose {
+			continue
+		}
+		var content string
+		var start, end int
+		switch string(name) {
+		case "Extension", "CustomAdData", "CompanionAds":
+			s.endAttrs()
+			s.rawInner(string(name))
+			continue
+		case "AdParameters", "VerificationParameters":
+			s.endAttrs()
+			start = s.pos
+			content = s.textStr()
+			end = s.pos
+		case "CreativeExtension":
+			s.endAttrs()
+			start = s.pos
+			raw := s.rawInner("CreativeExtension")
+			content, end = byteStr(raw), start+len(raw)
+		default:
+			continue
+		}
+		// The scanned string references input, which the decoders do not keep.
+		v.contents = append(v.contents, strings.Clone(content))
+		out = append(out, input[last:start]...)
+		out = strconv.AppendInt(out, int64(len(v.contents)-1), 10)
+		last = end
+	}
+	if v.contents == nil {
+		return input, nil
+	}
+	return append(out, input[last:]...), v
+}
+
+// restore puts the lifted content back into vast.
+func (v *verbatim) restore(vast *VAST) {
+	if v == nil {
+		return
+	}
+	for i := range vast.Ad {
+		if w := vast.Ad[i].Wrapper; w != nil {
+			v.restoreVerifications(w.AdVerifications)
+		}
+		il := vast.Ad[i].InLine
+		if il == nil {
+			continue
+		}
+		v.restoreVerifications(il.AdVerifications)
+		for j := range il.Creatives {
+			exts := il.Creatives[j].CreativeExtensions
+			for k := range exts {
+				exts[k].InnerXML = v.content(exts[k].InnerXML)
+			}
+			if l := il.Creatives[j].Linear; l != nil && l.AdParameters != nil {
+				l.AdParameters.Value = v.content(l.AdParameters.Value)
+			}
+		}
+	}
+}
+
+func (v *verbatim) restoreVerifications(vs AdVerifications) {
+	for i := range vs {
+		vs[i].VerificationParameters = VerificationParameters(v.content(string(vs[i].VerificationParameters)))
+	}
+}
+
+// content returns the lifted content that index refers to. An element left
+// empty was not lifted, so anything but an index is returned as is.
+func (v *verbatim) content(index string) string {
+	i, err := strconv.Atoi(index)
+	if err != nil || i < 0 || i >= len(v.contents) {
+		return index
 	}
+	return v.contents[i]
+}
+
+func (adBreak *AdBreak) UnmarshalToken(tok *xmltokenizer.Tokenizer, se *xmltokenizer.Token) error {
 	var err error
 	for i := range se.Attrs {
 		attr := &se.Attrs[i]
@@ -107,14 +240,31 @@ func (adBreak *AdBreak) UnmarshalToken(tok *xmltokenizer.Tokenizer, se *xmltoken
 		case "breakId":
 			adBreak.Id = string(attr.Value)
 		case "breakType":
-			adBreak.BreakType = string(attr.Value)
+			adBreak.BreakType = BreakType(attr.Value)
 		case "timeOffset":
 			err = adBreak.TimeOffset.UnmarshalText(attr.Value)
 			if err != nil {
 				return err
 			}
+		case "repeatAfter":
+			var d Duration
+			if err = d.UnmarshalText(attr.Value); err != nil {
+				return err
+			}
+			adBreak.RepeatAfter = &d
+		default:
+			if len(attr.Name.Prefix) > 0 || string(attr.Name.Local) == "xmlns" {
+				continue
+			}
+			if adBreak.ExtraAttrs == nil {
+				adBreak.ExtraAttrs = make(map[string]string)
+			}
+			adBreak.ExtraAttrs[string(attr.Name.Local)] = string(xmlStringToString(attr.Value))
 		}
 	}
+	if se.SelfClosing {
+		return nil
+	}
 
 	for {
 		token, err := tok.Token()
@@ -128,10 +278,17 @@ func (adBreak *AdBreak) UnmarshalToken(tok *xmltokenizer.Tokenizer, se *xmltoken
 			continue
 		}
 		switch string(token.Name.Local) {
+		case "AdSource":
+			if adBreak.AdSource != nil {
+				return fmt.Errorf("error parsing AdBreak %q: %w", adBreak.Id, ErrMultipleAdSources)
+			}
+			adBreak.adSource()
+		case "VASTAdData":
+			adBreak.vastData()
 		case "VAST":
 			var vast VAST
 			if token.SelfClosing {
-				adBreak.AdSource.VASTData.VAST = &VAST{}
+				adBreak.vastData().VAST = &VAST{}
 				break
 			}
 			// Reuse Token object in the sync.Pool since we only use it temporarily.
@@ -141,35 +298,95 @@ func (adBreak *AdBreak) UnmarshalToken(tok *xmltokenizer.Tokenizer, se *xmltoken
 			if err != nil {
 				return err
 			}
-			adBreak.AdSource.VASTData.VAST = &vast
-		case "Tracking":
-			if adBreak.TrackingEvents == nil {
-				adBreak.TrackingEvents = []TrackingEvent{}
-			}
-			var t TrackingEvent
+			adBreak.vastData().VAST = &vast
+		case "AdTagURI":
+			var uri AdTagURI
 			for i := range token.Attrs {
 				attr := &token.Attrs[i]
 				switch string(attr.Name.Local) {
-				case "event":
-					t.Event = string(attr.Value)
+				case "templateType":
+					uri.TemplateType = string(attr.Value)
 				}
 			}
 			if token.WasCDATA {
-				t.Text = string(token.Data)
+				uri.URI = strings.TrimSpace(string(token.Data))
 			} else {
-				t.Text = string(xmlStringToString(token.Data))
+				uri.URI = strings.TrimSpace(string(xmlStringToString(token.Data)))
+			}
+			adBreak.adSource().AdTagURI = &uri
+		case "CustomAdData":
+			var custom CustomAdData
+			for i := range token.Attrs {
+				attr := &token.Attrs[i]
+				switch string(attr.Name.Local) {
+				case "templateType":
+					custom.TemplateType = string(attr.Value)
+				}
+			}
+			if !token.SelfClosing {
+				// Reuse Token object in the sync.Pool since we only use it temporarily.
+				se := xmltokenizer.GetToken().Copy(token)
+				se.WasCDATA = token.WasCDATA // Copy does not carry the CDATA flag.
+				custom.Data, err = innerXML(tok, se)
+				xmltokenizer.PutToken(se) // Put back to sync.Pool.
+				if err != nil {
+					return err
+				}
+			}
+			adBreak.adSource().CustomAdData = &custom
+		case "Tracking":
+			if adBreak.TrackingEvents == nil {
+				adBreak.TrackingEvents = []TrackingEvent{}
+			}
+			t, err := decodeTrackingEvent(&token)
+			if err != nil {
+				return err
 			}
 			adBreak.TrackingEvents = append(adBreak.TrackingEvents, t)
+		case "Extension":
+			var ext VMAPExtension
+			// Reuse Token object in the sync.Pool since we only use it temporarily.
+			se := xmltokenizer.GetToken().Copy(token)
+			se.WasCDATA = token.WasCDATA // Copy does not carry the CDATA flag.
+			err = ext.UnmarshalToken(tok, se)
+			xmltokenizer.PutToken(se) // Put back to sync.Pool.
+			if err != nil {
+				return err
+			}
+			adBreak.Extensions = append(adBreak.Extensions, ext)
 		}
 	}
 }
 
+// adSource returns the AdSource of the break, creating it if needed.
+func (adBreak *AdBreak) adSource() *AdSource {
+	if adBreak.AdSource == nil {
+		adBreak.AdSource = &AdSource{}
+	}
+	return adBreak.AdSource
+}
+
+// vastData returns the VASTAdData of the break, creating it and its AdSource if needed.
+func (adBreak *AdBreak) vastData() *VASTData {
+	as := adBreak.adSource()
+	if as.VASTData == nil {
+		as.VASTData = &VASTData{}
+	}
+	return as.VASTData
+}
+
 func (vast *VAST) UnmarshalToken(tok *xmltokenizer.Tokenizer, se *xmltokenizer.Token) error {
 	for i := range se.Attrs {
 		attr := &se.Attrs[i]
 		switch string(attr.Name.Local) {
 		case "version":
 			vast.Version = string(attr.Value)
+		case "xmlns":
+			vast.Xmlns = string(attr.Value)
+		case "xsi":
+			vast.Xsi = string(attr.Value)
+		case "noNamespaceSchemaLocation":
+			vast.NoNamespaceSchemaLocation = string(attr.Value)
 		}
 	}
 
@@ -195,6 +412,14 @@ func (vast *VAST) UnmarshalToken(tok *xmltokenizer.Tokenizer, se *xmltokenizer.T
 				return err
 			}
 			vast.Ad = append(vast.Ad, ad)
+		case "Error":
+			var er Error
+			if token.WasCDATA {
+				er.Value = string(token.Data)
+			} else {
+				er.Value = string(xmlStringToString(token.Data))
+			}
+			vast.Error = append(vast.Error, er)
 		}
 	}
 }
@@ -204,13 +429,27 @@ func (ad *Ad) UnmarshalToken(tok *xmltokenizer.Tokenizer, se *xmltokenizer.Token
 		attr := &se.Attrs[i]
 		switch string(attr.Name.Local) {
 		case "sequence":
-			seq, err := strconv.Atoi(string(attr.Value))
+			v := strings.TrimSpace(string(attr.Value))
+			if v == "" {
+				continue
+			}
+			seq, err := strconv.Atoi(v)
 			if err != nil {
 				return err
 			}
-			ad.Sequence = seq
+			ad.Sequence = &seq
 		case "id":
 			ad.Id = string(attr.Value)
+		case "seatId":
+			ad.SeatId = string(attr.Value)
+		case "conditionalAd":
+			b, err := parseBool(attr.Value)
+			if err != nil {
+				return err
+			}
+			ad.ConditionalAd = &b
+		case "adType":
+			ad.AdType = string(attr.Value)
 		}
 	}
 	for {
@@ -235,6 +474,16 @@ func (ad *Ad) UnmarshalToken(tok *xmltokenizer.Tokenizer, se *xmltokenizer.Token
 				return err
 			}
 			ad.InLine = &inline
+		case "Wrapper":
+			var wrapper Wrapper
+			// Reuse Token object in the sync.Pool since we only use it temporarily.
+			se := xmltokenizer.GetToken().Copy(token)
+			err = wrapper.UnmarshalToken(tok, se)
+			xmltokenizer.PutToken(se) // Put back to sync.Pool.
+			if err != nil {
+				return err
+			}
+			ad.Wrapper = &wrapper
 		}
 	}
 }
@@ -276,18 +525,104 @@ func (inline *InLine) UnmarshalToken(tok *xmltokenizer.Tokenizer, se *xmltokeniz
 				imp.Text = string(xmlStringToString(token.Data))
 			}
 			inline.Impression = append(inline.Impression, imp)
+		case "Category":
+			var cat Category
+			for i := range token.Attrs {
+				attr := &token.Attrs[i]
+				switch string(attr.Name.Local) {
+				case "authority":
+					cat.Authority = string(attr.Value)
+				}
+			}
+			if token.WasCDATA {
+				cat.Value = string(token.Data)
+			} else {
+				cat.Value = string(xmlStringToString(token.Data))
+			}
+			inline.Categories = append(inline.Categories, cat)
+		case "Pricing":
+			inline.Pricing = decodePricing(&token)
+		case "Survey":
+			var survey Survey
+			for i := range token.Attrs {
+				attr := &token.Attrs[i]
+				switch string(attr.Name.Local) {
+				case "type":
+					survey.Type = string(attr.Value)
+				}
+			}
+			if token.WasCDATA {
+				survey.URI = strings.TrimSpace(string(token.Data))
+			} else {
+				survey.URI = strings.TrimSpace(string(xmlStringToString(token.Data)))
+			}
+			inline.Survey = &survey
+		case "ViewableImpression":
+			var vi ViewableImpression
+			se := xmltokenizer.GetToken().Copy(token)
+			err = vi.UnmarshalToken(tok, se)
+			xmltokenizer.PutToken(se) // Put back to sync.Pool.
+			if err != nil {
+				return err
+			}
+			inline.ViewableImpression = &vi
+		case "Verification":
+			var v Verification
+			se := xmltokenizer.GetToken().Copy(token)
+			err = v.UnmarshalToken(tok, se)
+			xmltokenizer.PutToken(se) // Put back to sync.Pool.
+			if err != nil {
+				return err
+			}
+			inline.AdVerifications = append(inline.AdVerifications, v)
 		case "AdSystem":
+			var as AdSystem
+			for i := range token.Attrs {
+				attr := &token.Attrs[i]
+				switch string(attr.Name.Local) {
+				case "version":
+					as.Version = string(attr.Value)
+				}
+			}
 			if token.WasCDATA {
-				inline.AdSystem = string(token.Data)
+				as.Name = string(token.Data)
 			} else {
-				inline.AdSystem = string(xmlStringToString(token.Data))
+				as.Name = string(xmlStringToString(token.Data))
 			}
+			inline.AdSystem = &as
 		case "AdTitle":
 			if token.WasCDATA {
 				inline.AdTitle = string(token.Data)
 			} else {
 				inline.AdTitle = string(xmlStringToString(token.Data))
 			}
+		case "AdServingId":
+			if token.WasCDATA {
+				inline.AdServingId = string(token.Data)
+			} else {
+				inline.AdServingId = string(xmlStringToString(token.Data))
+			}
+		case "Description":
+			if token.WasCDATA {
+				inline.Description = CDATAText(strings.TrimSpace(string(token.Data)))
+			} else {
+				inline.Description = CDATAText(strings.TrimSpace(string(xmlStringToString(token.Data))))
+			}
+		case "Advertiser":
+			var adv Advertiser
+			for i := range token.Attrs {
+				attr := &token.Attrs[i]
+				switch string(attr.Name.Local) {
+				case "id":
+					adv.Id = string(attr.Value)
+				}
+			}
+			if token.WasCDATA {
+				adv.Name = strings.TrimSpace(string(token.Data))
+			} else {
+				adv.Name = strings.TrimSpace(string(xmlStringToString(token.Data)))
+			}
+			inline.Advertiser = &adv
 		case "Extension":
 			var e Extension
 			// Reuse Token object in the sync.Pool since we only use it temporarily.
@@ -306,23 +641,22 @@ func (inline *InLine) UnmarshalToken(tok *xmltokenizer.Tokenizer, se *xmltokeniz
 			} else {
 				er.Value = string(xmlStringToString(token.Data))
 			}
-			inline.Error = &er
+			inline.Error = append(inline.Error, er)
 		}
 	}
 }
 
-func (c *Creative) UnmarshalToken(tok *xmltokenizer.Tokenizer, se *xmltokenizer.Token) error {
+func (vi *ViewableImpression) UnmarshalToken(tok *xmltokenizer.Tokenizer, se *xmltokenizer.Token) error {
 	for i := range se.Attrs {
 		attr := &se.Attrs[i]
 		switch string(attr.Name.Local) {
 		case "id":
-			c.Id = string(attr.Value)
-		case "adId":
-			c.AdId = string(attr.Value)
-		case "sequence":
-			//TODO
+			vi.Id = string(attr.Value)
 		}
 	}
+	if se.SelfClosing {
+		return nil
+	}
 
 	for {
 		token, err := tok.Token()
@@ -335,86 +669,353 @@ func (c *Creative) UnmarshalToken(tok *xmltokenizer.Tokenizer, se *xmltokenizer.
 		if token.IsEndElement { // Ignore child's EndElements
 			continue
 		}
+		var u string
+		if token.WasCDATA {
+			u = strings.TrimSpace(string(token.Data))
+		} else {
+			u = strings.TrimSpace(string(xmlStringToString(token.Data)))
+		}
+		switch string(token.Name.Local) {
+		case "Viewable":
+			vi.Viewable = append(vi.Viewable, u)
+		case "NotViewable":
+			vi.NotViewable = append(vi.NotViewable, u)
+		case "ViewUndetermined":
+			vi.ViewUndetermined = append(vi.ViewUndetermined, u)
+		}
+	}
+}
+
+func (v *Verification) UnmarshalToken(tok *xmltokenizer.Tokenizer, se *xmltokenizer.Token) error {
+	for i := range se.Attrs {
+		attr := &se.Attrs[i]
+		switch string(attr.Name.Local) {
+		case "vendor":
+			v.Vendor = string(attr.Value)
+		}
+	}
+	if se.SelfClosing {
+		return nil
+	}
 
+	for {
+		token, err := tok.Token()
+		if err != nil {
+			return err
+		}
+		if token.IsEndElementOf(se) { // Reach desired EndElement
+			return nil
+		}
+		if token.IsEndElement { // Ignore child's EndElements
+			continue
+		}
 		switch string(token.Name.Local) {
-		case "UniversalAdId":
-			var uaid UniversalAdId
+		case "JavaScriptResource":
+			var r JavaScriptResource
 			for i := range token.Attrs {
 				attr := &token.Attrs[i]
 				switch string(attr.Name.Local) {
-				case "idRegistry":
-					uaid.IdRegistry = string(attr.Value)
+				case "apiFramework":
+					r.ApiFramework = string(attr.Value)
+				case "browserOptional":
+					b, err := parseBool(attr.Value)
+					if err != nil {
+						return err
+					}
+					r.BrowserOptional = &b
 				}
 			}
 			if token.WasCDATA {
-				uaid.Id = string(token.Data)
+				r.Text = strings.TrimSpace(string(token.Data))
 			} else {
-				uaid.Id = string(xmlStringToString(token.Data))
-			}
-			c.UniversalAdId = &uaid
-		case "Tracking":
-			if c.Linear == nil {
-				c.Linear = &Linear{}
+				r.Text = strings.TrimSpace(string(xmlStringToString(token.Data)))
 			}
-			var t TrackingEvent
+			v.JavaScriptResources = append(v.JavaScriptResources, r)
+		case "ExecutableResource":
+			var r ExecutableResource
 			for i := range token.Attrs {
 				attr := &token.Attrs[i]
 				switch string(attr.Name.Local) {
-				case "event":
-					t.Event = string(attr.Value)
+				case "apiFramework":
+					r.ApiFramework = string(attr.Value)
+				case "type":
+					r.MediaType = string(attr.Value)
 				}
 			}
 			if token.WasCDATA {
-				t.Text = string(token.Data)
+				r.Text = strings.TrimSpace(string(token.Data))
 			} else {
-				t.Text = string(xmlStringToString(token.Data))
+				r.Text = strings.TrimSpace(string(xmlStringToString(token.Data)))
 			}
-			c.Linear.TrackingEvents = append(c.Linear.TrackingEvents, t)
-		case "ClickThrough":
-			c.Linear.ClickThrough = &ClickThrough{}
-			for i := range token.Attrs {
-				attr := &token.Attrs[i]
-				switch string(attr.Name.Local) {
-				case "id":
-					c.Linear.ClickThrough.Id = string(attr.Value)
-				}
+			v.ExecutableResources = append(v.ExecutableResources, r)
+		case "Tracking":
+			t, err := decodeTrackingEvent(&token)
+			if err != nil {
+				return err
 			}
+			v.TrackingEvents = append(v.TrackingEvents, t)
+		case "VerificationParameters":
 			if token.WasCDATA {
-				c.Linear.ClickThrough.Text = string(token.Data)
+				v.VerificationParameters = VerificationParameters(token.Data)
 			} else {
-				c.Linear.ClickThrough.Text = string(xmlStringToString(token.Data))
+				v.VerificationParameters = VerificationParameters(xmlStringToString(token.Data))
 			}
-		case "ClickTracking":
-			if c.Linear == nil {
-				c.Linear = &Linear{}
+		}
+	}
+}
+
+func (ic *Icon) UnmarshalToken(tok *xmltokenizer.Tokenizer, se *xmltokenizer.Token) error {
+	var err error
+	for i := range se.Attrs {
+		attr := &se.Attrs[i]
+		switch string(attr.Name.Local) {
+		case "program":
+			ic.Program = string(attr.Value)
+		case "width":
+			if ic.Width, err = parseInt(attr.Value); err != nil {
+				return err
 			}
-			var ct ClickTracking
+		case "height":
+			if ic.Height, err = parseInt(attr.Value); err != nil {
+				return err
+			}
+		case "xPosition":
+			ic.XPosition = string(attr.Value)
+		case "yPosition":
+			ic.YPosition = string(attr.Value)
+		case "duration":
+			var d Duration
+			if err = d.UnmarshalText(attr.Value); err != nil {
+				return err
+			}
+			ic.Duration = &d
+		case "offset":
+			var to TimeOffset
+			if err = to.UnmarshalText(attr.Value); err != nil {
+				return err
+			}
+			ic.Offset = &to
+		}
+	}
+	if se.SelfClosing {
+		return nil
+	}
+
+	for {
+		token, err := tok.Token()
+		if err != nil {
+			return err
+		}
+		if token.IsEndElementOf(se) { // Reach desired EndElement
+			return nil
+		}
+		if token.IsEndElement { // Ignore child's EndElements
+			continue
+		}
+		switch string(token.Name.Local) {
+		case "StaticResource":
+			var r StaticResource
 			for i := range token.Attrs {
 				attr := &token.Attrs[i]
 				switch string(attr.Name.Local) {
-				case "id":
-					ct.Id = string(attr.Value)
+				case "creativeType":
+					r.CreativeType = string(attr.Value)
 				}
 			}
-			if token.WasCDATA {
-				ct.Text = string(token.Data)
-			} else {
-				ct.Text = string(xmlStringToString(token.Data))
+			r.URL = charData(&token)
+			ic.StaticResources = append(ic.StaticResources, r)
+		case "IFrameResource":
+			ic.IFrameResources = append(ic.IFrameResources, IFrameResource{URL: charData(&token)})
+		case "HTMLResource":
+			ic.HTMLResources = append(ic.HTMLResources, HTMLResource{HTML: charData(&token)})
+		case "IconClicks":
+			if ic.IconClicks == nil {
+				ic.IconClicks = &IconClicks{}
 			}
-			c.Linear.ClickTracking = append(c.Linear.ClickTracking, ct)
-		case "Duration":
-			if c.Linear == nil {
-				c.Linear = &Linear{}
+		case "IconClickThrough":
+			if ic.IconClicks == nil {
+				ic.IconClicks = &IconClicks{}
 			}
-			if token.WasCDATA {
-				err = c.Linear.Duration.UnmarshalText(token.Data)
-			} else {
-				err = c.Linear.Duration.UnmarshalText(xmlStringToString(token.Data))
+			ic.IconClicks.IconClickThrough = charData(&token)
+		case "IconClickTracking":
+			if ic.IconClicks == nil {
+				ic.IconClicks = &IconClicks{}
+			}
+			ic.IconClicks.IconClickTracking = append(ic.IconClicks.IconClickTracking, charData(&token))
+		case "IconViewTracking":
+			ic.IconViewTracking = append(ic.IconViewTracking, charData(&token))
+		}
+	}
+}
+
+func (c *Creative) UnmarshalToken(tok *xmltokenizer.Tokenizer, se *xmltokenizer.Token) error {
+	for i := range se.Attrs {
+		attr := &se.Attrs[i]
+		switch string(attr.Name.Local) {
+		case "id":
+			c.Id = string(attr.Value)
+		case "adId":
+			c.AdId = string(attr.Value)
+		case "sequence":
+			seq, err := parseInt(attr.Value)
+			if err != nil {
+				return err
+			}
+			c.Sequence = seq
+		}
+	}
+
+	for {
+		token, err := tok.Token()
+		if err != nil {
+			return err
+		}
+		if token.IsEndElementOf(se) { // Reach desired EndElement
+			return nil
+		}
+		if token.IsEndElement { // Ignore child's EndElements
+			continue
+		}
+
+		switch string(token.Name.Local) {
+		case "Linear":
+			if c.Linear == nil {
+				c.Linear = &Linear{}
+			}
+			for i := range token.Attrs {
+				attr := &token.Attrs[i]
+				switch string(attr.Name.Local) {
+				case "skipoffset":
+					var to TimeOffset
+					if err = to.UnmarshalText(attr.Value); err != nil {
+						return err
+					}
+					c.Linear.SkipOffset = &to
+				}
+			}
+		case "UniversalAdId":
+			var uaid UniversalAdId
+			for i := range token.Attrs {
+				attr := &token.Attrs[i]
+				switch string(attr.Name.Local) {
+				case "idRegistry":
+					uaid.IdRegistry = string(attr.Value)
+				}
+			}
+			if token.WasCDATA {
+				uaid.Id = string(token.Data)
+			} else {
+				uaid.Id = string(xmlStringToString(token.Data))
+			}
+			c.UniversalAdIds = append(c.UniversalAdIds, uaid)
+		case "CreativeExtension":
+			var ext CreativeExtension
+			for i := range token.Attrs {
+				attr := &token.Attrs[i]
+				switch string(attr.Name.Local) {
+				case "type":
+					ext.ExtensionType = string(attr.Value)
+				}
+			}
+			if !token.SelfClosing {
+				// Reuse Token object in the sync.Pool since we only use it temporarily.
+				se := xmltokenizer.GetToken().Copy(token)
+				se.WasCDATA = token.WasCDATA // Copy does not carry the CDATA flag.
+				ext.InnerXML, err = innerXML(tok, se)
+				xmltokenizer.PutToken(se) // Put back to sync.Pool.
+				if err != nil {
+					return err
+				}
+			}
+			c.CreativeExtensions = append(c.CreativeExtensions, ext)
+		case "Tracking":
+			if c.Linear == nil {
+				c.Linear = &Linear{}
+			}
+			t, err := decodeTrackingEvent(&token)
+			if err != nil {
+				return err
+			}
+			c.Linear.TrackingEvents = append(c.Linear.TrackingEvents, t)
+		case "ClickThrough":
+			c.Linear.ClickThrough = &ClickThrough{}
+			for i := range token.Attrs {
+				attr := &token.Attrs[i]
+				switch string(attr.Name.Local) {
+				case "id":
+					c.Linear.ClickThrough.Id = string(attr.Value)
+				}
+			}
+			if token.WasCDATA {
+				c.Linear.ClickThrough.Text = string(token.Data)
+			} else {
+				c.Linear.ClickThrough.Text = string(xmlStringToString(token.Data))
+			}
+		case "ClickTracking":
+			if c.Linear == nil {
+				c.Linear = &Linear{}
+			}
+			var ct ClickTracking
+			for i := range token.Attrs {
+				attr := &token.Attrs[i]
+				switch string(attr.Name.Local) {
+				case "id":
+					ct.Id = string(attr.Value)
+				}
+			}
+			if token.WasCDATA {
+				ct.Text = string(token.Data)
+			} else {
+				ct.Text = string(xmlStringToString(token.Data))
+			}
+			c.Linear.ClickTracking = append(c.Linear.ClickTracking, ct)
+		case "Duration":
+			if c.Linear == nil {
+				c.Linear = &Linear{}
+			}
+			if token.WasCDATA {
+				err = c.Linear.Duration.UnmarshalText(token.Data)
+			} else {
+				err = c.Linear.Duration.UnmarshalText(xmlStringToString(token.Data))
 			}
 
 			if err != nil {
 				return err
 			}
+		case "Icon":
+			if c.Linear == nil {
+				c.Linear = &Linear{}
+			}
+			var ic Icon
+			se := xmltokenizer.GetToken().Copy(token)
+			err = ic.UnmarshalToken(tok, se)
+			xmltokenizer.PutToken(se) // Put back to sync.Pool.
+			if err != nil {
+				return err
+			}
+			c.Linear.Icons = append(c.Linear.Icons, ic)
+		case "AdParameters":
+			if c.Linear == nil {
+				c.Linear = &Linear{}
+			}
+			ap := &AdParameters{}
+			for i := range token.Attrs {
+				attr := &token.Attrs[i]
+				switch string(attr.Name.Local) {
+				case "xmlEncoded":
+					b, err := parseBool(attr.Value)
+					if err != nil {
+						return err
+					}
+					ap.XMLEncoded = &b
+				}
+			}
+			if token.WasCDATA {
+				ap.Value = string(token.Data)
+			} else {
+				ap.Value = string(xmlStringToString(token.Data))
+			}
+			c.Linear.AdParameters = ap
 		case "MediaFile":
 			if c.Linear == nil {
 				c.Linear = &Linear{}
@@ -424,26 +1025,77 @@ func (c *Creative) UnmarshalToken(tok *xmltokenizer.Tokenizer, se *xmltokenizer.
 				attr := &token.Attrs[i]
 				switch string(attr.Name.Local) {
 				case "bitrate":
-					m.Bitrate, err = strconv.Atoi(string(attr.Value))
+					m.Bitrate, err = parseInt(attr.Value)
+				case "height":
+					m.Height, err = parseInt(attr.Value)
+				case "width":
+					m.Width, err = parseInt(attr.Value)
+				case "delivery":
+					m.Delivery = Delivery(attr.Value)
+				case "type":
+					m.MediaType = string(attr.Value)
+				case "codec":
+					m.Codec = string(attr.Value)
+				case "id":
+					m.Id = string(attr.Value)
+				case "minBitrate":
+					m.MinBitrate, err = parseInt(attr.Value)
+				case "maxBitrate":
+					m.MaxBitrate, err = parseInt(attr.Value)
+				case "scalable":
+					if b, err := parseBool(attr.Value); err == nil {
+						m.Scalable = &b
+					}
+				case "maintainAspectRatio":
+					if b, err := parseBool(attr.Value); err == nil {
+						m.MaintainAspectRatio = &b
+					}
+				case "apiFramework":
+					m.ApiFramework = string(attr.Value)
+				case "fileSize":
+					m.FileSize, err = parseInt(attr.Value)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			if token.WasCDATA {
+				m.Text = string(token.Data)
+			} else {
+				m.Text = string(xmlStringToString(token.Data))
+			}
+			c.Linear.MediaFiles = append(c.Linear.MediaFiles, m)
+		case "Mezzanine":
+			if c.Linear == nil {
+				c.Linear = &Linear{}
+			}
+			var m Mezzanine
+			for i := range token.Attrs {
+				attr := &token.Attrs[i]
+				switch string(attr.Name.Local) {
+				case "id":
+					m.Id = string(attr.Value)
+				case "delivery":
+					m.Delivery = Delivery(attr.Value)
+				case "type":
+					m.MediaType = string(attr.Value)
+				case "width":
+					m.Width, err = parseInt(attr.Value)
 					if err != nil {
 						return err
 					}
 				case "height":
-					m.Height, err = strconv.Atoi(string(attr.Value))
+					m.Height, err = parseInt(attr.Value)
 					if err != nil {
 						return err
 					}
-				case "width":
-					m.Width, err = strconv.Atoi(string(attr.Value))
+				case "codec":
+					m.Codec = string(attr.Value)
+				case "fileSize":
+					m.FileSize, err = parseInt(attr.Value)
 					if err != nil {
 						return err
 					}
-				case "delivery":
-					m.Delivery = string(attr.Value)
-				case "type":
-					m.MediaType = string(attr.Value)
-				case "codec":
-					m.Codec = string(attr.Value)
 				}
 			}
 			if token.WasCDATA {
@@ -451,7 +1103,290 @@ func (c *Creative) UnmarshalToken(tok *xmltokenizer.Tokenizer, se *xmltokenizer.
 			} else {
 				m.Text = string(xmlStringToString(token.Data))
 			}
-			c.Linear.MediaFiles = append(c.Linear.MediaFiles, m)
+			c.Linear.Mezzanine = append(c.Linear.Mezzanine, m)
+		case "InteractiveCreativeFile":
+			if c.Linear == nil {
+				c.Linear = &Linear{}
+			}
+			var f InteractiveCreativeFile
+			for i := range token.Attrs {
+				attr := &token.Attrs[i]
+				switch string(attr.Name.Local) {
+				case "type":
+					f.MediaType = string(attr.Value)
+				case "apiFramework":
+					f.ApiFramework = string(attr.Value)
+				case "variableDuration":
+					if b, err := parseBool(attr.Value); err == nil {
+						f.VariableDuration = &b
+					}
+				}
+			}
+			if token.WasCDATA {
+				f.Text = string(token.Data)
+			} else {
+				f.Text = string(xmlStringToString(token.Data))
+			}
+			c.Linear.InteractiveCreativeFiles = append(c.Linear.InteractiveCreativeFiles, f)
+		}
+	}
+}
+
+func decodeTrackingEvent(token *xmltokenizer.Token) (TrackingEvent, error) {
+	var t TrackingEvent
+	var offset []byte
+	for i := range token.Attrs {
+		attr := &token.Attrs[i]
+		switch string(attr.Name.Local) {
+		case "event":
+			t.Event = string(attr.Value)
+		case "offset":
+			offset = attr.Value
+		}
+	}
+	if offset != nil && t.Event == EventProgress {
+		var to TimeOffset
+		if err := to.UnmarshalText(offset); err != nil {
+			return t, err
+		}
+		t.Offset = &to
+	}
+	if token.WasCDATA {
+		t.Text = string(token.Data)
+	} else {
+		t.Text = string(xmlStringToString(token.Data))
+	}
+	return t, nil
+}
+
+func decodePricing(token *xmltokenizer.Token) *Pricing {
+	var p Pricing
+	for i := range token.Attrs {
+		attr := &token.Attrs[i]
+		switch string(attr.Name.Local) {
+		case "model":
+			p.Model = string(attr.Value)
+		case "currency":
+			p.Currency = string(attr.Value)
+		}
+	}
+	if token.WasCDATA {
+		p.Value = strings.TrimSpace(string(token.Data))
+	} else {
+		p.Value = strings.TrimSpace(string(xmlStringToString(token.Data)))
+	}
+	return &p
+}
+
+func (w *Wrapper) UnmarshalToken(tok *xmltokenizer.Tokenizer, se *xmltokenizer.Token) error {
+	for i := range se.Attrs {
+		attr := &se.Attrs[i]
+		var dst **bool
+		switch string(attr.Name.Local) {
+		case "followAdditionalWrappers":
+			dst = &w.FollowAdditionalWrappers
+		case "allowMultipleAds":
+			dst = &w.AllowMultipleAds
+		case "fallbackOnNoAd":
+			dst = &w.FallbackOnNoAd
+		default:
+			continue
+		}
+		b, err := parseBool(attr.Value)
+		if err != nil {
+			return err
+		}
+		*dst = &b
+	}
+	for {
+		token, err := tok.Token()
+		if err != nil {
+			return err
+		}
+		if token.IsEndElementOf(se) { // Reach desired EndElement
+			return nil
+		}
+		if token.IsEndElement { // Ignore child's EndElements
+			continue
+		}
+		switch string(token.Name.Local) {
+		case "AdSystem":
+			var as AdSystem
+			for i := range token.Attrs {
+				attr := &token.Attrs[i]
+				switch string(attr.Name.Local) {
+				case "version":
+					as.Version = string(attr.Value)
+				}
+			}
+			if token.WasCDATA {
+				as.Name = string(token.Data)
+			} else {
+				as.Name = string(xmlStringToString(token.Data))
+			}
+			w.AdSystem = &as
+		case "VASTAdTagURI":
+			if token.WasCDATA {
+				w.VASTAdTagURI = string(token.Data)
+			} else {
+				w.VASTAdTagURI = string(xmlStringToString(token.Data))
+			}
+		case "Error":
+			var er Error
+			if token.WasCDATA {
+				er.Value = string(token.Data)
+			} else {
+				er.Value = string(xmlStringToString(token.Data))
+			}
+			w.Error = append(w.Error, er)
+		case "Impression":
+			var imp Impression
+			for i := range token.Attrs {
+				attr := &token.Attrs[i]
+				switch string(attr.Name.Local) {
+				case "id":
+					imp.Id = string(attr.Value)
+				}
+			}
+			if token.WasCDATA {
+				imp.Text = string(token.Data)
+			} else {
+				imp.Text = string(xmlStringToString(token.Data))
+			}
+			w.Impression = append(w.Impression, imp)
+		case "BlockedAdCategories":
+			var b BlockedAdCategory
+			for i := range token.Attrs {
+				attr := &token.Attrs[i]
+				switch string(attr.Name.Local) {
+				case "authority":
+					b.Authority = string(attr.Value)
+				}
+			}
+			if token.WasCDATA {
+				b.Value = string(token.Data)
+			} else {
+				b.Value = string(xmlStringToString(token.Data))
+			}
+			w.BlockedAdCategories = append(w.BlockedAdCategories, b)
+		case "Pricing":
+			w.Pricing = decodePricing(&token)
+		case "Verification":
+			var v Verification
+			se := xmltokenizer.GetToken().Copy(token)
+			err = v.UnmarshalToken(tok, se)
+			xmltokenizer.PutToken(se) // Put back to sync.Pool.
+			if err != nil {
+				return err
+			}
+			w.AdVerifications = append(w.AdVerifications, v)
+		case "Creative":
+			var c WrapperCreative
+			// Reuse Token object in the sync.Pool since we only use it temporarily.
+			se := xmltokenizer.GetToken().Copy(token)
+			err = c.UnmarshalToken(tok, se)
+			xmltokenizer.PutToken(se) // Put back to sync.Pool.
+			if err != nil {
+				return err
+			}
+			w.Creatives = append(w.Creatives, c)
+		case "Extension":
+			var e Extension
+			// Reuse Token object in the sync.Pool since we only use it temporarily.
+			se := xmltokenizer.GetToken().Copy(token)
+			err = e.UnmarshalToken(tok, se)
+			xmltokenizer.PutToken(se) // Put back to sync.Pool.
+			if err != nil {
+				return err
+			}
+			w.Extensions = append(w.Extensions, e)
+		}
+	}
+}
+
+func (c *WrapperCreative) UnmarshalToken(tok *xmltokenizer.Tokenizer, se *xmltokenizer.Token) error {
+	for i := range se.Attrs {
+		attr := &se.Attrs[i]
+		switch string(attr.Name.Local) {
+		case "id":
+			c.Id = string(attr.Value)
+		case "adId":
+			c.AdId = string(attr.Value)
+		case "sequence":
+			seq, err := parseInt(attr.Value)
+			if err != nil {
+				return err
+			}
+			c.Sequence = seq
+		}
+	}
+	if se.SelfClosing {
+		return nil
+	}
+
+	for {
+		token, err := tok.Token()
+		if err != nil {
+			return err
+		}
+		if token.IsEndElementOf(se) { // Reach desired EndElement
+			return nil
+		}
+		if token.IsEndElement { // Ignore child's EndElements
+			continue
+		}
+		switch string(token.Name.Local) {
+		case "Linear":
+			if c.Linear == nil {
+				c.Linear = &WrapperLinear{}
+			}
+		case "Tracking":
+			if c.Linear == nil {
+				c.Linear = &WrapperLinear{}
+			}
+			t, err := decodeTrackingEvent(&token)
+			if err != nil {
+				return err
+			}
+			c.Linear.TrackingEvents = append(c.Linear.TrackingEvents, t)
+		case "ClickTracking":
+			if c.Linear == nil {
+				c.Linear = &WrapperLinear{}
+			}
+			var ct ClickTracking
+			for i := range token.Attrs {
+				attr := &token.Attrs[i]
+				switch string(attr.Name.Local) {
+				case "id":
+					ct.Id = string(attr.Value)
+				}
+			}
+			if token.WasCDATA {
+				ct.Text = string(token.Data)
+			} else {
+				ct.Text = string(xmlStringToString(token.Data))
+			}
+			c.Linear.ClickTracking = append(c.Linear.ClickTracking, ct)
+		case "CompanionAds":
+			var ca CompanionAds
+			for i := range token.Attrs {
+				attr := &token.Attrs[i]
+				switch string(attr.Name.Local) {
+				case "required":
+					ca.Required = string(attr.Value)
+				}
+			}
+			if !token.SelfClosing {
+				// Reuse Token object in the sync.Pool since we only use it temporarily.
+				se := xmltokenizer.GetToken().Copy(token)
+				se.WasCDATA = token.WasCDATA // Copy does not carry the CDATA flag.
+				ca.InnerXML, err = innerXML(tok, se)
+				xmltokenizer.PutToken(se) // Put back to sync.Pool.
+				if err != nil {
+					return err
+				}
+			}
+			c.CompanionAds = &ca
 		}
 	}
 }
@@ -500,6 +1435,116 @@ func (ext *Extension) UnmarshalToken(tok *xmltokenizer.Tokenizer, se *xmltokeniz
 	}
 }
 
+func (ext *VMAPExtension) UnmarshalToken(tok *xmltokenizer.Tokenizer, se *xmltokenizer.Token) error {
+	for i := range se.Attrs {
+		attr := &se.Attrs[i]
+		switch string(attr.Name.Local) {
+		case "type":
+			ext.ExtensionType = string(attr.Value)
+		}
+	}
+	if se.SelfClosing {
+		return nil
+	}
+	inner, err := innerXML(tok, se)
+	if err != nil {
+		return err
+	}
+	ext.InnerXML = inner
+	return nil
+}
+
+// parseInt parses an integer attribute like encoding/xml does: surrounding
+// whitespace is ignored and an empty attribute is 0.
+func parseInt(data []byte) (int, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return 0, nil
+	}
+	return strconv.Atoi(string(data))
+}
+
+// parseBool parses a boolean attribute, accepting the forms of strconv.ParseBool
+// such as "true", "TRUE" and "1", surrounded by whitespace.
+func parseBool(data []byte) (bool, error) {
+	b, err := strconv.ParseBool(string(bytes.TrimSpace(data)))
+	if err != nil {
+		return false, fmt.Errorf("error parsing boolean: %w", err)
+	}
+	return b, nil
+}
+
+// innerXML rebuilds the raw content of se from the tokens up to its end element.
+// The tokenizer trims whitespace around tags, so that whitespace is not preserved.
+func innerXML(tok *xmltokenizer.Tokenizer, se *xmltokenizer.Token) (string, error) {
+	buf := appendCharData(nil, se)
+	depth := 0
+	for {
+		token, err := tok.Token()
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case token.IsEndElementOf(se):
+			if depth == 0 {
+				return string(buf), nil
+			}
+			depth--
+		case !token.IsEndElement && !token.SelfClosing && string(token.Name.Full) == string(se.Name.Full):
+			depth++
+		}
+		buf = appendRawToken(buf, &token)
+	}
+}
+
+// appendRawToken appends the XML form of token, including the character data following it.
+func appendRawToken(buf []byte, token *xmltokenizer.Token) []byte {
+	if len(token.Name.Full) == 0 {
+		// Comments, processing instructions and directives are kept as is.
+		return append(buf, token.Data...)
+	}
+	if token.IsEndElement {
+		buf = append(buf, "</"...)
+		buf = append(buf, token.Name.Full...)
+		buf = append(buf, '>')
+		return appendCharData(buf, token)
+	}
+	buf = append(buf, '<')
+	buf = append(buf, token.Name.Full...)
+	for i := range token.Attrs {
+		attr := &token.Attrs[i]
+		buf = append(buf, ' ')
+		buf = append(buf, attr.Name.Full...)
+		buf = append(buf, '=', '"')
+		// Attribute values are still escaped, only a quote from a single quoted value needs care.
+		buf = append(buf, bytes.ReplaceAll(attr.Value, []byte{'"'}, []byte("&#34;"))...)
+		buf = append(buf, '"')
+	}
+	if token.SelfClosing {
+		buf = append(buf, '/')
+	}
+	buf = append(buf, '>')
+	return appendCharData(buf, token)
+}
+
+func appendCharData(buf []byte, token *xmltokenizer.Token) []byte {
+	if token.WasCDATA {
+		buf = append(buf, "<![CDATA["...)
+		buf = append(buf, token.Data...)
+		return append(buf, "]]>"...)
+	}
+	return append(buf, token.Data...)
+}
+
+// charData returns the character data following token, trimmed by the
+// tokenizer, with entities decoded unless it was CDATA.
+func charData(token *xmltokenizer.Token) string {
+	if token.WasCDATA {
+		return string(token.Data)
+	}
+	return string(xmlStringToString(token.Data))
+}
+
 func xmlStringToString(input []byte) []byte {
 	o := 0
 	for i := 0; i < len(input); i++ {
@@ -509,6 +1554,7 @@ func xmlStringToString(input []byte) []byte {
 		//If we see a '&' we have a special character that needs decoding
 		case '&':
 			cb := make([]byte, 0, 4)
+			base := 0 // 0 for a named entity, else the base of a character reference
 		specialCharLoop:
 			for {
 				i++
@@ -517,15 +1563,18 @@ func xmlStringToString(input []byte) []byte {
 				}
 
 				c := input[i]
-				switch c {
-				case '#', 'x':
-				case ';':
+				switch {
+				case c == '#' && base == 0 && len(cb) == 0:
+					base = 10
+				case c == 'x' && base == 10 && len(cb) == 0:
+					base = 16
+				case c == ';':
 					break specialCharLoop
 				default:
 					cb = append(cb, c)
 				}
 			}
-			ch := decodeSpecialCharacterFromHexCode(cb)
+			ch := decodeReference(cb, base)
 			for _, l := range []byte(string(ch)) {
 				input[o] = l
 				o++
@@ -539,7 +1588,15 @@ func xmlStringToString(input []byte) []byte {
 	return input[0:o]
 }
 
-func decodeSpecialCharacterFromHexCode(input []byte) rune {
+// decodeReference returns the character that a reference stands for: the
+// code point in base 10 or 16 for a character reference, or a predefined
+// entity when base is 0. Unknown entities decode to 0.
+func decodeReference(input []byte, base int) rune {
+	if base != 0 {
+		// Handle &#34; and &#x22;
+		codePoint, _ := strconv.ParseInt(string(input), base, 32)
+		return rune(codePoint)
+	}
 	// Handle &amp; &lt; &gt; &apos; &quot;
 	switch string(input) {
 	case "amp":
@@ -553,6 +1610,5 @@ func decodeSpecialCharacterFromHexCode(input []byte) rune {
 	case "quot":
 		return '"'
 	}
-	codePoint, _ := strconv.ParseInt(string(input), 16, 32)
-	return rune(codePoint)
+	return 0
 }
@@ -3,7 +3,10 @@ package vmap
 import (
 	"bytes"
 	"errors"
+	"fmt"
+	"slices"
 	"strconv"
+	"strings"
 	"unsafe"
 )
 
@@ -139,6 +142,43 @@ func (s *scan) attr(name string) []byte {
 	return nil
 }
 
+// extraAttrs returns the attributes of the current start tag whose names are
+// not in known, keyed by name, or nil if there are none. Like encoding/xml
+// and the struct tags, it skips attributes with a namespace prefix and
+// namespace declarations. Values are entity decoded. Does not advance pos.
+func (s *scan) extraAttrs(known ...string) map[string]string {
+	gt := bytes.IndexByte(s.data[s.pos:], '>')
+	if gt < 0 {
+		return nil
+	}
+	region := s.data[s.pos : s.pos+gt]
+	var attrs map[string]string
+	for {
+		eq := bytes.IndexByte(region, '=')
+		if eq < 0 {
+			return attrs
+		}
+		name := bytes.TrimSpace(region[:eq])
+		rest := bytes.TrimLeft(region[eq+1:], " \t\r\n")
+		if len(rest) == 0 || (rest[0] != '"' && rest[0] != '\'') {
+			return attrs
+		}
+		end := bytes.IndexByte(rest[1:], rest[0])
+		if end < 0 {
+			return attrs
+		}
+		value := rest[1 : 1+end]
+		region = rest[2+end:]
+		if bytes.IndexByte(name, ':') >= 0 || string(name) == "xmlns" || slices.Contains(known, string(name)) {
+			continue
+		}
+		if attrs == nil {
+			attrs = make(map[string]string)
+		}
+		attrs[string(name)] = decodeXMLStr(value)
+	}
+}
+
 // endAttrs advances past the '>' of the current start tag.
 func (s *scan) endAttrs() {
 	j := bytes.IndexByte(s.data[s.pos:], '>')
@@ -147,6 +187,33 @@ func (s *scan) endAttrs() {
 	}
 }
 
+// rawInner returns the raw content of the current element up to its matching
+// end tag and advances past that end tag. Must be called after endAttrs().
+func (s *scan) rawInner(name string) []byte {
+	start := s.pos
+	depth := 0
+	for {
+		tag, isEnd, selfClose := s.next()
+		if tag == nil {
+			return s.data[start:]
+		}
+		if string(tag) != name {
+			continue
+		}
+		if !isEnd {
+			if !selfClose {
+				depth++
+			}
+			continue
+		}
+		if depth > 0 {
+			depth--
+			continue
+		}
+		return s.data[start:bytes.LastIndexByte(s.data[:s.pos], '<')]
+	}
+}
+
 // text extracts text or CDATA content from the current position until the
 // next '<'. Returns the raw bytes and whether it was CDATA.
 func (s *scan) text() (content []byte, wasCDATA bool) {
@@ -169,7 +236,18 @@ func (s *scan) text() (content []byte, wasCDATA bool) {
 			return nil, false
 		}
 		s.pos = start + end + len(cdataClose)
-		return s.data[start : start+end], true
+		content = s.data[start : start+end]
+		// Encoders split a "]]>" in the content over adjacent CDATA sections.
+		for bytes.HasPrefix(s.data[s.pos:], []byte(cdataOpen)) {
+			start = s.pos + len(cdataOpen)
+			end = bytes.Index(s.data[start:], []byte(cdataClose))
+			if end < 0 {
+				break
+			}
+			content = append(content[:len(content):len(content)], s.data[start:start+end]...)
+			s.pos = start + end + len(cdataClose)
+		}
+		return content, true
 	}
 
 	i := bytes.IndexByte(s.data[s.pos:], '<')
@@ -196,9 +274,17 @@ func (s *scan) textStr() string {
 	return decodeXMLStr(content)
 }
 
+// urlStr extracts text content like textStr, trimming the whitespace that
+// pretty printed documents leave around URLs, also inside CDATA.
+func (s *scan) urlStr() string {
+	return strings.TrimSpace(s.textStr())
+}
+
 // --- Top-level decoders ---
 
 // DecodeVmapScan decodes a VMAP document using direct byte scanning.
+// Like xml.Unmarshal it is lenient: malformed attributes are skipped. An
+// AdBreak with more than one AdSource is rejected with ErrMultipleAdSources.
 // String fields in the returned struct may reference the input slice;
 // the input must not be modified while the result is in use.
 func DecodeVmapScan(input []byte) (VMAP, error) {
@@ -207,7 +293,7 @@ func DecodeVmapScan(input []byte) (VMAP, error) {
 	found := false
 
 	for {
-		name, isEnd, _ := s.next()
+		name, isEnd, selfClose := s.next()
 		if name == nil {
 			break
 		}
@@ -228,7 +314,13 @@ func DecodeVmapScan(input []byte) (VMAP, error) {
 			vmap.XMLName.Local = "VMAP"
 			s.endAttrs()
 		case "AdBreak":
-			vmap.AdBreaks = append(vmap.AdBreaks, scanAdBreak(&s))
+			ab, err := scanAdBreak(&s)
+			if err != nil {
+				return vmap, err
+			}
+			vmap.AdBreaks = append(vmap.AdBreaks, ab)
+		case "Extension":
+			vmap.Extensions = append(vmap.Extensions, scanVMAPExtension(&s, selfClose))
 		}
 	}
 
@@ -269,19 +361,25 @@ func DecodeVastScan(input []byte) (VAST, error) {
 
 // --- Per-element scanners ---
 
-func scanAdBreak(s *scan) AdBreak {
+func scanAdBreak(s *scan) (AdBreak, error) {
 	var ab AdBreak
-	ab.AdSource = &AdSource{VASTData: &VASTData{}}
 
 	if v := s.attr("breakId"); v != nil {
 		ab.Id = byteStr(v)
 	}
 	if v := s.attr("breakType"); v != nil {
-		ab.BreakType = byteStr(v)
+		ab.BreakType = BreakType(byteStr(v))
 	}
 	if v := s.attr("timeOffset"); v != nil {
 		_ = ab.TimeOffset.UnmarshalText(v)
 	}
+	if v := s.attr("repeatAfter"); v != nil {
+		var d Duration
+		if d.UnmarshalText(v) == nil {
+			ab.RepeatAfter = &d
+		}
+	}
+	ab.ExtraAttrs = s.extraAttrs(adBreakAttrs...)
 	s.endAttrs()
 
 	for {
@@ -296,27 +394,61 @@ func scanAdBreak(s *scan) AdBreak {
 			continue
 		}
 		switch string(name) {
+		case "AdSource":
+			if ab.AdSource != nil {
+				return ab, fmt.Errorf("error parsing AdBreak %q: %w", ab.Id, ErrMultipleAdSources)
+			}
+			ab.adSource()
+		case "VASTAdData":
+			ab.vastData()
 		case "VAST":
 			if selfClose {
-				ab.AdSource.VASTData.VAST = &VAST{}
+				ab.vastData().VAST = &VAST{}
 				continue
 			}
 			vast := scanVast(s)
-			ab.AdSource.VASTData.VAST = &vast
+			ab.vastData().VAST = &vast
+		case "AdTagURI":
+			var uri AdTagURI
+			if v := s.attr("templateType"); v != nil {
+				uri.TemplateType = byteStr(v)
+			}
+			s.endAttrs()
+			uri.URI = s.urlStr()
+			ab.adSource().AdTagURI = &uri
+		case "CustomAdData":
+			var custom CustomAdData
+			if v := s.attr("templateType"); v != nil {
+				custom.TemplateType = byteStr(v)
+			}
+			s.endAttrs()
+			if !selfClose {
+				custom.Data = byteStr(s.rawInner("CustomAdData"))
+			}
+			ab.adSource().CustomAdData = &custom
 		case "Tracking":
 			if ab.TrackingEvents == nil {
 				ab.TrackingEvents = []TrackingEvent{}
 			}
-			var t TrackingEvent
-			if v := s.attr("event"); v != nil {
-				t.Event = byteStr(v)
-			}
-			s.endAttrs()
-			t.Text = s.textStr()
+			t := scanTrackingEvent(s)
 			ab.TrackingEvents = append(ab.TrackingEvents, t)
+		case "Extension":
+			ab.Extensions = append(ab.Extensions, scanVMAPExtension(s, selfClose))
 		}
 	}
-	return ab
+	return ab, nil
+}
+
+func scanVMAPExtension(s *scan, selfClose bool) VMAPExtension {
+	var ext VMAPExtension
+	if v := s.attr("type"); v != nil {
+		ext.ExtensionType = byteStr(v)
+	}
+	s.endAttrs()
+	if !selfClose {
+		ext.InnerXML = byteStr(s.rawInner("Extension"))
+	}
+	return ext
 }
 
 func scanVast(s *scan) VAST {
@@ -324,6 +456,15 @@ func scanVast(s *scan) VAST {
 	if v := s.attr("version"); v != nil {
 		vast.Version = byteStr(v)
 	}
+	if v := s.attr("xmlns"); v != nil {
+		vast.Xmlns = byteStr(v)
+	}
+	if v := s.attr("xsi"); v != nil {
+		vast.Xsi = byteStr(v)
+	}
+	if v := s.attr("noNamespaceSchemaLocation"); v != nil {
+		vast.NoNamespaceSchemaLocation = byteStr(v)
+	}
 	s.endAttrs()
 
 	for {
@@ -337,8 +478,12 @@ func scanVast(s *scan) VAST {
 			}
 			continue
 		}
-		if string(name) == "Ad" {
+		switch string(name) {
+		case "Ad":
 			vast.Ad = append(vast.Ad, scanAd(s))
+		case "Error":
+			s.endAttrs()
+			vast.Error = append(vast.Error, Error{Value: s.urlStr()})
 		}
 	}
 	return vast
@@ -350,7 +495,20 @@ func scanAd(s *scan) Ad {
 		ad.Id = byteStr(v)
 	}
 	if v := s.attr("sequence"); v != nil {
-		ad.Sequence, _ = strconv.Atoi(byteStr(v))
+		if seq, err := strconv.Atoi(strings.TrimSpace(byteStr(v))); err == nil {
+			ad.Sequence = &seq
+		}
+	}
+	if v := s.attr("seatId"); v != nil {
+		ad.SeatId = byteStr(v)
+	}
+	if v := s.attr("conditionalAd"); v != nil {
+		if b, err := parseBool(v); err == nil {
+			ad.ConditionalAd = &b
+		}
+	}
+	if v := s.attr("adType"); v != nil {
+		ad.AdType = byteStr(v)
 	}
 	s.endAttrs()
 
@@ -365,9 +523,13 @@ func scanAd(s *scan) Ad {
 			}
 			continue
 		}
-		if string(name) == "InLine" {
+		switch string(name) {
+		case "InLine":
 			inline := scanInLine(s)
 			ad.InLine = &inline
+		case "Wrapper":
+			wrapper := scanWrapper(s)
+			ad.Wrapper = &wrapper
 		}
 	}
 	return ad
@@ -378,7 +540,7 @@ func scanInLine(s *scan) InLine {
 	s.endAttrs()
 
 	for {
-		name, isEnd, _ := s.next()
+		name, isEnd, selfClose := s.next()
 		if name == nil {
 			break
 		}
@@ -397,24 +559,410 @@ func scanInLine(s *scan) InLine {
 				imp.Id = byteStr(v)
 			}
 			s.endAttrs()
-			imp.Text = s.textStr()
+			imp.Text = s.urlStr()
 			inline.Impression = append(inline.Impression, imp)
+		case "Category":
+			var cat Category
+			if v := s.attr("authority"); v != nil {
+				cat.Authority = byteStr(v)
+			}
+			s.endAttrs()
+			cat.Value = s.textStr()
+			inline.Categories = append(inline.Categories, cat)
+		case "Pricing":
+			inline.Pricing = scanPricing(s)
+		case "Survey":
+			var survey Survey
+			if v := s.attr("type"); v != nil {
+				survey.Type = byteStr(v)
+			}
+			s.endAttrs()
+			survey.URI = s.urlStr()
+			inline.Survey = &survey
+		case "ViewableImpression":
+			vi := scanViewableImpression(s, selfClose)
+			inline.ViewableImpression = &vi
+		case "Verification":
+			inline.AdVerifications = append(inline.AdVerifications, scanVerification(s, selfClose))
 		case "AdSystem":
+			var as AdSystem
+			if v := s.attr("version"); v != nil {
+				as.Version = byteStr(v)
+			}
 			s.endAttrs()
-			inline.AdSystem = s.textStr()
+			as.Name = s.textStr()
+			inline.AdSystem = &as
 		case "AdTitle":
 			s.endAttrs()
 			inline.AdTitle = s.textStr()
+		case "AdServingId":
+			s.endAttrs()
+			inline.AdServingId = s.textStr()
+		case "Description":
+			s.endAttrs()
+			inline.Description = CDATAText(strings.TrimSpace(s.textStr()))
+		case "Advertiser":
+			var adv Advertiser
+			if v := s.attr("id"); v != nil {
+				adv.Id = byteStr(v)
+			}
+			s.endAttrs()
+			adv.Name = strings.TrimSpace(s.textStr())
+			inline.Advertiser = &adv
 		case "Extension":
 			inline.Extensions = append(inline.Extensions, scanExtension(s))
 		case "Error":
 			s.endAttrs()
-			inline.Error = &Error{Value: s.textStr()}
+			inline.Error = append(inline.Error, Error{Value: s.urlStr()})
 		}
 	}
 	return inline
 }
 
+func scanViewableImpression(s *scan, selfClose bool) ViewableImpression {
+	var vi ViewableImpression
+	if v := s.attr("id"); v != nil {
+		vi.Id = byteStr(v)
+	}
+	s.endAttrs()
+	if selfClose {
+		return vi
+	}
+
+	for {
+		name, isEnd, _ := s.next()
+		if name == nil {
+			break
+		}
+		if isEnd {
+			if string(name) == "ViewableImpression" {
+				break
+			}
+			continue
+		}
+		switch string(name) {
+		case "Viewable":
+			s.endAttrs()
+			vi.Viewable = append(vi.Viewable, s.urlStr())
+		case "NotViewable":
+			s.endAttrs()
+			vi.NotViewable = append(vi.NotViewable, s.urlStr())
+		case "ViewUndetermined":
+			s.endAttrs()
+			vi.ViewUndetermined = append(vi.ViewUndetermined, s.urlStr())
+		}
+	}
+	return vi
+}
+
+func scanVerification(s *scan, selfClose bool) Verification {
+	var v Verification
+	if a := s.attr("vendor"); a != nil {
+		v.Vendor = byteStr(a)
+	}
+	s.endAttrs()
+	if selfClose {
+		return v
+	}
+
+	for {
+		name, isEnd, _ := s.next()
+		if name == nil {
+			break
+		}
+		if isEnd {
+			if string(name) == "Verification" {
+				break
+			}
+			continue
+		}
+		switch string(name) {
+		case "JavaScriptResource":
+			var r JavaScriptResource
+			if a := s.attr("apiFramework"); a != nil {
+				r.ApiFramework = byteStr(a)
+			}
+			if a := s.attr("browserOptional"); a != nil {
+				if b, err := parseBool(a); err == nil {
+					r.BrowserOptional = &b
+				}
+			}
+			s.endAttrs()
+			r.Text = s.urlStr()
+			v.JavaScriptResources = append(v.JavaScriptResources, r)
+		case "ExecutableResource":
+			var r ExecutableResource
+			if a := s.attr("apiFramework"); a != nil {
+				r.ApiFramework = byteStr(a)
+			}
+			if a := s.attr("type"); a != nil {
+				r.MediaType = byteStr(a)
+			}
+			s.endAttrs()
+			r.Text = s.urlStr()
+			v.ExecutableResources = append(v.ExecutableResources, r)
+		case "Tracking":
+			v.TrackingEvents = append(v.TrackingEvents, scanTrackingEvent(s))
+		case "VerificationParameters":
+			s.endAttrs()
+			v.VerificationParameters = VerificationParameters(s.textStr())
+		}
+	}
+	return v
+}
+
+func scanIcon(s *scan, selfClose bool) Icon {
+	var ic Icon
+	if v := s.attr("program"); v != nil {
+		ic.Program = byteStr(v)
+	}
+	if v := s.attr("width"); v != nil {
+		ic.Width, _ = strconv.Atoi(byteStr(v))
+	}
+	if v := s.attr("height"); v != nil {
+		ic.Height, _ = strconv.Atoi(byteStr(v))
+	}
+	if v := s.attr("xPosition"); v != nil {
+		ic.XPosition = byteStr(v)
+	}
+	if v := s.attr("yPosition"); v != nil {
+		ic.YPosition = byteStr(v)
+	}
+	if v := s.attr("duration"); v != nil {
+		var d Duration
+		if d.UnmarshalText(v) == nil {
+			ic.Duration = &d
+		}
+	}
+	if v := s.attr("offset"); v != nil {
+		var to TimeOffset
+		if to.UnmarshalText(v) == nil {
+			ic.Offset = &to
+		}
+	}
+	s.endAttrs()
+	if selfClose {
+		return ic
+	}
+
+	for {
+		name, isEnd, _ := s.next()
+		if name == nil {
+			break
+		}
+		if isEnd {
+			if string(name) == "Icon" {
+				break
+			}
+			continue
+		}
+		switch string(name) {
+		case "StaticResource":
+			var r StaticResource
+			if v := s.attr("creativeType"); v != nil {
+				r.CreativeType = byteStr(v)
+			}
+			s.endAttrs()
+			r.URL = s.urlStr()
+			ic.StaticResources = append(ic.StaticResources, r)
+		case "IFrameResource":
+			s.endAttrs()
+			ic.IFrameResources = append(ic.IFrameResources, IFrameResource{URL: s.urlStr()})
+		case "HTMLResource":
+			s.endAttrs()
+			ic.HTMLResources = append(ic.HTMLResources, HTMLResource{HTML: s.textStr()})
+		case "IconClicks":
+			s.endAttrs()
+			if ic.IconClicks == nil {
+				ic.IconClicks = &IconClicks{}
+			}
+		case "IconClickThrough":
+			s.endAttrs()
+			if ic.IconClicks == nil {
+				ic.IconClicks = &IconClicks{}
+			}
+			ic.IconClicks.IconClickThrough = s.urlStr()
+		case "IconClickTracking":
+			s.endAttrs()
+			if ic.IconClicks == nil {
+				ic.IconClicks = &IconClicks{}
+			}
+			ic.IconClicks.IconClickTracking = append(ic.IconClicks.IconClickTracking, s.urlStr())
+		case "IconViewTracking":
+			s.endAttrs()
+			ic.IconViewTracking = append(ic.IconViewTracking, s.urlStr())
+		}
+	}
+	return ic
+}
+
+func scanWrapper(s *scan) Wrapper {
+	var w Wrapper
+	for _, a := range []struct {
+		name string
+		dst  **bool
+	}{
+		{"followAdditionalWrappers", &w.FollowAdditionalWrappers},
+		{"allowMultipleAds", &w.AllowMultipleAds},
+		{"fallbackOnNoAd", &w.FallbackOnNoAd},
+	} {
+		if v := s.attr(a.name); v != nil {
+			if b, err := parseBool(v); err == nil {
+				*a.dst = &b
+			}
+		}
+	}
+	s.endAttrs()
+
+	for {
+		name, isEnd, selfClose := s.next()
+		if name == nil {
+			break
+		}
+		if isEnd {
+			if string(name) == "Wrapper" {
+				break
+			}
+			continue
+		}
+		switch string(name) {
+		case "AdSystem":
+			var as AdSystem
+			if v := s.attr("version"); v != nil {
+				as.Version = byteStr(v)
+			}
+			s.endAttrs()
+			as.Name = s.textStr()
+			w.AdSystem = &as
+		case "VASTAdTagURI":
+			s.endAttrs()
+			w.VASTAdTagURI = s.urlStr()
+		case "Error":
+			s.endAttrs()
+			w.Error = append(w.Error, Error{Value: s.urlStr()})
+		case "Impression":
+			var imp Impression
+			if v := s.attr("id"); v != nil {
+				imp.Id = byteStr(v)
+			}
+			s.endAttrs()
+			imp.Text = s.urlStr()
+			w.Impression = append(w.Impression, imp)
+		case "BlockedAdCategories":
+			var b BlockedAdCategory
+			if v := s.attr("authority"); v != nil {
+				b.Authority = byteStr(v)
+			}
+			s.endAttrs()
+			b.Value = s.textStr()
+			w.BlockedAdCategories = append(w.BlockedAdCategories, b)
+		case "Pricing":
+			w.Pricing = scanPricing(s)
+		case "Verification":
+			w.AdVerifications = append(w.AdVerifications, scanVerification(s, selfClose))
+		case "Creative":
+			w.Creatives = append(w.Creatives, scanWrapperCreative(s, selfClose))
+		case "Extension":
+			w.Extensions = append(w.Extensions, scanExtension(s))
+		}
+	}
+	return w
+}
+
+func scanTrackingEvent(s *scan) TrackingEvent {
+	var t TrackingEvent
+	if v := s.attr("event"); v != nil {
+		t.Event = byteStr(v)
+	}
+	if v := s.attr("offset"); v != nil && t.Event == EventProgress {
+		var to TimeOffset
+		if to.UnmarshalText(v) == nil {
+			t.Offset = &to
+		}
+	}
+	s.endAttrs()
+	t.Text = s.urlStr()
+	return t
+}
+
+func scanPricing(s *scan) *Pricing {
+	var p Pricing
+	if v := s.attr("model"); v != nil {
+		p.Model = byteStr(v)
+	}
+	if v := s.attr("currency"); v != nil {
+		p.Currency = byteStr(v)
+	}
+	s.endAttrs()
+	p.Value = strings.TrimSpace(s.textStr())
+	return &p
+}
+
+func scanWrapperCreative(s *scan, selfClose bool) WrapperCreative {
+	var c WrapperCreative
+	if v := s.attr("id"); v != nil {
+		c.Id = byteStr(v)
+	}
+	if v := s.attr("adId"); v != nil {
+		c.AdId = byteStr(v)
+	}
+	if v := s.attr("sequence"); v != nil {
+		c.Sequence, _ = strconv.Atoi(byteStr(v))
+	}
+	s.endAttrs()
+	if selfClose {
+		return c
+	}
+
+	for {
+		name, isEnd, selfClose := s.next()
+		if name == nil {
+			break
+		}
+		if isEnd {
+			if string(name) == "Creative" {
+				break
+			}
+			continue
+		}
+		switch string(name) {
+		case "Linear":
+			if c.Linear == nil {
+				c.Linear = &WrapperLinear{}
+			}
+			s.endAttrs()
+		case "Tracking":
+			if c.Linear == nil {
+				c.Linear = &WrapperLinear{}
+			}
+			t := scanTrackingEvent(s)
+			c.Linear.TrackingEvents = append(c.Linear.TrackingEvents, t)
+		case "ClickTracking":
+			if c.Linear == nil {
+				c.Linear = &WrapperLinear{}
+			}
+			var ct ClickTracking
+			if v := s.attr("id"); v != nil {
+				ct.Id = byteStr(v)
+			}
+			s.endAttrs()
+			ct.Text = s.urlStr()
+			c.Linear.ClickTracking = append(c.Linear.ClickTracking, ct)
+		case "CompanionAds":
+			var ca CompanionAds
+			if v := s.attr("required"); v != nil {
+				ca.Required = byteStr(v)
+			}
+			s.endAttrs()
+			if !selfClose {
+				ca.InnerXML = byteStr(s.rawInner("CompanionAds"))
+			}
+			c.CompanionAds = &ca
+		}
+	}
+	return c
+}
+
 func scanCreative(s *scan) Creative {
 	var c Creative
 	if v := s.attr("id"); v != nil {
@@ -423,10 +971,13 @@ func scanCreative(s *scan) Creative {
 	if v := s.attr("adId"); v != nil {
 		c.AdId = byteStr(v)
 	}
+	if v := s.attr("sequence"); v != nil {
+		c.Sequence, _ = strconv.Atoi(byteStr(v))
+	}
 	s.endAttrs()
 
 	for {
-		name, isEnd, _ := s.next()
+		name, isEnd, selfClose := s.next()
 		if name == nil {
 			break
 		}
@@ -437,6 +988,35 @@ func scanCreative(s *scan) Creative {
 			continue
 		}
 		switch string(name) {
+		case "Linear":
+			if c.Linear == nil {
+				c.Linear = &Linear{}
+			}
+			if v := s.attr("skipoffset"); v != nil {
+				var to TimeOffset
+				if to.UnmarshalText(v) == nil {
+					c.Linear.SkipOffset = &to
+				}
+			}
+			s.endAttrs()
+		case "Icon":
+			if c.Linear == nil {
+				c.Linear = &Linear{}
+			}
+			c.Linear.Icons = append(c.Linear.Icons, scanIcon(s, selfClose))
+		case "AdParameters":
+			if c.Linear == nil {
+				c.Linear = &Linear{}
+			}
+			ap := &AdParameters{}
+			if v := s.attr("xmlEncoded"); v != nil {
+				if b, err := parseBool(v); err == nil {
+					ap.XMLEncoded = &b
+				}
+			}
+			s.endAttrs()
+			ap.Value = s.textStr()
+			c.Linear.AdParameters = ap
 		case "UniversalAdId":
 			var uaid UniversalAdId
 			if v := s.attr("idRegistry"); v != nil {
@@ -444,17 +1024,22 @@ func scanCreative(s *scan) Creative {
 			}
 			s.endAttrs()
 			uaid.Id = s.textStr()
-			c.UniversalAdId = &uaid
+			c.UniversalAdIds = append(c.UniversalAdIds, uaid)
+		case "CreativeExtension":
+			var ext CreativeExtension
+			if v := s.attr("type"); v != nil {
+				ext.ExtensionType = byteStr(v)
+			}
+			s.endAttrs()
+			if !selfClose {
+				ext.InnerXML = byteStr(s.rawInner("CreativeExtension"))
+			}
+			c.CreativeExtensions = append(c.CreativeExtensions, ext)
 		case "Tracking":
 			if c.Linear == nil {
 				c.Linear = &Linear{}
 			}
-			var t TrackingEvent
-			if v := s.attr("event"); v != nil {
-				t.Event = byteStr(v)
-			}
-			s.endAttrs()
-			t.Text = s.textStr()
+			t := scanTrackingEvent(s)
 			c.Linear.TrackingEvents = append(c.Linear.TrackingEvents, t)
 		case "ClickThrough":
 			if c.Linear == nil {
@@ -465,7 +1050,7 @@ func scanCreative(s *scan) Creative {
 				c.Linear.ClickThrough.Id = byteStr(v)
 			}
 			s.endAttrs()
-			c.Linear.ClickThrough.Text = s.textStr()
+			c.Linear.ClickThrough.Text = s.urlStr()
 		case "ClickTracking":
 			if c.Linear == nil {
 				c.Linear = &Linear{}
@@ -475,7 +1060,7 @@ func scanCreative(s *scan) Creative {
 				ct.Id = byteStr(v)
 			}
 			s.endAttrs()
-			ct.Text = s.textStr()
+			ct.Text = s.urlStr()
 			c.Linear.ClickTracking = append(c.Linear.ClickTracking, ct)
 		case "Duration":
 			if c.Linear == nil {
@@ -497,27 +1082,100 @@ func scanCreative(s *scan) Creative {
 				c.Linear = &Linear{}
 			}
 			var m MediaFile
-			if v := s.attr("bitrate"); v != nil {
-				m.Bitrate, _ = strconv.Atoi(byteStr(v))
+			for _, a := range []struct {
+				name string
+				dst  *int
+			}{
+				{"bitrate", &m.Bitrate},
+				{"height", &m.Height},
+				{"width", &m.Width},
+				{"minBitrate", &m.MinBitrate},
+				{"maxBitrate", &m.MaxBitrate},
+				{"fileSize", &m.FileSize},
+			} {
+				if v := s.attr(a.name); v != nil {
+					*a.dst, _ = parseInt(v)
+				}
 			}
-			if v := s.attr("height"); v != nil {
-				m.Height, _ = strconv.Atoi(byteStr(v))
+			if v := s.attr("delivery"); v != nil {
+				m.Delivery = Delivery(byteStr(v))
 			}
-			if v := s.attr("width"); v != nil {
-				m.Width, _ = strconv.Atoi(byteStr(v))
+			if v := s.attr("type"); v != nil {
+				m.MediaType = byteStr(v)
+			}
+			if v := s.attr("codec"); v != nil {
+				m.Codec = byteStr(v)
+			}
+			if v := s.attr("id"); v != nil {
+				m.Id = byteStr(v)
+			}
+			if v := s.attr("apiFramework"); v != nil {
+				m.ApiFramework = byteStr(v)
+			}
+			for _, a := range []struct {
+				name string
+				dst  **bool
+			}{
+				{"scalable", &m.Scalable},
+				{"maintainAspectRatio", &m.MaintainAspectRatio},
+			} {
+				if v := s.attr(a.name); v != nil {
+					if b, err := parseBool(v); err == nil {
+						*a.dst = &b
+					}
+				}
+			}
+			s.endAttrs()
+			m.Text = s.urlStr()
+			c.Linear.MediaFiles = append(c.Linear.MediaFiles, m)
+		case "Mezzanine":
+			if c.Linear == nil {
+				c.Linear = &Linear{}
+			}
+			var m Mezzanine
+			if v := s.attr("id"); v != nil {
+				m.Id = byteStr(v)
 			}
 			if v := s.attr("delivery"); v != nil {
-				m.Delivery = byteStr(v)
+				m.Delivery = Delivery(byteStr(v))
 			}
 			if v := s.attr("type"); v != nil {
 				m.MediaType = byteStr(v)
 			}
+			if v := s.attr("width"); v != nil {
+				m.Width, _ = strconv.Atoi(byteStr(v))
+			}
+			if v := s.attr("height"); v != nil {
+				m.Height, _ = strconv.Atoi(byteStr(v))
+			}
 			if v := s.attr("codec"); v != nil {
 				m.Codec = byteStr(v)
 			}
+			if v := s.attr("fileSize"); v != nil {
+				m.FileSize, _ = strconv.Atoi(byteStr(v))
+			}
 			s.endAttrs()
-			m.Text = s.textStr()
-			c.Linear.MediaFiles = append(c.Linear.MediaFiles, m)
+			m.Text = s.urlStr()
+			c.Linear.Mezzanine = append(c.Linear.Mezzanine, m)
+		case "InteractiveCreativeFile":
+			if c.Linear == nil {
+				c.Linear = &Linear{}
+			}
+			var f InteractiveCreativeFile
+			if v := s.attr("type"); v != nil {
+				f.MediaType = byteStr(v)
+			}
+			if v := s.attr("apiFramework"); v != nil {
+				f.ApiFramework = byteStr(v)
+			}
+			if v := s.attr("variableDuration"); v != nil {
+				if b, err := parseBool(v); err == nil {
+					f.VariableDuration = &b
+				}
+			}
+			s.endAttrs()
+			f.Text = s.urlStr()
+			c.Linear.InteractiveCreativeFiles = append(c.Linear.InteractiveCreativeFiles, f)
 		}
 	}
 	return c
@@ -0,0 +1,227 @@
+package vmap
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ChangeKind says how a value differs between two documents.
+type ChangeKind string
+
+const (
+	ChangeAdded    ChangeKind = "added"
+	ChangeRemoved  ChangeKind = "removed"
+	ChangeModified ChangeKind = "modified"
+)
+
+// Change is a difference between two VMAP documents found by Diff.
+type Change struct {
+	Kind ChangeKind
+	// Path locates the value, e.g. "AdBreak[midroll-1].TimeOffset". Ad
+	// breaks, ads and creatives are identified by their id, or by their
+	// index if they have none; other repeated elements by their index.
+	Path string
+	// Old and New hold the value before and after the change as text. They
+	// are empty for an added or removed element with children.
+	Old, New string
+}
+
+// String describes the change, e.g.
+// `modified AdBreak[midroll-1].TimeOffset: "00:05:00" -> "00:06:00"`.
+func (c Change) String() string {
+	switch {
+	case c.Kind == ChangeModified:
+		return fmt.Sprintf("%s %s: %q -> %q", c.Kind, c.Path, c.Old, c.New)
+	case c.Kind == ChangeAdded && c.New != "":
+		return fmt.Sprintf("%s %s: %q", c.Kind, c.Path, c.New)
+	case c.Kind == ChangeRemoved && c.Old != "":
+		return fmt.Sprintf("%s %s: %q", c.Kind, c.Path, c.Old)
+	}
+	return string(c.Kind) + " " + c.Path
+}
+
+// Diff returns the changes that turn a into b, in document order: the
+// changes within the elements of a first, then the elements only found in
+// b. Values are compared as by Equal. A nil document is treated as empty.
+func Diff(a, b *VMAP) []Change {
+	if a == nil {
+		a = &VMAP{}
+	}
+	if b == nil {
+		b = &VMAP{}
+	}
+	var d differ
+	d.diff("", reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem())
+	return d.changes
+}
+
+type differ struct {
+	changes []Change
+}
+
+var textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+
+func (d *differ) diff(path string, a, b reflect.Value) {
+	if isLeaf(a.Type()) {
+		if !deepEqual(a, b) {
+			d.changes = append(d.changes, Change{Kind: ChangeModified, Path: path, Old: leafText(a), New: leafText(b)})
+		}
+		return
+	}
+	switch a.Kind() {
+	case reflect.Pointer:
+		switch {
+		case a.IsNil() && b.IsNil():
+		case a.IsNil():
+			d.added(path, b.Elem())
+		case b.IsNil():
+			d.removed(path, a.Elem())
+		default:
+			d.diff(path, a.Elem(), b.Elem())
+		}
+	case reflect.Struct:
+		t := a.Type()
+		for i := range t.NumField() {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name := fieldPath(f)
+			if f.Type.Kind() == reflect.Slice {
+				d.diffSlice(path, name, a.Field(i), b.Field(i))
+			} else {
+				d.diff(joinPath(path, name), a.Field(i), b.Field(i))
+			}
+		}
+	default:
+		if !deepEqual(a, b) {
+			d.changes = append(d.changes, Change{
+				Kind: ChangeModified,
+				Path: path,
+				Old:  fmt.Sprint(a.Interface()),
+				New:  fmt.Sprint(b.Interface()),
+			})
+		}
+	}
+}
+
+// diffSlice compares the elements of the slices a and b, found under parent
+// as name. Elements with the same path, see elemPaths, are compared with each
+// other.
+func (d *differ) diffSlice(parent, name string, a, b reflect.Value) {
+	aKeys, bKeys := elemPaths(name, a), elemPaths(name, b)
+	inB := make(map[string]int, len(bKeys))
+	for j, key := range bKeys {
+		inB[key] = j
+	}
+	inA := make(map[string]bool, len(aKeys))
+	for i, key := range aKeys {
+		inA[key] = true
+		if j, ok := inB[key]; ok {
+			d.diff(joinPath(parent, key), a.Index(i), b.Index(j))
+		} else {
+			d.removed(joinPath(parent, key), a.Index(i))
+		}
+	}
+	for j, key := range bKeys {
+		if !inA[key] {
+			d.added(joinPath(parent, key), b.Index(j))
+		}
+	}
+}
+
+func (d *differ) added(path string, v reflect.Value) {
+	c := Change{Kind: ChangeAdded, Path: path}
+	if isLeaf(v.Type()) {
+		c.New = leafText(v)
+	}
+	d.changes = append(d.changes, c)
+}
+
+func (d *differ) removed(path string, v reflect.Value) {
+	c := Change{Kind: ChangeRemoved, Path: path}
+	if isLeaf(v.Type()) {
+		c.Old = leafText(v)
+	}
+	d.changes = append(d.changes, c)
+}
+
+// elemPaths returns the path of each element of the slice s, named name.
+// Ad breaks, ads and creatives are keyed by id, unless the ids in s are not
+// unique, and other elements by index.
+func elemPaths(name string, s reflect.Value) []string {
+	paths := make([]string, s.Len())
+	seen := make(map[string]bool, s.Len())
+	for i := range paths {
+		var p string
+		switch e := s.Index(i).Addr().Interface().(type) {
+		case *AdBreak:
+			p = adBreakPath(e, i)
+		case *Ad:
+			p = adPath(e, i)
+		case *Creative:
+			p = creativePath(e.Id, i)
+		case *WrapperCreative:
+			p = creativePath(e.Id, i)
+		default:
+			p = name + "[" + strconv.Itoa(i) + "]"
+		}
+		if seen[p] {
+			for i := range paths {
+				paths[i] = name + "[" + strconv.Itoa(i) + "]"
+			}
+			return paths
+		}
+		seen[p] = true
+		paths[i] = p
+	}
+	return paths
+}
+
+// fieldPath returns the name of a struct field in a path: the XML element
+// name for elements, e.g. "Creative" for "Creatives>Creative", and the Go
+// field name for attributes and text.
+func fieldPath(f reflect.StructField) string {
+	tag, opts, _ := strings.Cut(f.Tag.Get("xml"), ",")
+	if tag == "" || tag == "-" || strings.Contains(opts, "attr") {
+		return f.Name
+	}
+	if i := strings.LastIndexByte(tag, '>'); i >= 0 {
+		tag = tag[i+1:]
+	}
+	return tag
+}
+
+func joinPath(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+// isLeaf reports whether values of t are compared as a whole. Pointers are
+// not, so that a nil pointer is reported as an added or removed value.
+func isLeaf(t reflect.Type) bool {
+	if t.Kind() != reflect.Pointer && t.Implements(textMarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// leafText formats a leaf value as it appears in a document.
+func leafText(v reflect.Value) string {
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		if text, err := m.MarshalText(); err == nil {
+			return string(text)
+		}
+	}
+	return fmt.Sprint(v.Interface())
+}
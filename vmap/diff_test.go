@@ -0,0 +1,64 @@
+package vmap
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestDiff(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVmap.xml")
+	is.NoErr(err)
+	a, err := DecodeVmap(doc)
+	is.NoErr(err)
+
+	b := a.Clone()
+	is.Equal(len(Diff(&a, b)), 0)
+
+	b.AdBreaks[1].TimeOffset = TimeOffset{Duration: &Duration{6 * time.Minute}}
+	b.AdBreaks = append(b.AdBreaks[:2], AdBreak{
+		Id:         "postroll",
+		BreakType:  BreakTypeLinear,
+		TimeOffset: TimeOffset{Position: -2},
+	})
+	b.AdBreaks[0].RepeatAfter = &Duration{10 * time.Minute}
+
+	changes := Diff(&a, b)
+	is.Equal(changes, []Change{
+		{Kind: ChangeAdded, Path: "AdBreak[midroll.ad-1].RepeatAfter", New: "00:10:00"},
+		{Kind: ChangeModified, Path: "AdBreak[midroll.ad-2].TimeOffset", Old: "00:05:00", New: "00:06:00"},
+		{Kind: ChangeRemoved, Path: "AdBreak[midroll.ad-3]"},
+		{Kind: ChangeAdded, Path: "AdBreak[postroll]"},
+	})
+	is.Equal(changes[1].String(),
+		`modified AdBreak[midroll.ad-2].TimeOffset: "00:05:00" -> "00:06:00"`)
+	is.Equal(changes[2].String(), "removed AdBreak[midroll.ad-3]")
+	is.Equal(Diff(&a, b), changes)
+
+	is.Equal(len(Diff(nil, nil)), 0)
+	is.Equal(Diff(nil, &VMAP{AdBreaks: []AdBreak{{Id: "pre"}}}), []Change{{Kind: ChangeAdded, Path: "AdBreak[pre]"}})
+}
+
+func TestDiffNestedElements(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVmap.xml")
+	is.NoErr(err)
+	a, err := DecodeVmap(doc)
+	is.NoErr(err)
+
+	b := a.Clone()
+	ad := &b.AdBreaks[0].AdSource.VASTData.VAST.Ad[0]
+	ad.InLine.AdTitle = "Changed title"
+	ad.InLine.Impression = ad.InLine.Impression[:0]
+
+	changes := Diff(&a, b)
+	is.Equal(len(changes), 1+len(a.AdBreaks[0].AdSource.VASTData.VAST.Ad[0].InLine.Impression))
+	is.Equal(changes[0].Kind, ChangeModified)
+	is.Equal(changes[0].Path, "AdBreak[midroll.ad-1].AdSource.VASTAdData.VAST.Ad["+ad.Id+"].InLine.AdTitle")
+	is.Equal(changes[0].New, "Changed title")
+	is.Equal(changes[1].Path, "AdBreak[midroll.ad-1].AdSource.VASTAdData.VAST.Ad["+ad.Id+"].InLine.Impression[0]")
+	is.Equal(changes[1].Kind, ChangeRemoved)
+}
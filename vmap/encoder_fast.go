@@ -2,19 +2,47 @@ package vmap
 
 import (
 	"strconv"
+	"strings"
 )
 
+// MarshalOptions controls the output of MarshalVmapWithOptions and MarshalVastWithOptions.
+type MarshalOptions struct {
+	// CDATA wraps the character data of URL bearing elements, such as Impression,
+	// Tracking, ClickThrough and MediaFile, in <![CDATA[...]]> instead of escaping it.
+	// Some VAST consumers reject URLs with escaped ampersands.
+	CDATA bool
+}
+
+// encoder holds the options of a single marshal call.
+type encoder struct {
+	opts MarshalOptions
+}
+
 // MarshalVmap marshals a VMAP to XML, producing output identical to encoding/xml.Marshal.
 func MarshalVmap(v *VMAP) ([]byte, error) {
+	return MarshalVmapWithOptions(v, MarshalOptions{})
+}
+
+// MarshalVmapWithOptions marshals a VMAP to XML. With the zero MarshalOptions
+// the output is identical to encoding/xml.Marshal.
+func MarshalVmapWithOptions(v *VMAP, opts MarshalOptions) ([]byte, error) {
+	e := encoder{opts: opts}
 	buf := make([]byte, 0, 8192)
-	buf = appendVMAP(buf, v)
+	buf = e.appendVMAP(buf, v)
 	return buf, nil
 }
 
 // MarshalVast marshals a VAST to XML, producing output identical to encoding/xml.Marshal.
 func MarshalVast(v *VAST) ([]byte, error) {
+	return MarshalVastWithOptions(v, MarshalOptions{})
+}
+
+// MarshalVastWithOptions marshals a VAST to XML. With the zero MarshalOptions
+// the output is identical to encoding/xml.Marshal.
+func MarshalVastWithOptions(v *VAST, opts MarshalOptions) ([]byte, error) {
+	e := encoder{opts: opts}
 	buf := make([]byte, 0, 4096)
-	buf = appendVAST(buf, v)
+	buf = e.appendVAST(buf, v)
 	return buf, nil
 }
 
@@ -80,6 +108,26 @@ func escAttr(buf []byte, s string) []byte {
 	return append(buf, s[last:]...)
 }
 
+// appendCDATA writes s as a CDATA section, matching encoding/xml's ",cdata" output.
+// Nothing is written for an empty string, and "]]>" inside s is split across sections.
+func appendCDATA(buf []byte, s string) []byte {
+	if len(s) == 0 {
+		return buf
+	}
+	buf = append(buf, "<![CDATA["...)
+	for {
+		i := strings.Index(s, "]]>")
+		if i < 0 {
+			break
+		}
+		buf = append(buf, s[:i]...)
+		buf = append(buf, "]]]]><![CDATA[>"...)
+		s = s[i+3:]
+	}
+	buf = append(buf, s...)
+	return append(buf, "]]>"...)
+}
+
 // --- duration / time offset helpers (allocation-free) ---
 
 func append2dig(buf []byte, n int) []byte {
@@ -115,7 +163,13 @@ func appendTimeOffset(buf []byte, to TimeOffset) []byte {
 	if to.Duration != nil {
 		return appendDuration(buf, *to.Duration)
 	}
-	if to.Position != 0 {
+	switch to.Position {
+	case 0:
+	case OffsetStart:
+		return append(buf, "start"...)
+	case OffsetEnd:
+		return append(buf, "end"...)
+	default:
 		buf = append(buf, '#')
 		return strconv.AppendInt(buf, int64(to.Position), 10)
 	}
@@ -126,10 +180,40 @@ func appendTimeOffset(buf []byte, to TimeOffset) []byte {
 	return buf
 }
 
+// appendURL appends the character data of a URL bearing element, as CDATA if
+// the CDATA option is set.
+func (e *encoder) appendURL(buf []byte, s string) []byte {
+	if e.opts.CDATA {
+		return appendCDATA(buf, s)
+	}
+	return escText(buf, s)
+}
+
+// appendBoolAttr appends prefix, the value and a closing quote if b is set.
+func appendBoolAttr(buf []byte, prefix string, b *bool) []byte {
+	if b == nil {
+		return buf
+	}
+	buf = append(buf, prefix...)
+	buf = strconv.AppendBool(buf, *b)
+	return append(buf, '"')
+}
+
+// appendIntAttr appends prefix, the value and a closing quote if n is not
+// zero, like an omitempty attribute.
+func appendIntAttr(buf []byte, prefix string, n int) []byte {
+	if n == 0 {
+		return buf
+	}
+	buf = append(buf, prefix...)
+	buf = strconv.AppendInt(buf, int64(n), 10)
+	return append(buf, '"')
+}
+
 // --- struct encoders ---
 // Field and attribute order matches encoding/xml.Marshal exactly.
 
-func appendVMAP(buf []byte, v *VMAP) []byte {
+func (e *encoder) appendVMAP(buf []byte, v *VMAP) []byte {
 	// XMLName tag is xml:"VMAP" (name only) — xml.Marshal does not output xmlns
 	buf = append(buf, `<VMAP vmap="`...)
 	buf = escAttr(buf, v.Vmap)
@@ -141,52 +225,115 @@ func appendVMAP(buf []byte, v *VMAP) []byte {
 	buf = escText(buf, v.Text)
 
 	for i := range v.AdBreaks {
-		buf = appendAdBreak(buf, &v.AdBreaks[i])
+		buf = e.appendAdBreak(buf, &v.AdBreaks[i])
 	}
+	buf = e.appendVMAPExtensions(buf, v.Extensions)
 	buf = append(buf, "</VMAP>"...)
 	return buf
 }
 
-func appendAdBreak(buf []byte, ab *AdBreak) []byte {
-	// attrs: breakId, breakType, timeOffset
+// appendVMAPExtensions writes the Extensions element, which like
+// xml.Marshal is omitted when there are no extensions.
+func (e *encoder) appendVMAPExtensions(buf []byte, exts VMAPExtensions) []byte {
+	if len(exts) == 0 {
+		return buf
+	}
+	buf = append(buf, "<Extensions>"...)
+	for i := range exts {
+		buf = e.appendVMAPExtension(buf, &exts[i])
+	}
+	return append(buf, "</Extensions>"...)
+}
+
+func (e *encoder) appendVMAPExtension(buf []byte, ext *VMAPExtension) []byte {
+	buf = append(buf, "<Extension"...)
+	if ext.ExtensionType != "" {
+		buf = append(buf, ` type="`...)
+		buf = escAttr(buf, ext.ExtensionType)
+		buf = append(buf, '"')
+	}
+	buf = append(buf, '>')
+	// innerxml is written verbatim
+	buf = append(buf, ext.InnerXML...)
+	buf = append(buf, "</Extension>"...)
+	return buf
+}
+
+func (e *encoder) appendAdBreak(buf []byte, ab *AdBreak) []byte {
+	// attrs: breakId, breakType, timeOffset, repeatAfter, then ExtraAttrs sorted by name
 	buf = append(buf, `<AdBreak breakId="`...)
 	buf = escAttr(buf, ab.Id)
 	buf = append(buf, `" breakType="`...)
-	buf = escAttr(buf, ab.BreakType)
+	buf = escAttr(buf, string(ab.BreakType))
 	buf = append(buf, `" timeOffset="`...)
 	buf = appendTimeOffset(buf, ab.TimeOffset)
-	buf = append(buf, '"', '>')
+	buf = append(buf, '"')
+	if ab.RepeatAfter != nil {
+		buf = append(buf, ` repeatAfter="`...)
+		buf = appendDuration(buf, *ab.RepeatAfter)
+		buf = append(buf, '"')
+	}
+	for _, name := range ab.extraAttrNames() {
+		buf = append(buf, ' ')
+		buf = append(buf, name...)
+		buf = append(buf, `="`...)
+		buf = escAttr(buf, ab.ExtraAttrs[name])
+		buf = append(buf, '"')
+	}
+	buf = append(buf, '>')
 
-	// child elements in field order: AdSource, TrackingEvents
+	// child elements in field order: AdSource, TrackingEvents, Extensions
 	if ab.AdSource != nil {
-		buf = appendAdSource(buf, ab.AdSource)
+		buf = e.appendAdSource(buf, ab.AdSource)
 	}
 	// Wrapper always emitted for nested path xml:"TrackingEvents>Tracking"
 	buf = append(buf, "<TrackingEvents>"...)
 	for i := range ab.TrackingEvents {
-		buf = appendTracking(buf, &ab.TrackingEvents[i])
+		buf = e.appendTracking(buf, &ab.TrackingEvents[i])
 	}
 	buf = append(buf, "</TrackingEvents>"...)
+	buf = e.appendVMAPExtensions(buf, ab.Extensions)
 	buf = append(buf, "</AdBreak>"...)
 	return buf
 }
 
-func appendAdSource(buf []byte, as *AdSource) []byte {
+func (e *encoder) appendAdSource(buf []byte, as *AdSource) []byte {
 	buf = append(buf, "<AdSource>"...)
 	if as.VASTData != nil {
 		buf = append(buf, "<VASTAdData>"...)
 		if as.VASTData.VAST != nil {
-			buf = appendVAST(buf, as.VASTData.VAST)
+			buf = e.appendVAST(buf, as.VASTData.VAST)
 		}
 		buf = append(buf, "</VASTAdData>"...)
 	}
+	if as.AdTagURI != nil {
+		buf = append(buf, `<AdTagURI templateType="`...)
+		buf = escAttr(buf, as.AdTagURI.TemplateType)
+		buf = append(buf, '"', '>')
+		buf = appendCDATA(buf, as.AdTagURI.URI)
+		buf = append(buf, "</AdTagURI>"...)
+	}
+	if as.CustomAdData != nil {
+		buf = append(buf, `<CustomAdData templateType="`...)
+		buf = escAttr(buf, as.CustomAdData.TemplateType)
+		buf = append(buf, '"', '>')
+		// innerxml is written verbatim
+		buf = append(buf, as.CustomAdData.Data...)
+		buf = append(buf, "</CustomAdData>"...)
+	}
 	buf = append(buf, "</AdSource>"...)
 	return buf
 }
 
-func appendVAST(buf []byte, v *VAST) []byte {
-	// attrs: xsi, noNamespaceSchemaLocation, version
-	buf = append(buf, `<VAST xsi="`...)
+func (e *encoder) appendVAST(buf []byte, v *VAST) []byte {
+	// attrs: xmlns (omitempty), xsi, noNamespaceSchemaLocation, version
+	buf = append(buf, "<VAST"...)
+	if v.Xmlns != "" {
+		buf = append(buf, ` xmlns="`...)
+		buf = escAttr(buf, v.Xmlns)
+		buf = append(buf, '"')
+	}
+	buf = append(buf, ` xsi="`...)
 	buf = escAttr(buf, v.Xsi)
 	buf = append(buf, `" noNamespaceSchemaLocation="`...)
 	buf = escAttr(buf, v.NoNamespaceSchemaLocation)
@@ -198,58 +345,142 @@ func appendVAST(buf []byte, v *VAST) []byte {
 	buf = escText(buf, v.Text)
 
 	for i := range v.Ad {
-		buf = appendAd(buf, &v.Ad[i])
+		buf = e.appendAd(buf, &v.Ad[i])
+	}
+	for i := range v.Error {
+		buf = append(buf, "<Error>"...)
+		buf = e.appendURL(buf, v.Error[i].Value)
+		buf = append(buf, "</Error>"...)
 	}
 	buf = append(buf, "</VAST>"...)
 	return buf
 }
 
-func appendAd(buf []byte, ad *Ad) []byte {
+func (e *encoder) appendAd(buf []byte, ad *Ad) []byte {
 	buf = append(buf, `<Ad id="`...)
 	buf = escAttr(buf, ad.Id)
-	buf = append(buf, `" sequence="`...)
-	buf = strconv.AppendInt(buf, int64(ad.Sequence), 10)
-	buf = append(buf, '"', '>')
+	buf = append(buf, '"')
+	if ad.Sequence != nil {
+		buf = append(buf, ` sequence="`...)
+		buf = strconv.AppendInt(buf, int64(*ad.Sequence), 10)
+		buf = append(buf, '"')
+	}
+	if ad.SeatId != "" {
+		buf = append(buf, ` seatId="`...)
+		buf = escAttr(buf, ad.SeatId)
+		buf = append(buf, '"')
+	}
+	buf = appendBoolAttr(buf, ` conditionalAd="`, ad.ConditionalAd)
+	if ad.AdType != "" {
+		buf = append(buf, ` adType="`...)
+		buf = escAttr(buf, ad.AdType)
+		buf = append(buf, '"')
+	}
+	buf = append(buf, '>')
 
 	if ad.InLine != nil {
-		buf = appendInLine(buf, ad.InLine)
+		buf = e.appendInLine(buf, ad.InLine)
+	}
+	if ad.Wrapper != nil {
+		buf = e.appendWrapper(buf, ad.Wrapper)
 	}
 	buf = append(buf, "</Ad>"...)
 	return buf
 }
 
-func appendInLine(buf []byte, il *InLine) []byte {
+func (e *encoder) appendInLine(buf []byte, il *InLine) []byte {
 	buf = append(buf, "<InLine>"...)
 
-	// field order: AdSystem, AdTitle, Impression, Creatives, Extensions, Error
-	buf = append(buf, "<AdSystem>"...)
-	buf = escText(buf, il.AdSystem)
-	buf = append(buf, "</AdSystem>"...)
+	// field order: AdSystem, AdTitle, AdServingId, Impression, Categories, Description, Advertiser, Pricing,
+	// Survey, ViewableImpression, AdVerifications, Creatives, Extensions, Error
+	if il.AdSystem != nil {
+		buf = append(buf, "<AdSystem"...)
+		if il.AdSystem.Version != "" {
+			buf = append(buf, ` version="`...)
+			buf = escAttr(buf, il.AdSystem.Version)
+			buf = append(buf, '"')
+		}
+		buf = append(buf, '>')
+		buf = escText(buf, il.AdSystem.Name)
+		buf = append(buf, "</AdSystem>"...)
+	}
 
 	buf = append(buf, "<AdTitle>"...)
 	buf = escText(buf, il.AdTitle)
 	buf = append(buf, "</AdTitle>"...)
+	if il.AdServingId != "" {
+		buf = append(buf, "<AdServingId>"...)
+		buf = escText(buf, il.AdServingId)
+		buf = append(buf, "</AdServingId>"...)
+	}
 
 	for i := range il.Impression {
-		buf = appendImpression(buf, &il.Impression[i])
+		buf = e.appendImpression(buf, &il.Impression[i])
+	}
+
+	for i := range il.Categories {
+		buf = append(buf, `<Category authority="`...)
+		buf = escAttr(buf, il.Categories[i].Authority)
+		buf = append(buf, '"', '>')
+		buf = escText(buf, il.Categories[i].Value)
+		buf = append(buf, "</Category>"...)
+	}
+
+	if il.Description != "" {
+		buf = append(buf, "<Description>"...)
+		buf = appendCDATA(buf, string(il.Description))
+		buf = append(buf, "</Description>"...)
+	}
+	if il.Advertiser != nil {
+		buf = append(buf, "<Advertiser"...)
+		if il.Advertiser.Id != "" {
+			buf = append(buf, ` id="`...)
+			buf = escAttr(buf, il.Advertiser.Id)
+			buf = append(buf, '"')
+		}
+		buf = append(buf, '>')
+		buf = escText(buf, il.Advertiser.Name)
+		buf = append(buf, "</Advertiser>"...)
+	}
+
+	if il.Pricing != nil {
+		buf = appendPricing(buf, il.Pricing)
+	}
+
+	if il.Survey != nil {
+		buf = append(buf, "<Survey"...)
+		if il.Survey.Type != "" {
+			buf = append(buf, ` type="`...)
+			buf = escAttr(buf, il.Survey.Type)
+			buf = append(buf, '"')
+		}
+		buf = append(buf, '>')
+		buf = appendCDATA(buf, il.Survey.URI)
+		buf = append(buf, "</Survey>"...)
 	}
 
+	if il.ViewableImpression != nil {
+		buf = appendViewableImpression(buf, il.ViewableImpression)
+	}
+
+	buf = e.appendAdVerifications(buf, il.AdVerifications)
+
 	// Wrappers always emitted for nested paths
 	buf = append(buf, "<Creatives>"...)
 	for i := range il.Creatives {
-		buf = appendCreative(buf, &il.Creatives[i])
+		buf = e.appendCreative(buf, &il.Creatives[i])
 	}
 	buf = append(buf, "</Creatives>"...)
 
 	buf = append(buf, "<Extensions>"...)
 	for i := range il.Extensions {
-		buf = appendExtension(buf, &il.Extensions[i])
+		buf = e.appendExtension(buf, &il.Extensions[i])
 	}
 	buf = append(buf, "</Extensions>"...)
 
-	if il.Error != nil {
+	for i := range il.Error {
 		buf = append(buf, "<Error>"...)
-		buf = escText(buf, il.Error.Value)
+		buf = e.appendURL(buf, il.Error[i].Value)
 		buf = append(buf, "</Error>"...)
 	}
 
@@ -257,56 +488,331 @@ func appendInLine(buf []byte, il *InLine) []byte {
 	return buf
 }
 
-func appendImpression(buf []byte, imp *Impression) []byte {
+func (e *encoder) appendWrapper(buf []byte, w *Wrapper) []byte {
+	// attrs: followAdditionalWrappers, allowMultipleAds, fallbackOnNoAd
+	buf = append(buf, "<Wrapper"...)
+	buf = appendBoolAttr(buf, ` followAdditionalWrappers="`, w.FollowAdditionalWrappers)
+	buf = appendBoolAttr(buf, ` allowMultipleAds="`, w.AllowMultipleAds)
+	buf = appendBoolAttr(buf, ` fallbackOnNoAd="`, w.FallbackOnNoAd)
+	buf = append(buf, '>')
+
+	// field order: AdSystem, VASTAdTagURI, Error, Impression, BlockedAdCategories, Pricing, AdVerifications,
+	// Creatives, Extensions
+	if w.AdSystem != nil {
+		buf = append(buf, "<AdSystem"...)
+		if w.AdSystem.Version != "" {
+			buf = append(buf, ` version="`...)
+			buf = escAttr(buf, w.AdSystem.Version)
+			buf = append(buf, '"')
+		}
+		buf = append(buf, '>')
+		buf = escText(buf, w.AdSystem.Name)
+		buf = append(buf, "</AdSystem>"...)
+	}
+
+	buf = append(buf, "<VASTAdTagURI>"...)
+	buf = e.appendURL(buf, w.VASTAdTagURI)
+	buf = append(buf, "</VASTAdTagURI>"...)
+
+	for i := range w.Error {
+		buf = append(buf, "<Error>"...)
+		buf = e.appendURL(buf, w.Error[i].Value)
+		buf = append(buf, "</Error>"...)
+	}
+
+	for i := range w.Impression {
+		buf = e.appendImpression(buf, &w.Impression[i])
+	}
+
+	for i := range w.BlockedAdCategories {
+		buf = append(buf, `<BlockedAdCategories authority="`...)
+		buf = escAttr(buf, w.BlockedAdCategories[i].Authority)
+		buf = append(buf, '"', '>')
+		buf = escText(buf, w.BlockedAdCategories[i].Value)
+		buf = append(buf, "</BlockedAdCategories>"...)
+	}
+
+	if w.Pricing != nil {
+		buf = appendPricing(buf, w.Pricing)
+	}
+
+	buf = e.appendAdVerifications(buf, w.AdVerifications)
+
+	// Wrappers always emitted for nested paths
+	buf = append(buf, "<Creatives>"...)
+	for i := range w.Creatives {
+		buf = e.appendWrapperCreative(buf, &w.Creatives[i])
+	}
+	buf = append(buf, "</Creatives>"...)
+
+	buf = append(buf, "<Extensions>"...)
+	for i := range w.Extensions {
+		buf = e.appendExtension(buf, &w.Extensions[i])
+	}
+	buf = append(buf, "</Extensions>"...)
+
+	buf = append(buf, "</Wrapper>"...)
+	return buf
+}
+
+func appendPricing(buf []byte, p *Pricing) []byte {
+	buf = append(buf, `<Pricing model="`...)
+	buf = escAttr(buf, p.Model)
+	buf = append(buf, `" currency="`...)
+	buf = escAttr(buf, p.Currency)
+	buf = append(buf, '"', '>')
+	buf = escText(buf, p.Value)
+	buf = append(buf, "</Pricing>"...)
+	return buf
+}
+
+func appendViewableImpression(buf []byte, vi *ViewableImpression) []byte {
+	buf = append(buf, "<ViewableImpression"...)
+	if vi.Id != "" {
+		buf = append(buf, ` id="`...)
+		buf = escAttr(buf, vi.Id)
+		buf = append(buf, '"')
+	}
+	buf = append(buf, '>')
+	for _, u := range vi.Viewable {
+		buf = append(buf, "<Viewable>"...)
+		buf = escText(buf, u)
+		buf = append(buf, "</Viewable>"...)
+	}
+	for _, u := range vi.NotViewable {
+		buf = append(buf, "<NotViewable>"...)
+		buf = escText(buf, u)
+		buf = append(buf, "</NotViewable>"...)
+	}
+	for _, u := range vi.ViewUndetermined {
+		buf = append(buf, "<ViewUndetermined>"...)
+		buf = escText(buf, u)
+		buf = append(buf, "</ViewUndetermined>"...)
+	}
+	buf = append(buf, "</ViewableImpression>"...)
+	return buf
+}
+
+// appendAdVerifications writes the AdVerifications element, which is left out
+// when there are no verifications.
+func (e *encoder) appendAdVerifications(buf []byte, vs []Verification) []byte {
+	if len(vs) == 0 {
+		return buf
+	}
+	buf = append(buf, "<AdVerifications>"...)
+	for i := range vs {
+		buf = e.appendVerification(buf, &vs[i])
+	}
+	return append(buf, "</AdVerifications>"...)
+}
+
+func (e *encoder) appendVerification(buf []byte, v *Verification) []byte {
+	buf = append(buf, "<Verification"...)
+	if v.Vendor != "" {
+		buf = append(buf, ` vendor="`...)
+		buf = escAttr(buf, v.Vendor)
+		buf = append(buf, '"')
+	}
+	buf = append(buf, '>')
+
+	// field order: JavaScriptResource, ExecutableResource, TrackingEvents, VerificationParameters
+	for i := range v.JavaScriptResources {
+		r := &v.JavaScriptResources[i]
+		buf = append(buf, "<JavaScriptResource"...)
+		if r.ApiFramework != "" {
+			buf = append(buf, ` apiFramework="`...)
+			buf = escAttr(buf, r.ApiFramework)
+			buf = append(buf, '"')
+		}
+		buf = appendBoolAttr(buf, ` browserOptional="`, r.BrowserOptional)
+		buf = append(buf, '>')
+		buf = e.appendURL(buf, r.Text)
+		buf = append(buf, "</JavaScriptResource>"...)
+	}
+	for i := range v.ExecutableResources {
+		r := &v.ExecutableResources[i]
+		buf = append(buf, "<ExecutableResource"...)
+		if r.ApiFramework != "" {
+			buf = append(buf, ` apiFramework="`...)
+			buf = escAttr(buf, r.ApiFramework)
+			buf = append(buf, '"')
+		}
+		if r.MediaType != "" {
+			buf = append(buf, ` type="`...)
+			buf = escAttr(buf, r.MediaType)
+			buf = append(buf, '"')
+		}
+		buf = append(buf, '>')
+		buf = e.appendURL(buf, r.Text)
+		buf = append(buf, "</ExecutableResource>"...)
+	}
+	// Wrapper always emitted for nested path xml:"TrackingEvents>Tracking"
+	buf = append(buf, "<TrackingEvents>"...)
+	for i := range v.TrackingEvents {
+		buf = e.appendTracking(buf, &v.TrackingEvents[i])
+	}
+	buf = append(buf, "</TrackingEvents>"...)
+	if v.VerificationParameters != "" {
+		buf = append(buf, "<VerificationParameters>"...)
+		buf = appendCDATA(buf, string(v.VerificationParameters))
+		buf = append(buf, "</VerificationParameters>"...)
+	}
+
+	buf = append(buf, "</Verification>"...)
+	return buf
+}
+
+func (e *encoder) appendWrapperCreative(buf []byte, c *WrapperCreative) []byte {
+	buf = append(buf, `<Creative id="`...)
+	buf = escAttr(buf, c.Id)
+	buf = append(buf, `" adId="`...)
+	buf = escAttr(buf, c.AdId)
+	buf = append(buf, '"')
+	if c.Sequence != 0 {
+		buf = append(buf, ` sequence="`...)
+		buf = strconv.AppendInt(buf, int64(c.Sequence), 10)
+		buf = append(buf, '"')
+	}
+	buf = append(buf, '>')
+
+	if c.Linear != nil {
+		buf = append(buf, "<Linear>"...)
+		// Wrappers always emitted for nested paths
+		buf = append(buf, "<TrackingEvents>"...)
+		for i := range c.Linear.TrackingEvents {
+			buf = e.appendTracking(buf, &c.Linear.TrackingEvents[i])
+		}
+		buf = append(buf, "</TrackingEvents>"...)
+		buf = append(buf, "<VideoClicks>"...)
+		for i := range c.Linear.ClickTracking {
+			buf = append(buf, `<ClickTracking id="`...)
+			buf = escAttr(buf, c.Linear.ClickTracking[i].Id)
+			buf = append(buf, '"', '>')
+			buf = e.appendURL(buf, c.Linear.ClickTracking[i].Text)
+			buf = append(buf, "</ClickTracking>"...)
+		}
+		buf = append(buf, "</VideoClicks>"...)
+		buf = append(buf, "</Linear>"...)
+	}
+
+	if c.CompanionAds != nil {
+		buf = append(buf, "<CompanionAds"...)
+		if c.CompanionAds.Required != "" {
+			buf = append(buf, ` required="`...)
+			buf = escAttr(buf, c.CompanionAds.Required)
+			buf = append(buf, '"')
+		}
+		buf = append(buf, '>')
+		// innerxml is written verbatim
+		buf = append(buf, c.CompanionAds.InnerXML...)
+		buf = append(buf, "</CompanionAds>"...)
+	}
+
+	buf = append(buf, "</Creative>"...)
+	return buf
+}
+
+func (e *encoder) appendImpression(buf []byte, imp *Impression) []byte {
 	buf = append(buf, `<Impression id="`...)
 	buf = escAttr(buf, imp.Id)
 	buf = append(buf, '"', '>')
-	buf = escText(buf, imp.Text)
+	buf = e.appendURL(buf, imp.Text)
 	buf = append(buf, "</Impression>"...)
 	return buf
 }
 
-func appendCreative(buf []byte, c *Creative) []byte {
+func (e *encoder) appendCreative(buf []byte, c *Creative) []byte {
 	buf = append(buf, `<Creative id="`...)
 	buf = escAttr(buf, c.Id)
 	buf = append(buf, `" adId="`...)
 	buf = escAttr(buf, c.AdId)
-	buf = append(buf, '"', '>')
+	buf = append(buf, '"')
+	if c.Sequence != 0 {
+		buf = append(buf, ` sequence="`...)
+		buf = strconv.AppendInt(buf, int64(c.Sequence), 10)
+		buf = append(buf, '"')
+	}
+	buf = append(buf, '>')
 
-	if c.UniversalAdId != nil {
+	for i := range c.UniversalAdIds {
 		buf = append(buf, `<UniversalAdId idRegistry="`...)
-		buf = escAttr(buf, c.UniversalAdId.IdRegistry)
+		buf = escAttr(buf, c.UniversalAdIds[i].IdRegistry)
 		buf = append(buf, '"', '>')
-		buf = escText(buf, c.UniversalAdId.Id)
+		buf = escText(buf, c.UniversalAdIds[i].Id)
 		buf = append(buf, "</UniversalAdId>"...)
 	}
 
+	buf = e.appendCreativeExtensions(buf, c.CreativeExtensions)
+
 	if c.Linear != nil {
-		buf = appendLinear(buf, c.Linear)
+		buf = e.appendLinear(buf, c.Linear)
 	}
 
 	buf = append(buf, "</Creative>"...)
 	return buf
 }
 
-func appendLinear(buf []byte, l *Linear) []byte {
-	buf = append(buf, "<Linear>"...)
+// appendCreativeExtensions writes the CreativeExtensions element, which like
+// xml.Marshal is omitted when there are no extensions.
+func (e *encoder) appendCreativeExtensions(buf []byte, exts CreativeExtensions) []byte {
+	if len(exts) == 0 {
+		return buf
+	}
+	buf = append(buf, "<CreativeExtensions>"...)
+	for i := range exts {
+		ext := &exts[i]
+		buf = append(buf, "<CreativeExtension"...)
+		if ext.ExtensionType != "" {
+			buf = append(buf, ` type="`...)
+			buf = escAttr(buf, ext.ExtensionType)
+			buf = append(buf, '"')
+		}
+		buf = append(buf, '>')
+		// innerxml is written verbatim
+		buf = append(buf, ext.InnerXML...)
+		buf = append(buf, "</CreativeExtension>"...)
+	}
+	return append(buf, "</CreativeExtensions>"...)
+}
+
+func (e *encoder) appendLinear(buf []byte, l *Linear) []byte {
+	buf = append(buf, "<Linear"...)
+	if l.SkipOffset != nil {
+		buf = append(buf, ` skipoffset="`...)
+		buf = appendTimeOffset(buf, *l.SkipOffset)
+		buf = append(buf, '"')
+	}
+	buf = append(buf, '>')
 
 	// Duration
 	buf = append(buf, "<Duration>"...)
 	buf = appendDuration(buf, l.Duration)
 	buf = append(buf, "</Duration>"...)
 
+	if ap := l.AdParameters; ap != nil {
+		buf = append(buf, "<AdParameters"...)
+		buf = appendBoolAttr(buf, ` xmlEncoded="`, ap.XMLEncoded)
+		buf = append(buf, '>')
+		buf = appendCDATA(buf, ap.Value)
+		buf = append(buf, "</AdParameters>"...)
+	}
+
 	// Wrappers always emitted for nested paths
 	buf = append(buf, "<TrackingEvents>"...)
 	for i := range l.TrackingEvents {
-		buf = appendTracking(buf, &l.TrackingEvents[i])
+		buf = e.appendTracking(buf, &l.TrackingEvents[i])
 	}
 	buf = append(buf, "</TrackingEvents>"...)
 
 	buf = append(buf, "<MediaFiles>"...)
 	for i := range l.MediaFiles {
-		buf = appendMediaFile(buf, &l.MediaFiles[i])
+		buf = e.appendMediaFile(buf, &l.MediaFiles[i])
+	}
+	for i := range l.Mezzanine {
+		buf = e.appendMezzanine(buf, &l.Mezzanine[i])
+	}
+	for i := range l.InteractiveCreativeFiles {
+		buf = e.appendInteractiveCreativeFile(buf, &l.InteractiveCreativeFiles[i])
 	}
 	buf = append(buf, "</MediaFiles>"...)
 
@@ -316,40 +822,135 @@ func appendLinear(buf []byte, l *Linear) []byte {
 		buf = append(buf, `<ClickThrough id="`...)
 		buf = escAttr(buf, l.ClickThrough.Id)
 		buf = append(buf, '"', '>')
-		buf = escText(buf, l.ClickThrough.Text)
+		buf = e.appendURL(buf, l.ClickThrough.Text)
 		buf = append(buf, "</ClickThrough>"...)
 	}
 	for i := range l.ClickTracking {
 		buf = append(buf, `<ClickTracking id="`...)
 		buf = escAttr(buf, l.ClickTracking[i].Id)
 		buf = append(buf, '"', '>')
-		buf = escText(buf, l.ClickTracking[i].Text)
+		buf = e.appendURL(buf, l.ClickTracking[i].Text)
 		buf = append(buf, "</ClickTracking>"...)
 	}
 	for i := range l.CustomClick {
 		buf = append(buf, `<CustomClick id="`...)
 		buf = escAttr(buf, l.CustomClick[i].Id)
 		buf = append(buf, '"', '>')
-		buf = escText(buf, l.CustomClick[i].Text)
+		buf = e.appendURL(buf, l.CustomClick[i].Text)
 		buf = append(buf, "</CustomClick>"...)
 	}
 	buf = append(buf, "</VideoClicks>"...)
 
+	if len(l.Icons) > 0 {
+		buf = append(buf, "<Icons>"...)
+		for i := range l.Icons {
+			buf = e.appendIcon(buf, &l.Icons[i])
+		}
+		buf = append(buf, "</Icons>"...)
+	}
+
 	buf = append(buf, "</Linear>"...)
 	return buf
 }
 
-func appendTracking(buf []byte, t *TrackingEvent) []byte {
+func (e *encoder) appendIcon(buf []byte, ic *Icon) []byte {
+	// attr order: program, width, height, xPosition, yPosition, duration, offset
+	buf = append(buf, "<Icon"...)
+	if ic.Program != "" {
+		buf = append(buf, ` program="`...)
+		buf = escAttr(buf, ic.Program)
+		buf = append(buf, '"')
+	}
+	if ic.Width != 0 {
+		buf = append(buf, ` width="`...)
+		buf = strconv.AppendInt(buf, int64(ic.Width), 10)
+		buf = append(buf, '"')
+	}
+	if ic.Height != 0 {
+		buf = append(buf, ` height="`...)
+		buf = strconv.AppendInt(buf, int64(ic.Height), 10)
+		buf = append(buf, '"')
+	}
+	if ic.XPosition != "" {
+		buf = append(buf, ` xPosition="`...)
+		buf = escAttr(buf, ic.XPosition)
+		buf = append(buf, '"')
+	}
+	if ic.YPosition != "" {
+		buf = append(buf, ` yPosition="`...)
+		buf = escAttr(buf, ic.YPosition)
+		buf = append(buf, '"')
+	}
+	if ic.Duration != nil {
+		buf = append(buf, ` duration="`...)
+		buf = appendDuration(buf, *ic.Duration)
+		buf = append(buf, '"')
+	}
+	if ic.Offset != nil {
+		buf = append(buf, ` offset="`...)
+		buf = appendTimeOffset(buf, *ic.Offset)
+		buf = append(buf, '"')
+	}
+	buf = append(buf, '>')
+
+	for i := range ic.StaticResources {
+		buf = append(buf, `<StaticResource creativeType="`...)
+		buf = escAttr(buf, ic.StaticResources[i].CreativeType)
+		buf = append(buf, '"', '>')
+		buf = e.appendURL(buf, ic.StaticResources[i].URL)
+		buf = append(buf, "</StaticResource>"...)
+	}
+	for i := range ic.IFrameResources {
+		buf = append(buf, "<IFrameResource>"...)
+		buf = e.appendURL(buf, ic.IFrameResources[i].URL)
+		buf = append(buf, "</IFrameResource>"...)
+	}
+	for i := range ic.HTMLResources {
+		buf = append(buf, "<HTMLResource>"...)
+		buf = appendCDATA(buf, ic.HTMLResources[i].HTML)
+		buf = append(buf, "</HTMLResource>"...)
+	}
+	if icc := ic.IconClicks; icc != nil {
+		buf = append(buf, "<IconClicks>"...)
+		if icc.IconClickThrough != "" {
+			buf = append(buf, "<IconClickThrough>"...)
+			buf = e.appendURL(buf, icc.IconClickThrough)
+			buf = append(buf, "</IconClickThrough>"...)
+		}
+		for _, u := range icc.IconClickTracking {
+			buf = append(buf, "<IconClickTracking>"...)
+			buf = e.appendURL(buf, u)
+			buf = append(buf, "</IconClickTracking>"...)
+		}
+		buf = append(buf, "</IconClicks>"...)
+	}
+	for _, u := range ic.IconViewTracking {
+		buf = append(buf, "<IconViewTracking>"...)
+		buf = e.appendURL(buf, u)
+		buf = append(buf, "</IconViewTracking>"...)
+	}
+	return append(buf, "</Icon>"...)
+}
+
+func (e *encoder) appendTracking(buf []byte, t *TrackingEvent) []byte {
 	buf = append(buf, `<Tracking event="`...)
 	buf = escAttr(buf, t.Event)
-	buf = append(buf, '"', '>')
-	buf = escText(buf, t.Text)
+	buf = append(buf, '"')
+	if t.Offset != nil {
+		buf = append(buf, ` offset="`...)
+		buf = appendTimeOffset(buf, *t.Offset)
+		buf = append(buf, '"')
+	}
+	buf = append(buf, '>')
+	buf = e.appendURL(buf, t.Text)
 	buf = append(buf, "</Tracking>"...)
 	return buf
 }
 
-func appendMediaFile(buf []byte, m *MediaFile) []byte {
-	// attr order: bitrate, width, height, delivery, type, codec
+func (e *encoder) appendMediaFile(buf []byte, m *MediaFile) []byte {
+	// attr order: bitrate, width, height, delivery, type, codec, then the
+	// optional id, minBitrate, maxBitrate, scalable, maintainAspectRatio,
+	// apiFramework, fileSize
 	buf = append(buf, `<MediaFile bitrate="`...)
 	buf = strconv.AppendInt(buf, int64(m.Bitrate), 10)
 	buf = append(buf, `" width="`...)
@@ -357,25 +958,94 @@ func appendMediaFile(buf []byte, m *MediaFile) []byte {
 	buf = append(buf, `" height="`...)
 	buf = strconv.AppendInt(buf, int64(m.Height), 10)
 	buf = append(buf, `" delivery="`...)
-	buf = escAttr(buf, m.Delivery)
+	buf = escAttr(buf, string(m.Delivery))
 	buf = append(buf, `" type="`...)
 	buf = escAttr(buf, m.MediaType)
 	buf = append(buf, `" codec="`...)
 	buf = escAttr(buf, m.Codec)
-	buf = append(buf, '"', '>')
-	buf = escText(buf, m.Text)
+	buf = append(buf, '"')
+	if m.Id != "" {
+		buf = append(buf, ` id="`...)
+		buf = escAttr(buf, m.Id)
+		buf = append(buf, '"')
+	}
+	buf = appendIntAttr(buf, ` minBitrate="`, m.MinBitrate)
+	buf = appendIntAttr(buf, ` maxBitrate="`, m.MaxBitrate)
+	buf = appendBoolAttr(buf, ` scalable="`, m.Scalable)
+	buf = appendBoolAttr(buf, ` maintainAspectRatio="`, m.MaintainAspectRatio)
+	if m.ApiFramework != "" {
+		buf = append(buf, ` apiFramework="`...)
+		buf = escAttr(buf, m.ApiFramework)
+		buf = append(buf, '"')
+	}
+	buf = appendIntAttr(buf, ` fileSize="`, m.FileSize)
+	buf = append(buf, '>')
+	buf = e.appendURL(buf, m.Text)
 	buf = append(buf, "</MediaFile>"...)
 	return buf
 }
 
-func appendExtension(buf []byte, ext *Extension) []byte {
+func (e *encoder) appendMezzanine(buf []byte, m *Mezzanine) []byte {
+	// attr order: id, delivery, type, width, height, codec, fileSize
+	buf = append(buf, "<Mezzanine"...)
+	if m.Id != "" {
+		buf = append(buf, ` id="`...)
+		buf = escAttr(buf, m.Id)
+		buf = append(buf, '"')
+	}
+	buf = append(buf, ` delivery="`...)
+	buf = escAttr(buf, string(m.Delivery))
+	buf = append(buf, `" type="`...)
+	buf = escAttr(buf, m.MediaType)
+	buf = append(buf, `" width="`...)
+	buf = strconv.AppendInt(buf, int64(m.Width), 10)
+	buf = append(buf, `" height="`...)
+	buf = strconv.AppendInt(buf, int64(m.Height), 10)
+	buf = append(buf, '"')
+	if m.Codec != "" {
+		buf = append(buf, ` codec="`...)
+		buf = escAttr(buf, m.Codec)
+		buf = append(buf, '"')
+	}
+	if m.FileSize != 0 {
+		buf = append(buf, ` fileSize="`...)
+		buf = strconv.AppendInt(buf, int64(m.FileSize), 10)
+		buf = append(buf, '"')
+	}
+	buf = append(buf, '>')
+	buf = e.appendURL(buf, m.Text)
+	buf = append(buf, "</Mezzanine>"...)
+	return buf
+}
+
+func (e *encoder) appendInteractiveCreativeFile(buf []byte, f *InteractiveCreativeFile) []byte {
+	// attr order: type, apiFramework, variableDuration
+	buf = append(buf, "<InteractiveCreativeFile"...)
+	if f.MediaType != "" {
+		buf = append(buf, ` type="`...)
+		buf = escAttr(buf, f.MediaType)
+		buf = append(buf, '"')
+	}
+	if f.ApiFramework != "" {
+		buf = append(buf, ` apiFramework="`...)
+		buf = escAttr(buf, f.ApiFramework)
+		buf = append(buf, '"')
+	}
+	buf = appendBoolAttr(buf, ` variableDuration="`, f.VariableDuration)
+	buf = append(buf, '>')
+	buf = e.appendURL(buf, f.Text)
+	buf = append(buf, "</InteractiveCreativeFile>"...)
+	return buf
+}
+
+func (e *encoder) appendExtension(buf []byte, ext *Extension) []byte {
 	buf = append(buf, `<Extension type="`...)
 	buf = escAttr(buf, ext.ExtensionType)
 	buf = append(buf, '"', '>')
 
 	buf = append(buf, "<CreativeParameters>"...)
 	for i := range ext.CreativeParameters {
-		buf = appendCreativeParameter(buf, &ext.CreativeParameters[i])
+		buf = e.appendCreativeParameter(buf, &ext.CreativeParameters[i])
 	}
 	buf = append(buf, "</CreativeParameters>"...)
 
@@ -383,7 +1053,7 @@ func appendExtension(buf []byte, ext *Extension) []byte {
 	return buf
 }
 
-func appendCreativeParameter(buf []byte, cp *CreativeParameter) []byte {
+func (e *encoder) appendCreativeParameter(buf []byte, cp *CreativeParameter) []byte {
 	// attr order: creativeId, name, type (Value is chardata)
 	buf = append(buf, `<CreativeParameter creativeId="`...)
 	buf = escAttr(buf, cp.CreativeId)
@@ -0,0 +1,94 @@
+package vmap
+
+import "reflect"
+
+// Equal reports whether v and other hold the same data. Nil and empty slices
+// are treated as equal, since encoding/xml leaves absent repeated elements
+// nil where encoding/json gives empty slices for the same input.
+func (v *VMAP) Equal(other *VMAP) bool {
+	if v == nil || other == nil {
+		return v == other
+	}
+	return deepEqual(reflect.ValueOf(v).Elem(), reflect.ValueOf(other).Elem())
+}
+
+// Equal reports whether ab and other hold the same data, treating nil and
+// empty slices as equal.
+func (ab *AdBreak) Equal(other *AdBreak) bool {
+	if ab == nil || other == nil {
+		return ab == other
+	}
+	return deepEqual(reflect.ValueOf(ab).Elem(), reflect.ValueOf(other).Elem())
+}
+
+// Equal reports whether to and other are the same offset.
+func (to TimeOffset) Equal(other TimeOffset) bool {
+	return to.Compare(other) == 0
+}
+
+// Equal reports whether d and other are the same duration.
+func (d Duration) Equal(other Duration) bool {
+	return d.Duration == other.Duration
+}
+
+// Equal reports whether m and other describe the same media file.
+func (m MediaFile) Equal(other MediaFile) bool {
+	return deepEqual(reflect.ValueOf(m), reflect.ValueOf(other))
+}
+
+var timeOffsetType = reflect.TypeFor[TimeOffset]()
+
+// deepEqual is reflect.DeepEqual, except that nil and empty slices and maps
+// are equal and time offsets are compared with TimeOffset.Equal.
+func deepEqual(a, b reflect.Value) bool {
+	if a.Type() == timeOffsetType {
+		return a.Interface().(TimeOffset).Equal(b.Interface().(TimeOffset))
+	}
+	switch a.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		return deepEqual(a.Elem(), b.Elem())
+	case reflect.Slice, reflect.Array:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for i := range a.Len() {
+			if !deepEqual(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Map:
+		if a.Len() != b.Len() {
+			return false
+		}
+		iter := a.MapRange()
+		for iter.Next() {
+			bv := b.MapIndex(iter.Key())
+			if !bv.IsValid() || !deepEqual(iter.Value(), bv) {
+				return false
+			}
+		}
+		return true
+	case reflect.Struct:
+		for i := range a.NumField() {
+			if !deepEqual(a.Field(i), b.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Bool:
+		return a.Bool() == b.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() == b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() == b.Uint()
+	case reflect.Float32, reflect.Float64:
+		return a.Float() == b.Float()
+	case reflect.String:
+		return a.String() == b.String()
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
@@ -0,0 +1,64 @@
+package vmap
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestVMAPEqual(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVmap.xml")
+	is.NoErr(err)
+	v, err := DecodeVmap(doc)
+	is.NoErr(err)
+
+	marshaled, err := MarshalVmap(&v)
+	is.NoErr(err)
+	decoded, err := DecodeVmap(marshaled)
+	is.NoErr(err)
+	is.True(v.Equal(&decoded))
+
+	js, err := json.Marshal(v)
+	is.NoErr(err)
+	var fromJSON VMAP
+	is.NoErr(json.Unmarshal(js, &fromJSON))
+	is.True(v.Equal(&fromJSON))
+
+	// nil and empty slices are equal
+	empty := v.Clone()
+	empty.Extensions = []VMAPExtension{}
+	empty.AdBreaks[0].AdSource.VASTData.VAST.Ad[0].InLine.Categories = []Category{}
+	is.True(v.Equal(empty))
+
+	changed := v.Clone()
+	changed.AdBreaks[1].TimeOffset.Duration.Duration += time.Second
+	is.True(!v.Equal(changed))
+	is.True(!v.AdBreaks[1].Equal(&changed.AdBreaks[1]))
+	is.True(v.AdBreaks[0].Equal(&changed.AdBreaks[0]))
+
+	changed = v.Clone()
+	changed.AdBreaks[0].AdSource.VASTData.VAST.Ad[0].InLine.Creatives[0].Linear.MediaFiles[0].Width++
+	is.True(!v.Equal(changed))
+
+	var nilVMAP *VMAP
+	is.True(nilVMAP.Equal(nil))
+	is.True(!nilVMAP.Equal(&v))
+}
+
+func TestTimeOffsetEqual(t *testing.T) {
+	is := is.New(t)
+	is.True(TimeOffset{Duration: &Duration{time.Minute}}.Equal(TimeOffset{Duration: &Duration{time.Minute}}))
+	is.True(!TimeOffset{Duration: &Duration{}}.Equal(TimeOffset{Position: OffsetStart}))
+	is.True(TimeOffset{Percent: 0.5}.Equal(TimeOffset{Percent: 0.5}))
+	is.True(!TimeOffset{Position: 1}.Equal(TimeOffset{Position: 2}))
+	is.True(Duration{time.Second}.Equal(Duration{time.Second}))
+	is.True(MediaFile{Width: 1}.Equal(MediaFile{Width: 1}))
+	is.True(!MediaFile{Width: 1}.Equal(MediaFile{Width: 2}))
+	yes, alsoYes, no := true, true, false
+	is.True(MediaFile{Scalable: &yes}.Equal(MediaFile{Scalable: &alsoYes}))
+	is.True(!MediaFile{Scalable: &yes}.Equal(MediaFile{Scalable: &no}))
+}
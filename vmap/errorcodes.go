@@ -0,0 +1,88 @@
+package vmap
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// VAST error codes, substituted for the [ERRORCODE] macro in Error URLs.
+const (
+	// XML errors
+	ErrCodeXMLParsing          = 100
+	ErrCodeVASTSchema          = 101
+	ErrCodeVersionNotSupported = 102
+
+	// Trafficking errors
+	ErrCodeTrafficking         = 200
+	ErrCodeUnexpectedLinearity = 201
+	ErrCodeUnexpectedDuration  = 202
+	ErrCodeUnexpectedSize      = 203
+	ErrCodeCategoryRequired    = 204
+	ErrCodeBlockedCategory     = 205
+	ErrCodeBreakShortened      = 206
+
+	// Wrapper errors
+	ErrCodeGeneralWrapper       = 300
+	ErrCodeWrapperTimeout       = 301
+	ErrCodeWrapperLimit         = 302
+	ErrCodeNoVASTResponse       = 303
+	ErrCodeInLineDisplayTimeout = 304
+
+	// Linear errors
+	ErrCodeGeneralLinear           = 400
+	ErrCodeMediaNotFound           = 401
+	ErrCodeMediaTimeout            = 402
+	ErrCodeMediaNotSupported       = 403
+	ErrCodeMediaDisplay            = 405
+	ErrCodeMezzanineRequired       = 406
+	ErrCodeMezzanineDownloading    = 407
+	ErrCodeConditionalAdRejected   = 408
+	ErrCodeInteractiveNotExecuted  = 409
+	ErrCodeVerificationNotExecuted = 410
+	ErrCodeMezzanineNotToSpec      = 411
+
+	// NonLinear errors
+	ErrCodeGeneralNonLinear      = 500
+	ErrCodeNonLinearDimensions   = 501
+	ErrCodeNonLinearFetch        = 502
+	ErrCodeNonLinearNotSupported = 503
+
+	// Companion errors
+	ErrCodeGeneralCompanion      = 600
+	ErrCodeCompanionDimensions   = 601
+	ErrCodeCompanionRequired     = 602
+	ErrCodeCompanionFetch        = 603
+	ErrCodeCompanionNotSupported = 604
+
+	// Undefined and interactive errors
+	ErrCodeUndefined                  = 900
+	ErrCodeGeneralVPAID               = 901
+	ErrCodeGeneralInteractiveCreative = 902
+)
+
+// Reasons a verification was not executed, substituted for the [REASON]
+// macro in verificationNotExecuted tracking URLs.
+const (
+	// ReasonVerificationRejected: the player does not allow code from the vendor.
+	ReasonVerificationRejected = 1
+	// ReasonVerificationNotSupported: the API framework or resource type is
+	// not supported by the player, e.g. because the OM SDK is unavailable.
+	ReasonVerificationNotSupported = 2
+	// ReasonVerificationLoadError: the resource could not be loaded or failed.
+	ReasonVerificationLoadError = 3
+)
+
+// URL returns the error URL with the [ERRORCODE] macro replaced by code.
+func (e *Error) URL(code int) string {
+	c := strconv.Itoa(code)
+	r := strings.NewReplacer("[ERRORCODE]", c, "%%ERRORCODE%%", c)
+	return r.Replace(strings.TrimSpace(e.Value))
+}
+
+// FireWithCode requests the error URL with the [ERRORCODE] macro replaced by code.
+// If client is nil, http.DefaultClient is used.
+func (e *Error) FireWithCode(ctx context.Context, client *http.Client, code int) error {
+	return fireURL(ctx, client, e.URL(code))
+}
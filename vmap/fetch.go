@@ -0,0 +1,215 @@
+package vmap
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// MaxFetchSize is the largest response body, in bytes, that Fetch will read.
+var MaxFetchSize int64 = 10 << 20
+
+// ErrBodyTooLarge is returned by Fetch when the response exceeds MaxFetchSize.
+var ErrBodyTooLarge = errors.New("response body exceeds max fetch size")
+
+// StatusError is returned by Fetch and the tracking Fire methods when the
+// server responds with a non-2xx status.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d fetching %s", e.StatusCode, e.URL)
+}
+
+// CompressionError is returned by Fetch when the response has a
+// Content-Encoding that is not supported or cannot be decompressed.
+type CompressionError struct {
+	URL      string
+	Encoding string
+	Err      error
+}
+
+func (e *CompressionError) Error() string {
+	return fmt.Sprintf("error decompressing %s response from %s: %v", e.Encoding, e.URL, e.Err)
+}
+
+func (e *CompressionError) Unwrap() error {
+	return e.Err
+}
+
+// Fetch requests a VMAP document from url and decodes it.
+// HTTP failures are returned as *StatusError, decoding failures are wrapped
+// so that they can be told apart. If client is nil, http.DefaultClient is used.
+//
+// Gzip and deflate compressed responses are decompressed regardless of the
+// client's transport, as are gzip bodies sent without a Content-Encoding.
+// Decompression failures are returned as *CompressionError.
+func Fetch(ctx context.Context, client *http.Client, url string) (*VMAP, error) {
+	body, err := fetchBody(ctx, client, url)
+	if err != nil {
+		return nil, err
+	}
+	var vmap VMAP
+	if err := xml.Unmarshal(body, &vmap); err != nil {
+		return nil, fmt.Errorf("error decoding VMAP from %s: %w", url, err)
+	}
+	return &vmap, nil
+}
+
+// FetchResult is the outcome of fetching one URL with FetchAll. Exactly one
+// of VMAP and Err is set.
+type FetchResult struct {
+	VMAP *VMAP
+	Err  error
+}
+
+// FetchAll fetches the VMAP documents at urls, as Fetch does, with at most
+// concurrency requests in flight, and returns the result for each URL.
+// A URL listed more than once is fetched once. If concurrency is less than
+// 1, the URLs are fetched one at a time. When ctx is done, the URLs not yet
+// fetched get ctx.Err() as their error.
+func FetchAll(ctx context.Context, client *http.Client, urls []string, concurrency int) map[string]FetchResult {
+	unique := make([]string, 0, len(urls))
+	results := make(map[string]FetchResult, len(urls))
+	for _, u := range urls {
+		if _, ok := results[u]; !ok {
+			results[u] = FetchResult{}
+			unique = append(unique, u)
+		}
+	}
+	concurrency = max(1, min(concurrency, len(unique)))
+
+	jobs := make(chan string)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for u := range jobs {
+				var res FetchResult
+				if err := ctx.Err(); err != nil {
+					res.Err = err
+				} else {
+					res.VMAP, res.Err = Fetch(ctx, client, u)
+				}
+				mu.Lock()
+				results[u] = res
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, u := range unique {
+		jobs <- u
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+func fetchBody(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/xml, text/xml")
+	// Setting Accept-Encoding turns off the transparent gzip support of
+	// http.Transport, so that all transports are handled the same way below.
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &StatusError{URL: url, StatusCode: resp.StatusCode}
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "" {
+		mediaType, _, err := mime.ParseMediaType(ct)
+		if err != nil || !isXMLMediaType(mediaType) {
+			return nil, fmt.Errorf("unexpected content type %q fetching %s", ct, url)
+		}
+	}
+
+	body, err := readLimited(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response from %s: %w", url, err)
+	}
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if encoding == "" && bytes.HasPrefix(body, gzipMagic) {
+		encoding = "gzip"
+	}
+	if encoding == "" || encoding == "identity" {
+		return body, nil
+	}
+	body, err = decompress(body, encoding)
+	if errors.Is(err, ErrBodyTooLarge) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, &CompressionError{URL: url, Encoding: encoding, Err: err}
+	}
+	return body, nil
+}
+
+// gzipMagic starts every gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// decompress returns the body decoded according to a Content-Encoding.
+// Deflate is zlib wrapped per the HTTP spec, but some servers send raw
+// deflate data, so that is accepted too.
+func decompress(body []byte, encoding string) ([]byte, error) {
+	var r io.ReadCloser
+	var err error
+	switch encoding {
+	case "gzip", "x-gzip":
+		r, err = gzip.NewReader(bytes.NewReader(body))
+	case "deflate":
+		r, err = zlib.NewReader(bytes.NewReader(body))
+		if err != nil {
+			r, err = flate.NewReader(bytes.NewReader(body)), nil
+		}
+	default:
+		return nil, errors.New("unsupported content encoding")
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return readLimited(r)
+}
+
+// readLimited reads r to the end, returning ErrBodyTooLarge if it holds more
+// than MaxFetchSize bytes.
+func readLimited(r io.Reader) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r, MaxFetchSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > MaxFetchSize {
+		return nil, ErrBodyTooLarge
+	}
+	return body, nil
+}
+
+// isXMLMediaType reports whether mediaType can carry an XML document.
+// Some ad servers answer with text/plain, so that is accepted too.
+func isXMLMediaType(mediaType string) bool {
+	return mediaType == "text/plain" || strings.HasSuffix(mediaType, "/xml") || strings.HasSuffix(mediaType, "+xml")
+}
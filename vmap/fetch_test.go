@@ -0,0 +1,162 @@
+package vmap
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func newVmapServer(t *testing.T) *httptest.Server {
+	doc, err := os.ReadFile("sample-vmap/testVmap.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/vmap":
+			w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+			_, _ = w.Write(doc)
+		case "/html":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte("<html></html>"))
+		case "/broken":
+			w.Header().Set("Content-Type", "text/xml")
+			_, _ = w.Write([]byte("<vmap:VMAP><vmap:AdBreak timeOffset=\"bogus\"></vmap:AdBreak></vmap:VMAP>"))
+		case "/gzip", "/sniff":
+			w.Header().Set("Content-Type", "application/xml")
+			if r.URL.Path == "/gzip" {
+				w.Header().Set("Content-Encoding", "gzip")
+			}
+			zw := gzip.NewWriter(w)
+			_, _ = zw.Write(doc)
+			_ = zw.Close()
+		case "/deflate", "/raw-deflate":
+			w.Header().Set("Content-Type", "application/xml")
+			w.Header().Set("Content-Encoding", "deflate")
+			var zw io.WriteCloser = zlib.NewWriter(w)
+			if r.URL.Path == "/raw-deflate" {
+				zw, _ = flate.NewWriter(w, flate.DefaultCompression)
+			}
+			_, _ = zw.Write(doc)
+			_ = zw.Close()
+		case "/bad-gzip":
+			w.Header().Set("Content-Type", "application/xml")
+			w.Header().Set("Content-Encoding", "gzip")
+			_, _ = w.Write(doc)
+		case "/brotli":
+			w.Header().Set("Content-Type", "application/xml")
+			w.Header().Set("Content-Encoding", "br")
+			_, _ = w.Write(doc)
+		case "/error":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestFetch(t *testing.T) {
+	is := is.New(t)
+	srv := newVmapServer(t)
+	defer srv.Close()
+
+	v, err := Fetch(context.Background(), srv.Client(), srv.URL+"/vmap")
+	is.NoErr(err)
+	is.Equal(len(v.AdBreaks), 3)
+
+	_, err = Fetch(context.Background(), srv.Client(), srv.URL+"/error")
+	var statusErr *StatusError
+	is.True(errors.As(err, &statusErr))
+	is.Equal(statusErr.StatusCode, http.StatusServiceUnavailable)
+
+	_, err = Fetch(context.Background(), srv.Client(), srv.URL+"/broken")
+	is.True(err != nil)
+	is.True(!errors.As(err, &statusErr))
+	is.True(strings.Contains(err.Error(), "error decoding VMAP"))
+
+	_, err = Fetch(context.Background(), srv.Client(), srv.URL+"/html")
+	is.True(err != nil)
+	is.True(strings.Contains(err.Error(), "unexpected content type"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = Fetch(ctx, srv.Client(), srv.URL+"/vmap")
+	is.True(errors.Is(err, context.Canceled))
+}
+
+func TestFetchMaxSize(t *testing.T) {
+	is := is.New(t)
+	srv := newVmapServer(t)
+	defer srv.Close()
+
+	defer func(size int64) { MaxFetchSize = size }(MaxFetchSize)
+	MaxFetchSize = 1024
+
+	_, err := Fetch(context.Background(), srv.Client(), srv.URL+"/vmap")
+	is.True(errors.Is(err, ErrBodyTooLarge))
+}
+
+func TestFetchCompressed(t *testing.T) {
+	is := is.New(t)
+	srv := newVmapServer(t)
+	defer srv.Close()
+
+	for _, path := range []string{"/gzip", "/sniff", "/deflate", "/raw-deflate"} {
+		v, err := Fetch(context.Background(), srv.Client(), srv.URL+path)
+		is.NoErr(err)
+		is.Equal(len(v.AdBreaks), 3)
+	}
+
+	var compErr *CompressionError
+	_, err := Fetch(context.Background(), srv.Client(), srv.URL+"/bad-gzip")
+	is.True(errors.As(err, &compErr))
+	is.Equal(compErr.Encoding, "gzip")
+	_, err = Fetch(context.Background(), srv.Client(), srv.URL+"/brotli")
+	is.True(errors.As(err, &compErr))
+	is.Equal(compErr.Encoding, "br")
+
+	// the limit applies to the decompressed document
+	defer func(size int64) { MaxFetchSize = size }(MaxFetchSize)
+	MaxFetchSize = 1024
+	_, err = Fetch(context.Background(), srv.Client(), srv.URL+"/gzip")
+	is.True(errors.Is(err, ErrBodyTooLarge))
+}
+
+func TestFetchAll(t *testing.T) {
+	is := is.New(t)
+	srv := newVmapServer(t)
+	defer srv.Close()
+
+	urls := []string{srv.URL + "/vmap", srv.URL + "/error", srv.URL + "/gzip", srv.URL + "/html", srv.URL + "/vmap"}
+	results := FetchAll(context.Background(), srv.Client(), urls, 2)
+	is.Equal(len(results), 4)
+	for _, path := range []string{"/vmap", "/gzip"} {
+		res := results[srv.URL+path]
+		is.NoErr(res.Err)
+		is.Equal(len(res.VMAP.AdBreaks), 3)
+	}
+	var statusErr *StatusError
+	is.True(errors.As(results[srv.URL+"/error"].Err, &statusErr))
+	is.Equal(results[srv.URL+"/error"].VMAP, nil)
+	is.True(results[srv.URL+"/html"].Err != nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results = FetchAll(ctx, srv.Client(), urls, 0)
+	is.Equal(len(results), 4)
+	for _, res := range results {
+		is.True(errors.Is(res.Err, context.Canceled))
+	}
+
+	is.Equal(len(FetchAll(context.Background(), srv.Client(), nil, 4)), 0)
+}
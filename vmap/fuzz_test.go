@@ -0,0 +1,79 @@
+package vmap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func FuzzDurationUnmarshal(f *testing.F) {
+	for _, seed := range []string{
+		"00:00:10", "00:01:00.300", "04:01:12.345", "00:00:05.5", "00:00:05.123456789", "99:59:59.999",
+		" 00:00:30 ", "00:00:05.", "00:00", "01:04:01:12.345", "", ":::", "...", "aa:bb:cc", "-00:00:01",
+		"99999999999999999999:00:00", "00:00:00.99999999999999999999",
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var d Duration
+		if err := d.UnmarshalText(data); err != nil {
+			return
+		}
+		text, err := d.MarshalText()
+		if err != nil {
+			t.Fatalf("MarshalText of %q parsed from %q: %v", d.Duration, data, err)
+		}
+		var rt Duration
+		if err := rt.UnmarshalText(text); err != nil {
+			t.Fatalf("%q marshalled from %q does not parse: %v", text, data, err)
+		}
+	})
+}
+
+func FuzzTimeOffsetUnmarshal(f *testing.F) {
+	for _, seed := range []string{
+		"start", "end", "00:00:10", "00:05:00.125", "10%", "25.000000%", "150%", "#1", "#-3",
+		"", "%", "#", "#999", "abc%", "1e3%", "NaN%", "00:00", "Start", "#1.5",
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var to TimeOffset
+		if err := to.UnmarshalText(data); err != nil {
+			return
+		}
+		if _, err := to.MarshalText(); err != nil {
+			t.Fatalf("MarshalText of offset parsed from %q: %v", data, err)
+		}
+	})
+}
+
+// FuzzVMAPParse checks that no input makes Parse panic. encoding/xml does not
+// expand entities declared in a DTD, so entity expansion cannot blow up the
+// memory used.
+func FuzzVMAPParse(f *testing.F) {
+	fixtures, err := filepath.Glob("sample-vmap/*.xml")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, name := range fixtures {
+		doc, err := os.ReadFile(name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(doc)
+	}
+	f.Add([]byte(`<!DOCTYPE VMAP [<!ENTITY a "aaaaaaaaaa"><!ENTITY b "&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;">]>` +
+		`<vmap:VMAP xmlns:vmap="http://www.iab.net/videosuite/vmap" version="1.0">&b;</vmap:VMAP>`))
+	f.Add([]byte(`<vmap:VMAP xmlns:vmap="http://www.iab.net/videosuite/vmap" version="1.0">` +
+		`<vmap:AdBreak timeOffset="start"><vmap:AdSource><vmap:VASTAdData>&lt;VAST version="3.0"&gt;` +
+		`</vmap:VASTAdData></vmap:AdSource></vmap:AdBreak></vmap:VMAP>`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if v, err := Parse(data); err == nil && v == nil {
+			t.Fatalf("Parse(%q) returned neither a VMAP nor an error", data)
+		}
+		if v, err := ParseWithOptions(data, ParseOptions{EmbeddedVAST: true}); err == nil && v == nil {
+			t.Fatalf("ParseWithOptions(%q) returned neither a VMAP nor an error", data)
+		}
+	})
+}
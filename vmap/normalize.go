@@ -0,0 +1,46 @@
+package vmap
+
+import (
+	"log/slog"
+	"slices"
+)
+
+// Normalize returns a copy of the VMAP with duplicate ad breaks removed and
+// the breaks sorted by TimeOffset, see NormalizeInPlace. The receiver is not
+// modified.
+func (v *VMAP) Normalize(logger *slog.Logger) *VMAP {
+	return v.Clone().NormalizeInPlace(logger)
+}
+
+// NormalizeInPlace removes the ad breaks whose breakId appears again later in
+// the VMAP, keeping the last occurrence, and then sorts the breaks by
+// TimeOffset.Compare, keeping the document order of breaks at the same
+// offset. Breaks without a breakId are never removed. Each removed break is
+// logged to logger at warning level, unless logger is nil. It returns v.
+func (v *VMAP) NormalizeInPlace(logger *slog.Logger) *VMAP {
+	if v == nil {
+		return nil
+	}
+	last := make(map[string]int, len(v.AdBreaks))
+	for i := range v.AdBreaks {
+		if id := v.AdBreaks[i].Id; id != "" {
+			last[id] = i
+		}
+	}
+	kept := v.AdBreaks[:0]
+	for i := range v.AdBreaks {
+		if id := v.AdBreaks[i].Id; id != "" && last[id] != i {
+			if logger != nil {
+				logger.Warn("removing duplicate ad break", "breakId", id, "index", i, "kept", last[id])
+			}
+			continue
+		}
+		kept = append(kept, v.AdBreaks[i])
+	}
+	clear(v.AdBreaks[len(kept):])
+	v.AdBreaks = kept
+	slices.SortStableFunc(v.AdBreaks, func(a, b AdBreak) int {
+		return a.TimeOffset.Compare(b.TimeOffset)
+	})
+	return v
+}
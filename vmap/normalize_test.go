@@ -0,0 +1,50 @@
+package vmap
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestNormalize(t *testing.T) {
+	is := is.New(t)
+	at := func(d time.Duration) TimeOffset { return TimeOffset{Duration: &Duration{d}} }
+	v := &VMAP{Version: "1.0", AdBreaks: []AdBreak{
+		{Id: "postroll", TimeOffset: TimeOffset{Position: OffsetEnd}},
+		{Id: "midroll-2", TimeOffset: at(20 * time.Minute)},
+		{Id: "midroll-1", TimeOffset: at(10 * time.Minute), BreakType: BreakTypeLinear},
+		{Id: "preroll", TimeOffset: TimeOffset{Position: OffsetStart}},
+		{TimeOffset: at(10 * time.Minute)},
+		{Id: "midroll-1", TimeOffset: at(15 * time.Minute), BreakType: BreakTypeNonLinear},
+		{TimeOffset: at(10 * time.Minute)},
+	}}
+	ids := func(v *VMAP) []string {
+		var ids []string
+		for _, ab := range v.AdBreaks {
+			ids = append(ids, ab.Id)
+		}
+		return ids
+	}
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	n := v.Normalize(logger)
+	is.Equal(ids(n), []string{"preroll", "", "", "midroll-1", "midroll-2", "postroll"})
+	is.Equal(n.AdBreaks[3].BreakType, BreakTypeNonLinear) // the last midroll-1 is kept
+	is.Equal(len(v.AdBreaks), 7)                          // the receiver is not modified
+	is.Equal(v.AdBreaks[0].Id, "postroll")
+	is.Equal(strings.Count(logs.String(), "removing duplicate ad break"), 1)
+	is.True(strings.Contains(logs.String(), "breakId=midroll-1 index=2 kept=5"))
+
+	same := v.NormalizeInPlace(nil)
+	is.True(same == v)
+	is.Equal(ids(v), ids(n))
+	is.True(v.Equal(n))
+
+	is.Equal((*VMAP)(nil).Normalize(nil), nil)
+	is.Equal((*VMAP)(nil).NormalizeInPlace(nil), nil)
+}
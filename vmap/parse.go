@@ -0,0 +1,327 @@
+package vmap
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ParseError is returned by Parse when the document cannot be decoded. It
+// locates the element that was being decoded when the error occurred.
+type ParseError struct {
+	Cause error
+	// ElementPath is the path of open elements, e.g.
+	// "VMAP/AdBreak[0]/AdSource/VASTAdData/VAST". Elements that may repeat
+	// carry their index among siblings of the same name.
+	ElementPath string
+	// ByteOffset is the input offset just after the token being decoded.
+	ByteOffset int64
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("vmap: parse error at <%s> offset %d: %v", e.ElementPath, e.ByteOffset, e.Cause)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Cause
+}
+
+// ParseOptions controls how ParseWithOptions interprets a VMAP document.
+// The zero value gives the same result as xml.Unmarshal.
+type ParseOptions struct {
+	// EmbeddedVAST makes the parser accept a VASTAdData that carries its VAST
+	// document as escaped or CDATA wrapped character data instead of as a
+	// child element, as some ad servers do. The text is parsed and stored in
+	// VASTData.VAST as if it had been nested.
+	EmbeddedVAST bool
+}
+
+// Parse decodes a VMAP document using encoding/xml. Unlike xml.Unmarshal it
+// reports decoding errors, such as ErrMultipleAdSources for an AdBreak with
+// more than one AdSource, as *ParseError.
+func Parse(b []byte) (*VMAP, error) {
+	return ParseWithOptions(b, ParseOptions{})
+}
+
+// ParseWithOptions decodes a VMAP document using encoding/xml, applying opts.
+func ParseWithOptions(b []byte, opts ParseOptions) (*VMAP, error) {
+	var vmap VMAP
+	if err := decodeXML(b, &vmap); err != nil {
+		return nil, err
+	}
+	if opts.EmbeddedVAST {
+		if err := parseEmbeddedVAST(b, &vmap); err != nil {
+			return nil, err
+		}
+	}
+	return &vmap, nil
+}
+
+// ParseStrict decodes a VMAP document like Parse, and also returns the paths
+// of the elements that the VMAP types do not model, whose content is lost.
+// The paths have the form of ParseError.ElementPath. Nothing is reported
+// below an unmodelled element, nor inside elements that are kept verbatim,
+// such as Extension and CustomAdData. Unknown attributes are not reported.
+func ParseStrict(b []byte) (*VMAP, []string, error) {
+	vmap, err := Parse(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	unmodelled, err := unmodelledElements(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	return vmap, unmodelled, nil
+}
+
+// unmodelledElements returns the paths of the elements in b that have no
+// counterpart in the VMAP types.
+func unmodelledElements(b []byte) ([]string, error) {
+	pt := &pathTracker{d: xml.NewDecoder(bytes.NewReader(b))}
+	var paths []string
+	// models of the open elements, nil where the content is not checked
+	var stack []*elementModel
+	for {
+		tok, err := pt.Token()
+		if err == io.EOF {
+			return paths, nil
+		}
+		if err != nil {
+			return nil, &ParseError{Cause: err, ElementPath: pt.path(), ByteOffset: pt.d.InputOffset()}
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			var m *elementModel
+			switch {
+			case len(stack) == 0:
+				m = vmapModel()
+			case stack[len(stack)-1] == nil:
+			case stack[len(stack)-1].any:
+			default:
+				var ok bool
+				if m, ok = stack[len(stack)-1].children[t.Name.Local]; !ok {
+					paths = append(paths, pt.path())
+				}
+			}
+			stack = append(stack, m)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		}
+	}
+}
+
+// elementModel describes the child elements that the type decoded from an
+// element accepts.
+type elementModel struct {
+	children map[string]*elementModel
+	// any is set when the content is kept verbatim, so any child is accepted.
+	any bool
+}
+
+var vmapModel = sync.OnceValue(func() *elementModel {
+	return newElementModel(reflect.TypeFor[VMAP](), make(map[reflect.Type]*elementModel))
+})
+
+var (
+	xmlUnmarshalerType  = reflect.TypeFor[xml.Unmarshaler]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// newElementModel returns the model of an element decoded into t, following
+// the struct tags like encoding/xml does.
+func newElementModel(t reflect.Type, seen map[reflect.Type]*elementModel) *elementModel {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice && !isListType(t) && t.Elem().Kind() != reflect.Uint8 {
+		t = t.Elem()
+	}
+	if m, ok := seen[t]; ok {
+		return m
+	}
+	m := &elementModel{children: make(map[string]*elementModel)}
+	seen[t] = m
+	switch {
+	case isListType(t):
+		// A list element such as AdVerifications, whose children are named
+		// after the element type.
+		m.children[listItemName(t)] = newElementModel(t.Elem(), seen)
+	case t.Kind() == reflect.Struct && !reflect.PointerTo(t).Implements(textUnmarshalerType):
+		m.addFields(t, seen)
+	}
+	return m
+}
+
+// isListType reports whether t is a slice type that decodes itself from a
+// list element, like AdVerifications and Icons.
+func isListType(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && reflect.PointerTo(t).Implements(xmlUnmarshalerType)
+}
+
+// listItemName returns the name of the children of a list element, which is
+// the name of their type unless noted here.
+func listItemName(t reflect.Type) string {
+	if t == reflect.TypeFor[VMAPExtensions]() {
+		return "Extension"
+	}
+	return t.Elem().Name()
+}
+
+func (m *elementModel) addFields(t reflect.Type, seen map[reflect.Type]*elementModel) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("xml")
+		if f.Anonymous && tag == "" {
+			if ft := f.Type; ft.Kind() == reflect.Struct {
+				m.addFields(ft, seen)
+			}
+			continue
+		}
+		if !f.IsExported() || tag == "-" || f.Name == "XMLName" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if opts != "" {
+			flags := strings.Split(opts, ",")
+			if slices.Contains(flags, "attr") || slices.Contains(flags, "chardata") ||
+				slices.Contains(flags, "cdata") || slices.Contains(flags, "comment") {
+				continue
+			}
+			if slices.Contains(flags, "innerxml") || slices.Contains(flags, "any") {
+				m.any = true
+				continue
+			}
+		}
+		if name == "" {
+			name = f.Name
+		}
+		names := strings.Split(name[strings.LastIndex(name, " ")+1:], ">")
+		parent := m
+		for _, n := range names[:len(names)-1] {
+			if parent.children[n] == nil {
+				parent.children[n] = &elementModel{children: make(map[string]*elementModel)}
+			}
+			parent = parent.children[n]
+		}
+		parent.children[names[len(names)-1]] = newElementModel(f.Type, seen)
+	}
+}
+
+// parseEmbeddedVAST fills in the VAST of every VASTAdData that has no VAST
+// child element but whose character data looks like an XML document.
+func parseEmbeddedVAST(b []byte, vmap *VMAP) error {
+	// VASTData does not keep its character data, so collect it separately.
+	var raw struct {
+		AdBreaks []struct {
+			VASTAdData string `xml:"AdSource>VASTAdData"`
+		} `xml:"AdBreak"`
+	}
+	if err := xml.Unmarshal(b, &raw); err != nil {
+		return fmt.Errorf("error parsing VMAP: %w", err)
+	}
+	for i := range vmap.AdBreaks {
+		ab := &vmap.AdBreaks[i]
+		if ab.AdSource == nil || ab.AdSource.VASTData == nil || ab.AdSource.VASTData.VAST != nil {
+			continue
+		}
+		text := bytes.TrimSpace([]byte(raw.AdBreaks[i].VASTAdData))
+		if len(text) == 0 || text[0] != '<' {
+			continue
+		}
+		var vast VAST
+		if err := decodeXML(text, &vast); err != nil {
+			return fmt.Errorf("error parsing embedded VAST in %s: %w", adBreakPath(ab, i), err)
+		}
+		ab.AdSource.VASTData.VAST = &vast
+	}
+	return nil
+}
+
+// decodeXML decodes b into v like xml.Unmarshal, but returns errors as a
+// *ParseError locating the element being decoded.
+func decodeXML(b []byte, v any) error {
+	pt := &pathTracker{d: xml.NewDecoder(bytes.NewReader(b))}
+	if err := xml.NewTokenDecoder(pt).Decode(v); err != nil {
+		return &ParseError{Cause: err, ElementPath: pt.path(), ByteOffset: pt.d.InputOffset()}
+	}
+	return nil
+}
+
+// pathTracker is an xml.TokenReader that keeps track of the open elements.
+type pathTracker struct {
+	d     *xml.Decoder
+	stack []pathElement
+}
+
+type pathElement struct {
+	name     string
+	children map[string]int
+}
+
+func (pt *pathTracker) Token() (xml.Token, error) {
+	tok, err := pt.d.Token()
+	switch t := tok.(type) {
+	case xml.StartElement:
+		name := t.Name.Local
+		if n := len(pt.stack); n > 0 {
+			parent := &pt.stack[n-1]
+			if parent.children == nil {
+				parent.children = make(map[string]int)
+			}
+			if repeatedElements()[name] {
+				name += "[" + strconv.Itoa(parent.children[name]) + "]"
+			}
+			parent.children[t.Name.Local]++
+		}
+		pt.stack = append(pt.stack, pathElement{name: name})
+	case xml.EndElement:
+		pt.stack = pt.stack[:len(pt.stack)-1]
+	}
+	return tok, err
+}
+
+func (pt *pathTracker) path() string {
+	names := make([]string, len(pt.stack))
+	for i := range pt.stack {
+		names[i] = pt.stack[i].name
+	}
+	return strings.Join(names, "/")
+}
+
+// repeatedElements returns the names of the elements that are decoded into
+// slices, found by walking the VMAP struct tags.
+var repeatedElements = sync.OnceValue(func() map[string]bool {
+	names := make(map[string]bool)
+	seen := make(map[reflect.Type]bool)
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct || seen[t] {
+			return
+		}
+		seen[t] = true
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag, _, _ := strings.Cut(f.Tag.Get("xml"), ",")
+			if tag == "" || tag == "-" {
+				continue
+			}
+			switch {
+			case isListType(f.Type):
+				// The list element is not repeated, its children are.
+				names[listItemName(f.Type)] = true
+			case f.Type.Kind() == reflect.Slice && f.Type.Elem().Kind() != reflect.Uint8:
+				names[tag[strings.LastIndex(tag, ">")+1:]] = true
+			}
+			walk(f.Type)
+		}
+	}
+	walk(reflect.TypeFor[VMAP]())
+	return names
+})
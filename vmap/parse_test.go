@@ -0,0 +1,191 @@
+package vmap
+
+import (
+	"encoding/xml"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestParseEmbeddedVAST(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVmapEmbeddedVAST.xml")
+	is.NoErr(err)
+
+	strict, err := Parse(doc)
+	is.NoErr(err)
+	is.True(strict.AdBreaks[0].AdSource.VASTData.VAST != nil)
+	is.Equal(strict.AdBreaks[1].AdSource.VASTData.VAST, nil)
+	is.Equal(strict.AdBreaks[2].AdSource.VASTData.VAST, nil)
+
+	v, err := ParseWithOptions(doc, ParseOptions{EmbeddedVAST: true})
+	is.NoErr(err)
+	nested := v.AdBreaks[0].AdSource.VASTData.VAST
+	is.Equal(nested.Version, "3.0")
+	is.Equal(nested.Ad[0].InLine.Impression[0].Text, "https://adserver.example.com/impression?ad=1&pos=pre")
+	is.Equal(*v.AdBreaks[1].AdSource.VASTData.VAST, *nested)
+	is.Equal(*v.AdBreaks[2].AdSource.VASTData.VAST, *nested)
+}
+
+func TestParseEmbeddedVASTErrors(t *testing.T) {
+	is := is.New(t)
+	_, err := Parse([]byte(`<VMAP><AdBreak>`))
+	is.True(err != nil)
+
+	doc := []byte(`<vmap:VMAP xmlns:vmap="http://www.iab.net/videosuite/vmap" version="1.0">` +
+		`<vmap:AdBreak breakId="broken"><vmap:AdSource><vmap:VASTAdData>` +
+		`<![CDATA[<VAST version="3.0"><Ad>]]>` +
+		`</vmap:VASTAdData></vmap:AdSource></vmap:AdBreak>` +
+		`<vmap:AdBreak breakId="text"><vmap:AdSource><vmap:VASTAdData>not xml</vmap:VASTAdData></vmap:AdSource>` +
+		`</vmap:AdBreak></vmap:VMAP>`)
+	_, err = ParseWithOptions(doc, ParseOptions{EmbeddedVAST: true})
+	is.True(err != nil)
+	is.True(strings.HasPrefix(err.Error(), "error parsing embedded VAST in AdBreak[broken]: "))
+
+	v, err := Parse(doc)
+	is.NoErr(err)
+	is.Equal(v.AdBreaks[0].AdSource.VASTData.VAST, nil)
+}
+
+func TestParseMultipleAdSources(t *testing.T) {
+	is := is.New(t)
+	doc := []byte(`<vmap:VMAP xmlns:vmap="http://www.iab.net/videosuite/vmap" version="1.0">` +
+		`<vmap:AdBreak breakId="twice" timeOffset="start" breakType="linear">` +
+		`<vmap:AdSource><vmap:AdTagURI>https://a.example.com/vast</vmap:AdTagURI></vmap:AdSource>` +
+		`<vmap:AdSource><vmap:AdTagURI>https://b.example.com/vast</vmap:AdTagURI></vmap:AdSource>` +
+		`</vmap:AdBreak></vmap:VMAP>`)
+
+	_, err := Parse(doc)
+	is.True(errors.Is(err, ErrMultipleAdSources))
+	is.True(strings.Contains(err.Error(), `AdBreak "twice"`))
+
+	var v VMAP
+	err = xml.Unmarshal(doc, &v)
+	is.True(errors.Is(err, ErrMultipleAdSources))
+	_, err = DecodeVmap(doc)
+	is.True(errors.Is(err, ErrMultipleAdSources))
+	_, err = DecodeVmapScan(doc)
+	is.True(errors.Is(err, ErrMultipleAdSources))
+	is.True(strings.Contains(err.Error(), `AdBreak "twice"`))
+
+	single, err := os.ReadFile("sample-vmap/testVmapAdTagURI.xml")
+	is.NoErr(err)
+	_, err = Parse(single)
+	is.NoErr(err)
+	is.NoErr(xml.Unmarshal(single, &v))
+	_, err = DecodeVmap(single)
+	is.NoErr(err)
+	_, err = DecodeVmapScan(single)
+	is.NoErr(err)
+}
+
+func TestParseErrorPath(t *testing.T) {
+	is := is.New(t)
+	tests := []struct {
+		name string
+		doc  string
+		path string
+	}{
+		{
+			name: "root",
+			doc:  `<vmap:VMAP xmlns:vmap="http://www.iab.net/videosuite/vmap" version="1.0">`,
+			path: "VMAP",
+		},
+		{
+			name: "ad break attribute",
+			doc: `<vmap:VMAP xmlns:vmap="http://www.iab.net/videosuite/vmap" version="1.0">` +
+				`<vmap:AdBreak breakId="pre" timeOffset="start"></vmap:AdBreak>` +
+				`<vmap:AdBreak breakId="mid" timeOffset="half%"></vmap:AdBreak></vmap:VMAP>`,
+			path: "VMAP/AdBreak[1]",
+		},
+		{
+			name: "nested VAST",
+			doc: `<vmap:VMAP xmlns:vmap="http://www.iab.net/videosuite/vmap" version="1.0">` +
+				`<vmap:AdBreak breakId="pre" timeOffset="start"><vmap:AdSource><vmap:VASTAdData>` +
+				`<VAST version="3.0"><Ad id="1"></Ad><Ad id="2"><InLine><Creatives>` +
+				`<Creative><Linear><Duration>00:00:15</Duration></Linear></Creative>` +
+				`<Creative><Linear><MediaFiles><MediaFile width="wide"></MediaFile></MediaFiles>` +
+				`</Linear></Creative>` +
+				`</Creatives></InLine></Ad></VAST></vmap:VASTAdData></vmap:AdSource></vmap:AdBreak></vmap:VMAP>`,
+			path: "VMAP/AdBreak[0]/AdSource/VASTAdData/VAST/Ad[1]/InLine/Creatives/Creative[1]" +
+				"/Linear/MediaFiles/MediaFile[0]",
+		},
+		{
+			name: "syntax",
+			doc: `<vmap:VMAP xmlns:vmap="http://www.iab.net/videosuite/vmap" version="1.0">` +
+				`<vmap:AdBreak breakId="pre" timeOffset="start"><vmap:AdSource></vmap:AdBreak></vmap:VMAP>`,
+			path: "VMAP/AdBreak[0]/AdSource",
+		},
+	}
+	for _, tt := range tests {
+		_, err := Parse([]byte(tt.doc))
+		var parseErr *ParseError
+		is.True(errors.As(err, &parseErr))
+		is.Equal(parseErr.ElementPath, tt.path)
+		is.True(parseErr.ByteOffset > 0)
+		is.True(parseErr.ByteOffset <= int64(len(tt.doc)))
+		is.True(strings.HasPrefix(err.Error(), "vmap: parse error at <"+tt.path+"> offset "))
+	}
+}
+
+func TestParseErrorPathListElements(t *testing.T) {
+	is := is.New(t)
+	doc := `<VAST version="4.1"><Ad id="1"><InLine><AdVerifications>` +
+		`<Verification vendor="a"></Verification>` +
+		`<Verification vendor="b"><JavaScriptResource browserOptional="maybe">https://v.example.com/b.js` +
+		`</JavaScriptResource></Verification>` +
+		`</AdVerifications></InLine></Ad></VAST>`
+	doc = `<vmap:VMAP xmlns:vmap="http://www.iab.net/videosuite/vmap" version="1.0">` +
+		`<vmap:AdBreak breakId="pre" timeOffset="start"><vmap:AdSource><vmap:VASTAdData>` + doc +
+		`</vmap:VASTAdData></vmap:AdSource></vmap:AdBreak></vmap:VMAP>`
+	_, err := Parse([]byte(doc))
+	var parseErr *ParseError
+	is.True(errors.As(err, &parseErr))
+	is.Equal(parseErr.ElementPath, "VMAP/AdBreak[0]/AdSource/VASTAdData/VAST/Ad[0]/InLine/AdVerifications"+
+		"/Verification[1]/JavaScriptResource[0]")
+}
+
+func TestParseStrict(t *testing.T) {
+	is := is.New(t)
+	doc := []byte(`<vmap:VMAP xmlns:vmap="http://www.iab.net/videosuite/vmap" version="1.0">` +
+		`<vmap:AdBreak breakId="pre" breakType="linear" timeOffset="start">` +
+		`<vmap:AdSource><vmap:VASTAdData><VAST version="4.1"><Ad id="1"><InLine>` +
+		`<AdTitle>strict</AdTitle><Rating>PG</Rating>` +
+		`<Creatives><Creative><Linear><Duration>00:00:10</Duration>` +
+		`<Vendor><Name>nested, not reported</Name></Vendor>` +
+		`<Icons><Icon program="AdChoices"><IconViewTracking>https://t.example.com/v</IconViewTracking>` +
+		`</Icon></Icons></Linear></Creative></Creatives>` +
+		`</InLine></Ad></VAST></vmap:VASTAdData></vmap:AdSource>` +
+		`<vmap:Extensions><vmap:Extension type="x"><Anything><Goes/></Anything></vmap:Extension></vmap:Extensions>` +
+		`</vmap:AdBreak>` +
+		`<vmap:AdBreak breakId="post" breakType="linear" timeOffset="end"><vmap:Placement/></vmap:AdBreak>` +
+		`</vmap:VMAP>`)
+
+	v, unmodelled, err := ParseStrict(doc)
+	is.NoErr(err)
+	is.Equal(len(v.AdBreaks), 2)
+	is.Equal(unmodelled, []string{
+		"VMAP/AdBreak[0]/AdSource/VASTAdData/VAST/Ad[0]/InLine/Rating",
+		"VMAP/AdBreak[0]/AdSource/VASTAdData/VAST/Ad[0]/InLine/Creatives/Creative[0]/Linear/Vendor",
+		"VMAP/AdBreak[1]/Placement",
+	})
+
+	parsed, err := Parse(doc)
+	is.NoErr(err)
+	is.True(parsed.Equal(v))
+
+	for _, name := range []string{"sample-vmap/testVmap.xml", "sample-vmap/testVmapExtensions.xml"} {
+		doc, err := os.ReadFile(name)
+		is.NoErr(err)
+		_, unmodelled, err := ParseStrict(doc)
+		is.NoErr(err)
+		is.Equal(unmodelled, nil)
+	}
+
+	_, _, err = ParseStrict([]byte(`<VMAP><AdBreak>`))
+	var perr *ParseError
+	is.True(errors.As(err, &perr))
+}
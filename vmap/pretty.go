@@ -0,0 +1,17 @@
+package vmap
+
+import "encoding/xml"
+
+// PrettyXML marshals the VMAP with xml.MarshalIndent, starting each line
+// with prefix and indenting nested elements with indent, and prepends the
+// XML declaration. It is meant for human readers, such as debugging tools,
+// e.g. v.PrettyXML("", "  "). Use MarshalVmap for compact output.
+func (v *VMAP) PrettyXML(prefix, indent string) ([]byte, error) {
+	body, err := xml.MarshalIndent(v, prefix, indent)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 0, len(xml.Header)+len(body))
+	buf = append(buf, xml.Header...)
+	return append(buf, body...), nil
+}
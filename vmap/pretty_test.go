@@ -0,0 +1,33 @@
+package vmap
+
+import (
+	"encoding/xml"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestPrettyXML(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVmap.xml")
+	is.NoErr(err)
+	v, err := DecodeVmap(doc)
+	is.NoErr(err)
+
+	out, err := v.PrettyXML("", "  ")
+	is.NoErr(err)
+	is.True(strings.HasPrefix(string(out), xml.Header+"<VMAP"))
+	is.True(strings.Contains(string(out), "\n  <AdBreak "))
+	is.True(strings.Contains(string(out), "\n    <AdSource"))
+
+	var rt VMAP
+	is.NoErr(xml.Unmarshal(out, &rt))
+	is.Equal(len(rt.AdBreaks), len(v.AdBreaks))
+	is.Equal(rt.AdBreaks[0].Id, v.AdBreaks[0].Id)
+
+	out, err = v.PrettyXML("> ", "\t")
+	is.NoErr(err)
+	is.True(strings.Contains(string(out), "\n> \t<AdBreak "))
+}
@@ -0,0 +1,353 @@
+package vmap
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultMaxWrapperDepth is the number of wrappers ResolveWrappers follows
+// when ResolveOptions.MaxDepth is zero, as recommended by the VAST spec.
+const DefaultMaxWrapperDepth = 5
+
+// ErrWrapperLoop is wrapped by the WrapperError returned when a wrapper
+// chain requests the same VASTAdTagURI twice.
+var ErrWrapperLoop = errors.New("wrapper chain loops")
+
+// WrapperError is returned by ResolveWrappers when a wrapper chain cannot be
+// resolved. Code is the VAST error code to report through the Error URLs:
+// ErrCodeWrapperTimeout when a VASTAdTagURI cannot be fetched,
+// ErrCodeWrapperLimit when the depth limit is exceeded or the chain loops,
+// and ErrCodeNoVASTResponse when a wrapper leads to a response without ads.
+type WrapperError struct {
+	Code int
+	URI  string
+	Err  error
+	// ErrorURLs holds the root level Error URLs of the response without ads
+	// for ErrCodeNoVASTResponse.
+	ErrorURLs []string
+}
+
+func (e *WrapperError) Error() string {
+	msg := fmt.Sprintf("vast error %d resolving %s", e.Code, e.URI)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *WrapperError) Unwrap() error {
+	return e.Err
+}
+
+// ResolveOptions controls how ResolveWrappers fetches wrapped VAST documents.
+type ResolveOptions struct {
+	// Client is used for the requests. If nil, http.DefaultClient is used.
+	Client *http.Client
+	// MaxDepth is the number of wrappers to follow for each ad. If zero,
+	// DefaultMaxWrapperDepth is used.
+	MaxDepth int
+	// HopTimeout limits each request. If zero, only ctx limits the requests.
+	HopTimeout time.Duration
+	// OnError, if set, is called with the failure of every ad that is
+	// dropped instead of failing the resolution, e.g. to fire its Error URLs.
+	OnError func(*WrapperError)
+}
+
+// ResolveWrappers follows the VASTAdTagURI of every Wrapper ad in v until an
+// InLine ad is reached, and returns a copy of v in which each wrapper is
+// replaced by the ads it resolved to. The impressions, errors and tracking of
+// the wrappers followed are merged into each resolved InLine as described for
+// FlattenWrapperChain, and the wrappers are recorded in its WrapperChain.
+// v is not modified.
+//
+// The followAdditionalWrappers, allowMultipleAds and fallbackOnNoAd
+// attributes of each wrapper are honored: disallowed wrappers are dropped,
+// and when multiple ads are not allowed only the first standalone ad of the
+// response is used. A wrapper that cannot be resolved is dropped, and its
+// siblings are kept, if it is part of a pod or its fallbackOnNoAd is true;
+// its failure is passed to ResolveOptions.OnError. Any other failure, or the
+// failure of every ad of a response, is returned as *WrapperError, joined
+// with errors.Join when several ads failed.
+func ResolveWrappers(ctx context.Context, v *VAST, opts ResolveOptions) (*VAST, error) {
+	if opts.MaxDepth == 0 {
+		opts.MaxDepth = DefaultMaxWrapperDepth
+	}
+	out := v.clone()
+	ads, err := resolveAds(ctx, out.Ad, nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	out.Ad = ads
+	return out, nil
+}
+
+// resolveAds resolves the ads of one response, dropping the failed ads that
+// may fall back on their siblings.
+func resolveAds(ctx context.Context, ads []Ad, chain []Wrapper, opts ResolveOptions) ([]Ad, error) {
+	var resolved []Ad
+	var dropped []*WrapperError
+	for i := range ads {
+		r, err := resolveAd(ctx, ads[i], chain, opts)
+		if err != nil {
+			var wrapperErr *WrapperError
+			if !canFallBack(&ads[i]) || !errors.As(err, &wrapperErr) {
+				return nil, err
+			}
+			dropped = append(dropped, wrapperErr)
+			continue
+		}
+		resolved = append(resolved, r...)
+	}
+	if len(resolved) == 0 && len(dropped) > 0 {
+		if len(dropped) == 1 {
+			return nil, dropped[0]
+		}
+		errs := make([]error, len(dropped))
+		for i := range dropped {
+			errs[i] = dropped[i]
+		}
+		return nil, errors.Join(errs...)
+	}
+	if opts.OnError != nil {
+		for _, err := range dropped {
+			opts.OnError(err)
+		}
+	}
+	return resolved, nil
+}
+
+// canFallBack reports whether ad may be dropped when it cannot be resolved,
+// leaving the other ads of its response: it is part of a pod, or it is a
+// wrapper with fallbackOnNoAd set.
+func canFallBack(ad *Ad) bool {
+	if ad.Sequence != nil {
+		return true
+	}
+	return ad.Wrapper != nil && ad.Wrapper.FallbackOnNoAd != nil && *ad.Wrapper.FallbackOnNoAd
+}
+
+func resolveAd(ctx context.Context, ad Ad, chain []Wrapper, opts ResolveOptions) ([]Ad, error) {
+	w := ad.Wrapper
+	if w == nil {
+		if len(chain) > 0 {
+			ad.WrapperChain = chain
+			if ad.InLine != nil {
+				for i := range chain {
+					ad.InLine.mergeWrapper(&chain[i])
+				}
+			}
+		}
+		return []Ad{ad}, nil
+	}
+
+	uri := strings.TrimSpace(w.VASTAdTagURI)
+	if len(chain) >= opts.MaxDepth {
+		return nil, &WrapperError{Code: ErrCodeWrapperLimit, URI: uri}
+	}
+	for i := range chain {
+		if strings.TrimSpace(chain[i].VASTAdTagURI) == uri {
+			return nil, &WrapperError{Code: ErrCodeWrapperLimit, URI: uri, Err: ErrWrapperLoop}
+		}
+	}
+
+	next, err := fetchVAST(ctx, uri, opts)
+	if err != nil {
+		return nil, &WrapperError{Code: ErrCodeWrapperTimeout, URI: uri, Err: err}
+	}
+	ads := next.Ad
+	if w.FollowAdditionalWrappers != nil && !*w.FollowAdditionalWrappers {
+		ads = withoutWrappers(ads)
+	}
+	if w.AllowMultipleAds != nil && !*w.AllowMultipleAds {
+		ads = firstStandaloneAd(ads)
+	}
+	if len(ads) == 0 {
+		return nil, &WrapperError{Code: ErrCodeNoVASTResponse, URI: uri, ErrorURLs: next.ErrorURLs()}
+	}
+
+	// Each hop gets its own copy of the chain so that sibling ads in a pod
+	// do not share a backing array.
+	chain = append(chain[:len(chain):len(chain)], *w)
+	return resolveAds(ctx, ads, chain, opts)
+}
+
+// FlattenWrapperChain merges the tracking of a wrapper chain into the InLine
+// ads at its end. chain holds the VAST responses in the order they were
+// fetched: every document but the last must contain a Wrapper ad, and the
+// last must contain an InLine ad. The returned copy of the last document
+// stands on its own:
+//
+//   - Impressions and Error URLs of each wrapper are appended to every InLine.
+//   - Linear tracking events and click tracking of a wrapper creative are
+//     appended to the InLine creatives with the same sequence, or to all of
+//     them when the wrapper creative has no sequence.
+//   - Duplicate URLs are removed, keeping the first. Tracking events are only
+//     duplicates if they also have the same event. Impression URLs are
+//     compared without surrounding whitespace.
+//
+// The documents in chain are not modified.
+func FlattenWrapperChain(chain []*VAST) (*VAST, error) {
+	if len(chain) == 0 {
+		return nil, errors.New("empty wrapper chain")
+	}
+	var wrappers []*Wrapper
+	for i, v := range chain[:len(chain)-1] {
+		w := v.firstWrapper()
+		if w == nil {
+			return nil, fmt.Errorf("wrapper chain document %d has no Wrapper ad", i)
+		}
+		wrappers = append(wrappers, w)
+	}
+
+	flat := chain[len(chain)-1].clone()
+	found := false
+	for i := range flat.Ad {
+		il := flat.Ad[i].InLine
+		if il == nil {
+			continue
+		}
+		found = true
+		for _, w := range wrappers {
+			il.mergeWrapper(w)
+		}
+	}
+	if !found {
+		return nil, errors.New("last document in wrapper chain has no InLine ad")
+	}
+	return flat, nil
+}
+
+// firstWrapper returns the first Wrapper ad of the VAST, or nil.
+func (v *VAST) firstWrapper() *Wrapper {
+	for i := range v.Ad {
+		if v.Ad[i].Wrapper != nil {
+			return v.Ad[i].Wrapper
+		}
+	}
+	return nil
+}
+
+// mergeWrapper appends the impressions, errors and linear tracking of w to il.
+func (il *InLine) mergeWrapper(w *Wrapper) {
+	il.Impression = appendNew(il.Impression, w.Impression, impressionKey)
+	il.Error = appendNew(il.Error, w.Error, func(e Error) string { return e.Value })
+	for _, wc := range w.Creatives {
+		if wc.Linear == nil {
+			continue
+		}
+		for i := range il.Creatives {
+			c := &il.Creatives[i]
+			if c.Linear == nil || (wc.Sequence != 0 && wc.Sequence != c.Sequence) {
+				continue
+			}
+			c.Linear.TrackingEvents = appendNew(c.Linear.TrackingEvents, wc.Linear.TrackingEvents,
+				func(t TrackingEvent) string { return t.Event + " " + t.Text })
+			c.Linear.ClickTracking = appendNew(c.Linear.ClickTracking, wc.Linear.ClickTracking,
+				func(ct ClickTracking) string { return ct.Text })
+		}
+	}
+}
+
+// DedupImpressions removes the impressions whose URL, ignoring surrounding
+// whitespace, is that of an earlier impression, so that each is fired once.
+func (il *InLine) DedupImpressions() {
+	il.Impression = dedupImpressions(il.Impression, make(map[string]bool))
+}
+
+// DedupImpressions removes duplicate impression URLs from the ad, as
+// InLine.DedupImpressions does, across its InLine or Wrapper and the wrappers
+// of its WrapperChain, in that order. An impression of a wrapper in the chain
+// is removed if the InLine, or an outer wrapper, has the same URL.
+func (a *Ad) DedupImpressions() {
+	seen := make(map[string]bool)
+	if a.InLine != nil {
+		a.InLine.Impression = dedupImpressions(a.InLine.Impression, seen)
+	}
+	if a.Wrapper != nil {
+		a.Wrapper.Impression = dedupImpressions(a.Wrapper.Impression, seen)
+	}
+	for i := range a.WrapperChain {
+		a.WrapperChain[i].Impression = dedupImpressions(a.WrapperChain[i].Impression, seen)
+	}
+}
+
+// dedupImpressions returns a copy of imps without the impressions whose key
+// is in seen or earlier in imps, and adds the keys of the others to seen.
+func dedupImpressions(imps []Impression, seen map[string]bool) []Impression {
+	return slices.DeleteFunc(slices.Clone(imps), func(imp Impression) bool {
+		k := impressionKey(imp)
+		if seen[k] {
+			return true
+		}
+		seen[k] = true
+		return false
+	})
+}
+
+func impressionKey(imp Impression) string {
+	return strings.TrimSpace(imp.Text)
+}
+
+// appendNew appends the elements of src whose key is not yet in dst.
+func appendNew[T any](dst, src []T, key func(T) string) []T {
+	seen := make(map[string]bool, len(dst)+len(src))
+	for _, d := range dst {
+		seen[key(d)] = true
+	}
+	for _, s := range src {
+		if k := key(s); !seen[k] {
+			seen[k] = true
+			dst = append(dst, s)
+		}
+	}
+	return dst
+}
+
+// fetchVAST requests and decodes the VAST document at uri.
+func fetchVAST(ctx context.Context, uri string, opts ResolveOptions) (*VAST, error) {
+	if uri == "" {
+		return nil, errors.New("missing VASTAdTagURI")
+	}
+	if opts.HopTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.HopTimeout)
+		defer cancel()
+	}
+	body, err := fetchBody(ctx, opts.Client, uri)
+	if err != nil {
+		return nil, err
+	}
+	var vast VAST
+	if err := xml.Unmarshal(body, &vast); err != nil {
+		return nil, fmt.Errorf("error decoding VAST from %s: %w", uri, err)
+	}
+	return &vast, nil
+}
+
+// withoutWrappers returns the ads that are not wrappers.
+func withoutWrappers(ads []Ad) []Ad {
+	var kept []Ad
+	for _, ad := range ads {
+		if ad.Wrapper == nil {
+			kept = append(kept, ad)
+		}
+	}
+	return kept
+}
+
+// firstStandaloneAd returns the first ad without a sequence, i.e. not part
+// of an ad pod, as a one element slice.
+func firstStandaloneAd(ads []Ad) []Ad {
+	for i := range ads {
+		if ads[i].Sequence == nil {
+			return ads[i : i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,303 @@
+package vmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+// newWrapperServer serves a chain of wrappers: /wrap/N wraps /wrap/N-1 and
+// /wrap/0 is the InLine pod from testVast.xml.
+func newWrapperServer(t *testing.T) *httptest.Server {
+	inline, err := os.ReadFile("sample-vmap/testVast.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrapper := func(attrs, uri string) string {
+		return `<VAST version="4.0"><Ad id="wrapper"><Wrapper` + attrs + `>` +
+			`<AdSystem>Wrapper Adserver</AdSystem>` +
+			`<VASTAdTagURI><![CDATA[` + uri + `]]></VASTAdTagURI>` +
+			`<Impression>https://wrapper.example.com/impression</Impression>` +
+			`<BlockedAdCategories>IAB25</BlockedAdCategories>` +
+			`</Wrapper></Ad></VAST>`
+	}
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		switch path := r.URL.Path; {
+		case path == "/wrap/0":
+			_, _ = w.Write(inline)
+		case strings.HasPrefix(path, "/wrap/"):
+			n, _ := strconv.Atoi(strings.TrimPrefix(path, "/wrap/"))
+			_, _ = fmt.Fprint(w, wrapper("", fmt.Sprintf("%s/wrap/%d", srv.URL, n-1)))
+		case path == "/loop":
+			_, _ = fmt.Fprint(w, wrapper("", srv.URL+"/loop"))
+		case path == "/nofollow":
+			_, _ = fmt.Fprint(w, wrapper(` followAdditionalWrappers="false"`, srv.URL+"/wrap/1"))
+		case path == "/single":
+			_, _ = fmt.Fprint(w, wrapper(` allowMultipleAds="false"`, srv.URL+"/mixed"))
+		case path == "/mixed":
+			_, _ = fmt.Fprint(w, `<VAST version="4.0"><Ad id="pod" sequence="1"><InLine></InLine></Ad>`+
+				`<Ad id="pod-zero" sequence="0"><InLine></InLine></Ad>`+
+				`<Ad id="standalone"><InLine></InLine></Ad></VAST>`)
+		case path == "/empty":
+			_, _ = fmt.Fprint(w, `<VAST version="4.0"><Error>https://adserver.example.com/noad</Error></VAST>`)
+		case path == "/slow":
+			time.Sleep(200 * time.Millisecond)
+			_, _ = w.Write(inline)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return srv
+}
+
+func wrapperVAST(uri string) *VAST {
+	return &VAST{Version: "4.0", Ad: []Ad{
+		{Id: "outer", Wrapper: &Wrapper{VASTAdTagURI: uri}},
+		{Id: "inline", InLine: &InLine{AdTitle: "Already inline"}},
+	}}
+}
+
+func TestResolveWrappers(t *testing.T) {
+	is := is.New(t)
+	srv := newWrapperServer(t)
+	defer srv.Close()
+	opts := ResolveOptions{Client: srv.Client()}
+
+	v := wrapperVAST(srv.URL + "/wrap/2")
+	original := v.clone()
+	resolved, err := ResolveWrappers(context.Background(), v, opts)
+	is.NoErr(err)
+	is.Equal(v, original) // input is untouched
+
+	is.Equal(len(resolved.Ad), 3)
+	is.Equal(resolved.Ad[0].Id, "POD_AD-ID_001")
+	is.Equal(resolved.Ad[1].Id, "POD_AD-ID_002")
+	is.Equal(resolved.Ad[2].Id, "inline")
+	is.Equal(resolved.Ad[2].WrapperChain, nil)
+
+	chain := resolved.Ad[0].WrapperChain
+	is.Equal(len(chain), 3)
+	is.Equal(chain[0].VASTAdTagURI, srv.URL+"/wrap/2")
+	is.Equal(chain[2].VASTAdTagURI, srv.URL+"/wrap/0")
+	is.Equal(chain[1].BlockedAdCategories, []BlockedAdCategory{{Value: "IAB25"}})
+
+	// the impression shared by all hops is merged once
+	imps := resolved.Ad[0].InLine.Impression
+	is.Equal(len(imps), 2)
+	is.Equal(imps[1].Text, "https://wrapper.example.com/impression")
+
+	single, err := ResolveWrappers(context.Background(), wrapperVAST(srv.URL+"/single"), opts)
+	is.NoErr(err)
+	is.Equal(single.Ad[0].Id, "standalone")
+}
+
+func TestResolveWrappersErrors(t *testing.T) {
+	is := is.New(t)
+	srv := newWrapperServer(t)
+	defer srv.Close()
+	opts := ResolveOptions{Client: srv.Client()}
+
+	for _, tc := range []struct {
+		path string
+		opts ResolveOptions
+		code int
+	}{
+		{"/wrap/5", opts, ErrCodeWrapperLimit},
+		{"/wrap/2", ResolveOptions{Client: srv.Client(), MaxDepth: 2}, ErrCodeWrapperLimit},
+		{"/loop", opts, ErrCodeWrapperLimit},
+		{"/missing", opts, ErrCodeWrapperTimeout},
+		{"/slow", ResolveOptions{Client: srv.Client(), HopTimeout: 10 * time.Millisecond}, ErrCodeWrapperTimeout},
+		{"/empty", opts, ErrCodeNoVASTResponse},
+		{"/nofollow", opts, ErrCodeNoVASTResponse},
+	} {
+		_, err := ResolveWrappers(context.Background(), wrapperVAST(srv.URL+tc.path), tc.opts)
+		var wrapperErr *WrapperError
+		is.True(errors.As(err, &wrapperErr))
+		is.Equal(wrapperErr.Code, tc.code)
+	}
+
+	_, err := ResolveWrappers(context.Background(), wrapperVAST(srv.URL+"/loop"), opts)
+	is.True(errors.Is(err, ErrWrapperLoop))
+
+	_, err = ResolveWrappers(context.Background(), wrapperVAST(srv.URL+"/empty"), opts)
+	var noAds *WrapperError
+	is.True(errors.As(err, &noAds))
+	is.Equal(noAds.ErrorURLs, []string{"https://adserver.example.com/noad"})
+
+	_, err = ResolveWrappers(context.Background(), wrapperVAST(srv.URL+"/wrap/4"), opts)
+	is.NoErr(err)
+}
+
+func TestResolveWrappersFallback(t *testing.T) {
+	is := is.New(t)
+	srv := newWrapperServer(t)
+	defer srv.Close()
+	var dropped []*WrapperError
+	opts := ResolveOptions{Client: srv.Client(), OnError: func(err *WrapperError) {
+		dropped = append(dropped, err)
+	}}
+	one, two := 1, 2
+
+	// a pod with one dead wrapper keeps the other ads
+	pod := &VAST{Version: "4.0", Ad: []Ad{
+		{Id: "dead", Sequence: &one, Wrapper: &Wrapper{VASTAdTagURI: srv.URL + "/missing"}},
+		{Id: "alive", Sequence: &two, Wrapper: &Wrapper{VASTAdTagURI: srv.URL + "/wrap/1"}},
+	}}
+	resolved, err := ResolveWrappers(context.Background(), pod, opts)
+	is.NoErr(err)
+	is.Equal(len(resolved.Ad), 2)
+	is.Equal(resolved.Ad[0].Id, "POD_AD-ID_001")
+	is.Equal(len(dropped), 1)
+	is.Equal(dropped[0].Code, ErrCodeWrapperTimeout)
+	is.Equal(dropped[0].URI, srv.URL+"/missing")
+
+	// a standalone wrapper falls back only with fallbackOnNoAd
+	dropped = nil
+	v := wrapperVAST(srv.URL + "/empty")
+	_, err = ResolveWrappers(context.Background(), v, opts)
+	is.True(err != nil)
+	is.Equal(len(dropped), 0)
+
+	fallback := true
+	v.Ad[0].Wrapper.FallbackOnNoAd = &fallback
+	resolved, err = ResolveWrappers(context.Background(), v, opts)
+	is.NoErr(err)
+	is.Equal(len(resolved.Ad), 1)
+	is.Equal(resolved.Ad[0].Id, "inline")
+	is.Equal(len(dropped), 1)
+	is.Equal(dropped[0].ErrorURLs, []string{"https://adserver.example.com/noad"})
+
+	// if every ad fails, the failures are returned
+	dropped = nil
+	pod.Ad[1].Wrapper.VASTAdTagURI = srv.URL + "/loop"
+	_, err = ResolveWrappers(context.Background(), pod, opts)
+	var wrapperErr *WrapperError
+	is.True(errors.As(err, &wrapperErr))
+	is.Equal(wrapperErr.Code, ErrCodeWrapperTimeout)
+	is.True(errors.Is(err, ErrWrapperLoop))
+	is.Equal(len(dropped), 0)
+}
+
+func TestFlattenWrapperChain(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVastWrapper.xml")
+	is.NoErr(err)
+	wrapper, err := DecodeVast(doc)
+	is.NoErr(err)
+	doc, err = os.ReadFile("sample-vmap/testVast.xml")
+	is.NoErr(err)
+	inline, err := DecodeVast(doc)
+	is.NoErr(err)
+	original := inline.clone()
+
+	flat, err := FlattenWrapperChain([]*VAST{&wrapper, &wrapper, &inline})
+	is.NoErr(err)
+	is.Equal(&inline, original) // input is untouched
+
+	for _, ad := range flat.Ad {
+		il := ad.InLine
+		is.Equal(len(il.Impression), 3) // wrapper impressions are merged once
+		is.Equal(il.Impression[2].Text, "https://thirdparty.example.com/imp?a=1&b=2")
+		is.Equal(il.Error[len(il.Error)-1].Value, "https://wrapper.example.com/error?code=[ERRORCODE]")
+		linear := il.Creatives[0].Linear
+		is.Equal(len(linear.TrackingEvents), 7)
+		is.Equal(linear.TrackingEvents[6].Text, "https://wrapper.example.com/tracking/complete")
+		is.Equal(linear.ClickTracking[len(linear.ClickTracking)-1].Text, "https://wrapper.example.com/click")
+	}
+
+	// the flattened document marshals as a standalone InLine VAST
+	b, err := MarshalVast(flat)
+	is.NoErr(err)
+	decoded, err := DecodeVast(b)
+	is.NoErr(err)
+	is.Equal(decoded.Ad[0].InLine.Impression, flat.Ad[0].InLine.Impression)
+	is.Equal(decoded.firstWrapper(), nil)
+
+	_, err = FlattenWrapperChain(nil)
+	is.True(err != nil)
+	_, err = FlattenWrapperChain([]*VAST{&inline, &inline})
+	is.True(err != nil)
+	_, err = FlattenWrapperChain([]*VAST{&wrapper})
+	is.True(err != nil)
+}
+
+func TestDedupImpressions(t *testing.T) {
+	is := is.New(t)
+	imps := func(urls ...string) []Impression {
+		var imps []Impression
+		for _, u := range urls {
+			imps = append(imps, Impression{Text: u})
+		}
+		return imps
+	}
+	il := &InLine{Impression: imps(
+		"https://a.example.com/imp",
+		"https://b.example.com/imp",
+		"  https://a.example.com/imp\n",
+		"https://a.example.com/imp?x=1",
+		"https://b.example.com/imp",
+	)}
+	il.DedupImpressions()
+	is.Equal(il.Impression, imps(
+		"https://a.example.com/imp", "https://b.example.com/imp", "https://a.example.com/imp?x=1"))
+	(&InLine{}).DedupImpressions()
+
+	outer := Wrapper{Impression: imps("https://outer.example.com/imp", " https://a.example.com/imp")}
+	inner := Wrapper{Impression: imps("https://outer.example.com/imp ", "https://inner.example.com/imp")}
+	ad := &Ad{
+		InLine:       &InLine{Impression: imps("https://a.example.com/imp", "https://a.example.com/imp")},
+		WrapperChain: []Wrapper{outer, inner},
+	}
+	ad.DedupImpressions()
+	is.Equal(ad.InLine.Impression, imps("https://a.example.com/imp"))
+	is.Equal(ad.WrapperChain[0].Impression, imps("https://outer.example.com/imp"))
+	is.Equal(ad.WrapperChain[1].Impression, imps("https://inner.example.com/imp"))
+	is.Equal(len(outer.Impression), 2) // the slices of the chain are not modified in place
+
+	// flattening compares impressions without surrounding whitespace too
+	flat, err := FlattenWrapperChain([]*VAST{
+		{Ad: []Ad{{Wrapper: &outer}}},
+		{Ad: []Ad{{Wrapper: &inner}}},
+		{Ad: []Ad{{InLine: &InLine{Impression: imps("https://a.example.com/imp")}}}},
+	})
+	is.NoErr(err)
+	is.Equal(flat.Ad[0].InLine.Impression, imps(
+		"https://a.example.com/imp", "https://outer.example.com/imp", "https://inner.example.com/imp"))
+}
+
+func TestFlattenWrapperChainSequence(t *testing.T) {
+	is := is.New(t)
+	tracking := func(url string) *WrapperLinear {
+		return &WrapperLinear{TrackingEvents: []TrackingEvent{{Event: "start", Text: url}}}
+	}
+	wrapper := &VAST{Ad: []Ad{{Wrapper: &Wrapper{Creatives: []WrapperCreative{
+		{Sequence: 2, Linear: tracking("https://wrapper.example.com/second")},
+		{Linear: tracking("https://wrapper.example.com/all")},
+	}}}}}
+	inline := &VAST{Ad: []Ad{{InLine: &InLine{Creatives: []Creative{
+		{Sequence: 1, Linear: &Linear{}},
+		{Sequence: 2, Linear: &Linear{}},
+	}}}}}
+
+	flat, err := FlattenWrapperChain([]*VAST{wrapper, inline})
+	is.NoErr(err)
+	creatives := flat.Ad[0].InLine.Creatives
+	is.Equal(creatives[0].Linear.TrackingEvents, []TrackingEvent{
+		{Event: "start", Text: "https://wrapper.example.com/all"},
+	})
+	is.Equal(creatives[1].Linear.TrackingEvents, []TrackingEvent{
+		{Event: "start", Text: "https://wrapper.example.com/second"},
+		{Event: "start", Text: "https://wrapper.example.com/all"},
+	})
+}
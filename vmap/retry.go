@@ -0,0 +1,144 @@
+package vmap
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// RetryOptions controls how FetchWithRetry repeats failed requests.
+type RetryOptions struct {
+	// MaxAttempts is the number of requests made at most, including the
+	// first. If less than 1, 3 is used.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry. It doubles for
+	// each following retry, up to MaxBackoff, and is jittered. If zero,
+	// 200ms is used.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts. If zero, 5s is used.
+	MaxBackoff time.Duration
+	// RetryOn reports whether a response with the status code is retried.
+	// If nil, DefaultRetryOn is used. Network errors are always retried.
+	RetryOn func(statusCode int) bool
+}
+
+// DefaultRetryOn reports whether statusCode is a transient failure: 429 or
+// one of 500, 502, 503 and 504.
+func DefaultRetryOn(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+type loggerKey struct{}
+
+// WithLogger returns a copy of ctx carrying logger, which FetchWithRetry
+// logs its attempts to at debug level.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// loggerFrom returns the logger set by WithLogger, or nil.
+func loggerFrom(ctx context.Context) *slog.Logger {
+	logger, _ := ctx.Value(loggerKey{}).(*slog.Logger)
+	return logger
+}
+
+// FetchWithRetry fetches and decodes a VMAP document like Fetch, repeating
+// the request after network errors and the status codes selected by
+// opts.RetryOn, with jittered exponential backoff. Documents that fail to
+// decode are not retried. The last error is returned when all attempts
+// fail, or ctx.Err() if ctx is done while waiting.
+func FetchWithRetry(ctx context.Context, client *http.Client, url string, opts RetryOptions) (*VMAP, error) {
+	logger := loggerFrom(ctx)
+	var v *VMAP
+	err := retry(ctx, opts, func(attempt int) error {
+		if logger != nil {
+			logger.DebugContext(ctx, "fetching VMAP", "url", url, "attempt", attempt)
+		}
+		var err error
+		v, err = Fetch(ctx, client, url)
+		return err
+	}, func(attempt int, delay time.Duration, err error) {
+		if logger != nil {
+			logger.DebugContext(ctx, "retrying VMAP fetch",
+				"url", url, "attempt", attempt, "delay", delay, "error", err)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// retry calls do, with the attempt number starting at 1, until it succeeds,
+// fails with an error that opts does not retry, or opts.MaxAttempts is
+// reached, and returns the last error. Between attempts it waits with
+// jittered exponential backoff, calling onRetry first if it is not nil. It
+// returns ctx.Err() if ctx is done while waiting.
+func retry(ctx context.Context, opts RetryOptions, do func(attempt int) error,
+	onRetry func(attempt int, delay time.Duration, err error)) error {
+	opts = opts.withDefaults()
+	backoff := opts.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := do(attempt)
+		if err == nil || attempt >= opts.MaxAttempts || !opts.retryable(ctx, err) {
+			return err
+		}
+
+		delay := jitter(backoff)
+		if onRetry != nil {
+			onRetry(attempt, delay, err)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff = min(2*backoff, opts.MaxBackoff)
+	}
+}
+
+func (o RetryOptions) withDefaults() RetryOptions {
+	if o.MaxAttempts < 1 {
+		o.MaxAttempts = 3
+	}
+	if o.InitialBackoff <= 0 {
+		o.InitialBackoff = 200 * time.Millisecond
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = 5 * time.Second
+	}
+	if o.RetryOn == nil {
+		o.RetryOn = DefaultRetryOn
+	}
+	return o
+}
+
+// retryable reports whether a failed fetch may succeed if repeated: a status
+// selected by RetryOn, or a network error while ctx is still live.
+func (o RetryOptions) retryable(ctx context.Context, err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return o.RetryOn(statusErr.StatusCode)
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr) && ctx.Err() == nil
+}
+
+// jitter returns a random delay between d/2 and d.
+func jitter(d time.Duration) time.Duration {
+	if d < 2 {
+		return d
+	}
+	return d/2 + rand.N(d/2)
+}
@@ -0,0 +1,86 @@
+package vmap
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestFetchWithRetry(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVmap.xml")
+	is.NoErr(err)
+
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		switch {
+		case r.URL.Path == "/missing":
+			http.NotFound(w, r)
+		case r.URL.Path == "/flaky" && n < 3:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case r.URL.Path == "/down":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.Header().Set("Content-Type", "application/xml")
+			_, _ = w.Write(doc)
+		}
+	}))
+	defer srv.Close()
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	ctx := WithLogger(context.Background(), logger)
+	opts := RetryOptions{MaxAttempts: 4, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+
+	v, err := FetchWithRetry(ctx, srv.Client(), srv.URL+"/flaky", opts)
+	is.NoErr(err)
+	is.Equal(len(v.AdBreaks), 3)
+	is.Equal(requests.Load(), int32(3))
+	is.True(strings.Contains(logs.String(), "attempt=3"))
+	is.True(!strings.Contains(logs.String(), "attempt=4"))
+
+	requests.Store(0)
+	_, err = FetchWithRetry(ctx, srv.Client(), srv.URL+"/missing", opts)
+	var statusErr *StatusError
+	is.True(errors.As(err, &statusErr))
+	is.Equal(statusErr.StatusCode, http.StatusNotFound)
+	is.Equal(requests.Load(), int32(1))
+
+	requests.Store(0)
+	_, err = FetchWithRetry(ctx, srv.Client(), srv.URL+"/down", opts)
+	is.True(errors.As(err, &statusErr))
+	is.Equal(statusErr.StatusCode, http.StatusBadGateway)
+	is.Equal(requests.Load(), int32(4))
+
+	requests.Store(0)
+	opts.RetryOn = func(statusCode int) bool { return statusCode == http.StatusNotFound }
+	_, err = FetchWithRetry(context.Background(), srv.Client(), srv.URL+"/down", opts)
+	is.True(errors.As(err, &statusErr))
+	is.Equal(requests.Load(), int32(1))
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = FetchWithRetry(cancelled, srv.Client(), srv.URL+"/flaky", RetryOptions{})
+	is.True(errors.Is(err, context.Canceled))
+}
+
+func TestDefaultRetryOn(t *testing.T) {
+	is := is.New(t)
+	for _, code := range []int{429, 500, 502, 503, 504} {
+		is.True(DefaultRetryOn(code))
+	}
+	for _, code := range []int{200, 400, 404, 501} {
+		is.True(!DefaultRetryOn(code))
+	}
+}
@@ -0,0 +1,116 @@
+package vmap
+
+// RewriteTrackingURLs replaces every tracking URL in the VMAP, including the
+// embedded VAST documents, with the result of fn, e.g. to route beacons
+// through a proxy or to sign them. See VAST.RewriteTrackingURLs for the URLs
+// visited. The VMAP is modified in place.
+func (v *VMAP) RewriteTrackingURLs(fn func(eventType, url string) string) {
+	for i := range v.AdBreaks {
+		ab := &v.AdBreaks[i]
+		rewriteTrackingEvents(ab.TrackingEvents, fn)
+		if ab.AdSource != nil && ab.AdSource.VASTData != nil && ab.AdSource.VASTData.VAST != nil {
+			ab.AdSource.VASTData.VAST.RewriteTrackingURLs(fn)
+		}
+	}
+}
+
+// RewriteTrackingURLs replaces every tracking URL in the VAST document with
+// the result of fn, which is given the URL and its event type:
+//   - "impression" for Impression URLs;
+//   - "error" for Error URLs;
+//   - the event name, e.g. "start", for Tracking URLs, including those of
+//     verifications and of VMAP ad breaks;
+//   - "clickTracking" and "customClick" for video click tracking;
+//   - "viewable", "notViewable" and "viewUndetermined" for the URLs of a
+//     ViewableImpression;
+//   - "iconClickTracking" and "iconViewTracking" for the tracking of icons.
+//
+// Wrappers, and the wrappers merged into WrapperChain by ResolveWrappers, are
+// visited too. ClickThrough and IconClickThrough URLs are left alone, since
+// they are landing pages rather than beacons. The document is modified in place.
+func (v *VAST) RewriteTrackingURLs(fn func(eventType, url string) string) {
+	rewriteErrors(v.Error, fn)
+	for i := range v.Ad {
+		ad := &v.Ad[i]
+		if il := ad.InLine; il != nil {
+			rewriteImpressions(il.Impression, fn)
+			rewriteErrors(il.Error, fn)
+			if vi := il.ViewableImpression; vi != nil {
+				rewriteStrings(vi.Viewable, "viewable", fn)
+				rewriteStrings(vi.NotViewable, "notViewable", fn)
+				rewriteStrings(vi.ViewUndetermined, "viewUndetermined", fn)
+			}
+			rewriteVerifications(il.AdVerifications, fn)
+			for j := range il.Creatives {
+				if l := il.Creatives[j].Linear; l != nil {
+					rewriteTrackingEvents(l.TrackingEvents, fn)
+					rewriteClickTracking(l.ClickTracking, fn)
+					for k := range l.CustomClick {
+						l.CustomClick[k].Text = fn("customClick", l.CustomClick[k].Text)
+					}
+					for k := range l.Icons {
+						ic := &l.Icons[k]
+						if ic.IconClicks != nil {
+							rewriteStrings(ic.IconClicks.IconClickTracking, "iconClickTracking", fn)
+						}
+						rewriteStrings(ic.IconViewTracking, "iconViewTracking", fn)
+					}
+				}
+			}
+		}
+		if ad.Wrapper != nil {
+			ad.Wrapper.rewriteTrackingURLs(fn)
+		}
+		for j := range ad.WrapperChain {
+			ad.WrapperChain[j].rewriteTrackingURLs(fn)
+		}
+	}
+}
+
+func (w *Wrapper) rewriteTrackingURLs(fn func(eventType, url string) string) {
+	rewriteImpressions(w.Impression, fn)
+	rewriteErrors(w.Error, fn)
+	rewriteVerifications(w.AdVerifications, fn)
+	for i := range w.Creatives {
+		if l := w.Creatives[i].Linear; l != nil {
+			rewriteTrackingEvents(l.TrackingEvents, fn)
+			rewriteClickTracking(l.ClickTracking, fn)
+		}
+	}
+}
+
+func rewriteTrackingEvents(events []TrackingEvent, fn func(eventType, url string) string) {
+	for i := range events {
+		events[i].Text = fn(events[i].Event, events[i].Text)
+	}
+}
+
+func rewriteImpressions(imps []Impression, fn func(eventType, url string) string) {
+	for i := range imps {
+		imps[i].Text = fn("impression", imps[i].Text)
+	}
+}
+
+func rewriteErrors(errs []Error, fn func(eventType, url string) string) {
+	for i := range errs {
+		errs[i].Value = fn("error", errs[i].Value)
+	}
+}
+
+func rewriteClickTracking(clicks []ClickTracking, fn func(eventType, url string) string) {
+	for i := range clicks {
+		clicks[i].Text = fn("clickTracking", clicks[i].Text)
+	}
+}
+
+func rewriteVerifications(vs []Verification, fn func(eventType, url string) string) {
+	for i := range vs {
+		rewriteTrackingEvents(vs[i].TrackingEvents, fn)
+	}
+}
+
+func rewriteStrings(urls []string, eventType string, fn func(eventType, url string) string) {
+	for i := range urls {
+		urls[i] = fn(eventType, urls[i])
+	}
+}
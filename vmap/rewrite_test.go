@@ -0,0 +1,110 @@
+package vmap
+
+import (
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestRewriteTrackingURLs(t *testing.T) {
+	is := is.New(t)
+	beacon := func(name string) string { return "https://t.example.com/" + name }
+	tracking := func(event string) []TrackingEvent { return []TrackingEvent{{Event: event, Text: beacon(event)}} }
+	verifications := []Verification{{Vendor: "omid", TrackingEvents: tracking(EventVerificationNotExecuted)}}
+	wrapper := func(name string) Wrapper {
+		return Wrapper{
+			VASTAdTagURI:    "https://adserver.example.com/vast",
+			Impression:      []Impression{{Text: beacon(name + "-impression")}},
+			Error:           []Error{{Value: beacon(name + "-error")}},
+			AdVerifications: verifications,
+			Creatives: []WrapperCreative{{Linear: &WrapperLinear{
+				TrackingEvents: tracking(EventComplete),
+				ClickTracking:  []ClickTracking{{Text: beacon(name + "-click")}},
+			}}},
+		}
+	}
+	outer := wrapper("outer")
+	v := &VMAP{AdBreaks: []AdBreak{{
+		Id:             "preroll",
+		TrackingEvents: tracking(EventBreakStart),
+		AdSource: &AdSource{VASTData: &VASTData{VAST: &VAST{
+			Error: []Error{{Value: beacon("no-ad")}},
+			Ad: []Ad{
+				{Id: "inline", WrapperChain: []Wrapper{outer}, InLine: &InLine{
+					Impression: []Impression{{Text: beacon("impression")}},
+					Error:      []Error{{Value: beacon("error")}},
+					ViewableImpression: &ViewableImpression{
+						Viewable:         []string{beacon("viewable")},
+						NotViewable:      []string{beacon("notViewable")},
+						ViewUndetermined: []string{beacon("viewUndetermined")},
+					},
+					AdVerifications: verifications,
+					Creatives: []Creative{{Linear: &Linear{
+						TrackingEvents: tracking(EventStart),
+						ClickThrough:   &ClickThrough{Text: "https://brand.example.com/"},
+						ClickTracking:  []ClickTracking{{Text: beacon("click")}},
+						CustomClick:    []CustomClick{{Text: beacon("custom")}},
+						Icons: Icons{{
+							IconClicks: &IconClicks{
+								IconClickThrough:  "https://optout.example.com/",
+								IconClickTracking: []string{beacon("icon-click")},
+							},
+							IconViewTracking: []string{beacon("icon-view")},
+						}},
+					}}},
+				}},
+				{Id: "wrapper", Wrapper: &Wrapper{}},
+			},
+		}}},
+	}}}
+	*v.AdBreaks[0].AdSource.VASTData.VAST.Ad[1].Wrapper = wrapper("wrapper")
+	v = v.Clone() // the shared slices above are copied, so each URL is rewritten once
+
+	var events []string
+	v.RewriteTrackingURLs(func(eventType, u string) string {
+		events = append(events, eventType)
+		return "https://proxy.example.com/b?u=" + url.QueryEscape(u)
+	})
+
+	doc, err := MarshalVmap(v)
+	is.NoErr(err)
+	is.Equal(strings.Count(string(doc), "https://t.example.com/"), 0)
+	// WrapperChain is not marshalled
+	is.Equal(strings.Count(string(doc), "https://proxy.example.com/b?u="), len(events)-5)
+	chain := v.AdBreaks[0].AdSource.VASTData.VAST.Ad[0].WrapperChain[0]
+	is.Equal(chain.Impression[0].Text, "https://proxy.example.com/b?u="+url.QueryEscape(beacon("outer-impression")))
+	is.True(strings.Contains(string(doc), "https://brand.example.com/"))
+	is.True(strings.Contains(string(doc), "<IconClickThrough>https://optout.example.com/</IconClickThrough>"))
+	is.True(strings.Contains(string(doc), "https://proxy.example.com/b?u=https%3A%2F%2Ft.example.com%2Fimpression"))
+
+	slices.Sort(events)
+	events = slices.Compact(events)
+	is.Equal(events, []string{
+		EventBreakStart, "clickTracking", EventComplete, "customClick", "error", "iconClickTracking",
+		"iconViewTracking", "impression", "notViewable", EventStart, EventVerificationNotExecuted,
+		"viewUndetermined", "viewable",
+	})
+}
+
+func TestRewriteTrackingURLsFixture(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVmap.xml")
+	is.NoErr(err)
+	v, err := DecodeVmap(doc)
+	is.NoErr(err)
+
+	calls := 0
+	v.RewriteTrackingURLs(func(eventType, u string) string {
+		calls++
+		return "https://proxy.example.com/b?u=" + url.QueryEscape(u)
+	})
+	is.True(calls > 0)
+
+	out, err := MarshalVmap(&v)
+	is.NoErr(err)
+	is.Equal(strings.Count(string(out), "https://proxy.example.com/b?u="), calls)
+}
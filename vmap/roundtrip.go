@@ -0,0 +1,96 @@
+package vmap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// RoundTrip decodes a VMAP or VAST document and marshals it again, giving the
+// canonical form of the document as seen by this package. For a document that
+// only uses modelled elements the semantic content is preserved, but the
+// following transformations are expected:
+//
+//   - Elements and attributes that are not modelled are dropped, as are
+//     comments, processing instructions and the XML declaration.
+//   - Namespace prefixes are removed, e.g. vmap:AdBreak becomes AdBreak and the
+//     xmlns:vmap declaration becomes a plain vmap attribute.
+//   - Whitespace around URLs, as trimmed by the decoders, around
+//     CreativeParameter values and between the children of the VMAP and VAST
+//     root elements is dropped.
+//   - CDATA sections are written as escaped text, except for AdTagURI and raw
+//     content such as extensions and CustomAdData, which are kept as is.
+//   - Durations and time offsets are normalized to HH:MM:SS or HH:MM:SS.mmm.
+//   - Wrapper elements such as TrackingEvents and Creatives are always written,
+//     and attributes without omitempty are written even when empty.
+func RoundTrip(b []byte) ([]byte, error) {
+	root, err := rootElement(b)
+	if err != nil {
+		return nil, err
+	}
+	switch root {
+	case "VMAP":
+		var v VMAP
+		if err := xml.Unmarshal(b, &v); err != nil {
+			return nil, fmt.Errorf("error decoding VMAP: %w", err)
+		}
+		v.Text = strings.TrimSpace(v.Text)
+		for i := range v.AdBreaks {
+			if as := v.AdBreaks[i].AdSource; as != nil && as.VASTData != nil && as.VASTData.VAST != nil {
+				as.VASTData.VAST.trimParameters()
+			}
+		}
+		return MarshalVmap(&v)
+	case "VAST":
+		var v VAST
+		if err := xml.Unmarshal(b, &v); err != nil {
+			return nil, fmt.Errorf("error decoding VAST: %w", err)
+		}
+		v.trimParameters()
+		return MarshalVast(&v)
+	default:
+		return nil, fmt.Errorf("unsupported root element %q", root)
+	}
+}
+
+// rootElement returns the local name of the first element in the document.
+func rootElement(b []byte) (string, error) {
+	d := xml.NewDecoder(bytes.NewReader(b))
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return "", errors.New("no root element found in document")
+		}
+		if err != nil {
+			return "", fmt.Errorf("error parsing document: %w", err)
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			return se.Name.Local, nil
+		}
+	}
+}
+
+// trimParameters trims the whitespace that xml.Unmarshal keeps in the root
+// element and around CreativeParameter values, which the token and scan
+// decoders already drop.
+func (v *VAST) trimParameters() {
+	v.Text = strings.TrimSpace(v.Text)
+	for i := range v.Ad {
+		var exts []Extension
+		if il := v.Ad[i].InLine; il != nil {
+			exts = il.Extensions
+		}
+		if w := v.Ad[i].Wrapper; w != nil {
+			exts = append(exts, w.Extensions...)
+		}
+		for j := range exts {
+			params := exts[j].CreativeParameters
+			for k := range params {
+				params[k].Value = strings.TrimSpace(params[k].Value)
+			}
+		}
+	}
+}
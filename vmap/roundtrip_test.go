@@ -0,0 +1,91 @@
+package vmap
+
+import (
+	"encoding/xml"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestRoundTrip(t *testing.T) {
+	is := is.New(t)
+	for _, file := range []string{"testVmap.xml", "testVmapAdTagURI.xml", "testVmapCustomAdData.xml"} {
+		doc, err := os.ReadFile("sample-vmap/" + file)
+		is.NoErr(err)
+
+		out, err := RoundTrip(doc)
+		is.NoErr(err)
+
+		// The canonical form is stable
+		again, err := RoundTrip(out)
+		is.NoErr(err)
+		is.Equal(string(again), string(out))
+
+		// and matches what the token decoder, which trims URLs, sees in the original
+		decoded, err := DecodeVmap(doc)
+		is.NoErr(err)
+		roundTripped, err := DecodeVmap(out)
+		is.NoErr(err)
+		decoded.XMLName = roundTripped.XMLName // the namespace is dropped
+		is.Equal(roundTripped, decoded)
+	}
+
+	doc, err := os.ReadFile("sample-vmap/testVast.xml")
+	is.NoErr(err)
+	out, err := RoundTrip(doc)
+	is.NoErr(err)
+	var original, roundTripped VAST
+	is.NoErr(xml.Unmarshal(doc, &original))
+	is.NoErr(xml.Unmarshal(out, &roundTripped))
+	is.Equal(roundTripped.Ad[0].InLine.Impression[0].Text,
+		strings.TrimSpace(original.Ad[0].InLine.Impression[0].Text))
+}
+
+func TestRoundTripStartAndEnd(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVmap.xml")
+	is.NoErr(err)
+	out, err := RoundTrip(doc)
+	is.NoErr(err)
+	is.True(strings.Contains(string(out), ` timeOffset="start">`))
+	is.True(strings.HasPrefix(string(out), `<VMAP vmap="http://www.iab.net/vmap-1.0" version="1.0"><AdBreak `))
+	is.True(strings.HasSuffix(string(out), `</AdBreak></VMAP>`))
+	is.True(!strings.Contains(string(out), `&#xA;  <AdBreak`))
+
+	doc = []byte(`<VMAP version="1.0">` + "\n  " + `<AdBreak timeOffset="end" breakType="linear" breakId="post">` +
+		`</AdBreak>` + "\n" + `</VMAP>`)
+	out, err = RoundTrip(doc)
+	is.NoErr(err)
+	is.True(strings.Contains(string(out), ` timeOffset="end">`))
+
+	var v VMAP
+	is.NoErr(xml.Unmarshal(out, &v))
+	is.Equal(v.AdBreaks[0].TimeOffset.Position, OffsetEnd)
+}
+
+func TestRoundTripNormalizesDurations(t *testing.T) {
+	is := is.New(t)
+	doc := []byte(`<VAST version="3.0"><Ad id="1"><InLine><Creatives><Creative>` +
+		`<Linear skipoffset="00:00:05.000"><Duration>00:00:30.000</Duration>` +
+		`<TrackingEvents><Tracking event="start">
+			https://example.com/start?a=1&amp;b=2
+		</Tracking></TrackingEvents>` +
+		`</Linear></Creative></Creatives></InLine></Ad></VAST>`)
+
+	out, err := RoundTrip(doc)
+	is.NoErr(err)
+	is.True(strings.Contains(string(out), `<Linear skipoffset="00:00:05"><Duration>00:00:30</Duration>`))
+	is.True(strings.Contains(string(out), `<Tracking event="start">https://example.com/start?a=1&amp;b=2</Tracking>`))
+}
+
+func TestRoundTripErrors(t *testing.T) {
+	is := is.New(t)
+	_, err := RoundTrip([]byte(`<Other/>`))
+	is.Equal(err.Error(), `unsupported root element "Other"`)
+	_, err = RoundTrip([]byte(``))
+	is.True(err != nil)
+	_, err = RoundTrip([]byte(`<VAST><Ad>`))
+	is.True(err != nil)
+}
@@ -1,36 +1,264 @@
 package vmap
 
 import (
+	"cmp"
 	"encoding/xml"
+	"errors"
 	"fmt"
+	"math"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 )
 
 type VMAP struct {
-	XMLName  xml.Name  `xml:"VMAP" json:"xmlName"`
-	Text     string    `xml:",chardata" json:"text"`
-	Vmap     string    `xml:"vmap,attr" json:"vmap"`
-	Version  string    `xml:"version,attr" json:"version"`
-	AdBreaks []AdBreak `xml:"AdBreak" json:"adBreaks"`
+	XMLName    xml.Name       `xml:"VMAP" json:"xmlName"`
+	Text       string         `xml:",chardata" json:"text"`
+	Vmap       string         `xml:"vmap,attr" json:"vmap"`
+	Version    string         `xml:"version,attr" json:"version"`
+	AdBreaks   []AdBreak      `xml:"AdBreak" json:"adBreaks"`
+	Extensions VMAPExtensions `xml:"Extensions,omitempty" json:"extensions"`
+}
+
+// VMAPExtensions lists the vmap:Extension elements of the VMAP root or an
+// AdBreak. Like AdVerifications, the Extensions element is only marshalled
+// when there are extensions.
+type VMAPExtensions []VMAPExtension
+
+func (x *VMAPExtensions) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var p struct {
+		Extension []VMAPExtension `xml:"Extension"`
+	}
+	if err := d.DecodeElement(&p, &start); err != nil {
+		return err
+	}
+	*x = append(*x, p.Extension...)
+	return nil
+}
+
+func (x VMAPExtensions) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(struct {
+		Extension []VMAPExtension `xml:"Extension"`
+	}{x}, start)
+}
+
+// VMAPExtension is a vmap:Extension element, found under the VMAP root or an
+// AdBreak. Its content is vendor specific and is kept as raw XML so that it
+// survives a decode/marshal round trip.
+type VMAPExtension struct {
+	ExtensionType string `xml:"type,attr,omitempty" json:"type"`
+	InnerXML      string `xml:",innerxml" json:"innerXml"`
 }
 
 type AdBreak struct {
 	AdSource       *AdSource       `xml:"AdSource" json:"adSource"`
 	TrackingEvents []TrackingEvent `xml:"TrackingEvents>Tracking" json:"trackingEvents"`
+	Extensions     VMAPExtensions  `xml:"Extensions,omitempty" json:"extensions"`
 	Id             string          `xml:"breakId,attr" json:"id"`
-	BreakType      string          `xml:"breakType,attr" json:"breakType"`
+	BreakType      BreakType       `xml:"breakType,attr" json:"breakType"`
 	TimeOffset     TimeOffset      `xml:"timeOffset,attr" json:"timeOffset"`
+	// RepeatAfter, from VMAP 1.0.1, repeats the break at this interval after TimeOffset.
+	RepeatAfter *Duration `xml:"repeatAfter,attr,omitempty" json:"repeatAfter"`
+	// ExtraAttrs holds the attributes of the AdBreak element that are not
+	// modelled above, such as a vendor's placement id, keyed by name. Only
+	// attributes without a namespace prefix are kept. They are marshalled
+	// after the known attributes, sorted by name; keys that name a modelled
+	// attribute, xmlns or a prefixed attribute are skipped.
+	ExtraAttrs map[string]string `xml:"-" json:"extraAttrs"`
+}
+
+// adBreakAttrs are the modelled attributes of the AdBreak element.
+var adBreakAttrs = []string{"breakId", "breakType", "timeOffset", "repeatAfter"}
+
+// extraAttrNames returns the sorted keys of ExtraAttrs that are marshalled.
+func (ab *AdBreak) extraAttrNames() []string {
+	var names []string
+	for name := range ab.ExtraAttrs {
+		if slices.Contains(adBreakAttrs, name) || name == "xmlns" || strings.Contains(name, ":") {
+			continue
+		}
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func (ab *AdBreak) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain AdBreak
+	p := struct {
+		*plain
+		// AdSources shadows AdSource, which encoding/xml would otherwise
+		// merge silently when it is repeated.
+		AdSources []AdSource `xml:"AdSource"`
+		Attrs     []xml.Attr `xml:",any,attr"`
+	}{plain: (*plain)(ab)}
+	if err := d.DecodeElement(&p, &start); err != nil {
+		return err
+	}
+	switch len(p.AdSources) {
+	case 0:
+	case 1:
+		ab.AdSource = &p.AdSources[0]
+	default:
+		return fmt.Errorf("error parsing AdBreak %q: %w", ab.Id, ErrMultipleAdSources)
+	}
+	for _, attr := range p.Attrs {
+		if attr.Name.Space != "" || attr.Name.Local == "xmlns" {
+			continue
+		}
+		if ab.ExtraAttrs == nil {
+			ab.ExtraAttrs = make(map[string]string)
+		}
+		ab.ExtraAttrs[attr.Name.Local] = attr.Value
+	}
+	return nil
+}
+
+func (ab AdBreak) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type plain AdBreak
+	p := struct {
+		plain
+		Attrs []xml.Attr `xml:",any,attr"`
+	}{plain: plain(ab)}
+	for _, name := range ab.extraAttrNames() {
+		p.Attrs = append(p.Attrs, xml.Attr{Name: xml.Name{Local: name}, Value: ab.ExtraAttrs[name]})
+	}
+	return e.EncodeElement(p, start)
+}
+
+// BreakType is the kind of ad an AdBreak accepts. VMAP allows a comma
+// separated list of types, such as "linear,nonlinear"; use Split to get them.
+type BreakType string
+
+const (
+	BreakTypeLinear    BreakType = "linear"
+	BreakTypeNonLinear BreakType = "nonlinear"
+	BreakTypeDisplay   BreakType = "display"
+)
+
+// Valid reports whether t holds one or more of the break types defined by
+// VMAP and nothing else.
+func (t BreakType) Valid() bool {
+	types := t.Split()
+	if len(types) == 0 {
+		return false
+	}
+	for _, bt := range types {
+		switch bt {
+		case BreakTypeLinear, BreakTypeNonLinear, BreakTypeDisplay:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Split returns the break types in a comma separated list, with surrounding
+// whitespace and empty entries removed.
+func (t BreakType) Split() []BreakType {
+	var types []BreakType
+	for _, s := range strings.Split(string(t), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			types = append(types, BreakType(s))
+		}
+	}
+	return types
 }
 
 type AdSource struct {
-	VASTData *VASTData `xml:"VASTAdData"`
+	VASTData     *VASTData     `xml:"VASTAdData"`
+	AdTagURI     *AdTagURI     `xml:"AdTagURI" json:"adTagURI"`
+	CustomAdData *CustomAdData `xml:"CustomAdData" json:"customAdData"`
 }
 
 type TrackingEvent struct {
 	Event string `xml:"event,attr" json:"event"`
-	Text  string `xml:",chardata" json:"url"`
+	// Offset is the playback position at which a progress event fires, as a
+	// duration or a percentage of the creative. It is only decoded for
+	// progress events and is nil for other events.
+	Offset *TimeOffset `xml:"offset,attr,omitempty" json:"offset"`
+	Text   string      `xml:",chardata" json:"url"`
+}
+
+// Tracking events of VAST linear creatives.
+const (
+	EventCreativeView           = "creativeView"
+	EventStart                  = "start"
+	EventFirstQuartile          = "firstQuartile"
+	EventMidpoint               = "midpoint"
+	EventThirdQuartile          = "thirdQuartile"
+	EventComplete               = "complete"
+	EventMute                   = "mute"
+	EventUnmute                 = "unmute"
+	EventPause                  = "pause"
+	EventResume                 = "resume"
+	EventRewind                 = "rewind"
+	EventSkip                   = "skip"
+	EventProgress               = "progress"
+	EventFullscreen             = "fullscreen"
+	EventExitFullscreen         = "exitFullscreen"
+	EventPlayerExpand           = "playerExpand"
+	EventPlayerCollapse         = "playerCollapse"
+	EventExpand                 = "expand"
+	EventCollapse               = "collapse"
+	EventCloseLinear            = "closeLinear"
+	EventLoaded                 = "loaded"
+	EventNotUsed                = "notUsed"
+	EventOtherAdInteraction     = "otherAdInteraction"
+	EventAcceptInvitationLinear = "acceptInvitationLinear"
+	EventTimeSpentViewing       = "timeSpentViewing"
+)
+
+// Tracking events of VMAP ad breaks.
+const (
+	EventBreakStart = "breakStart"
+	EventBreakEnd   = "breakEnd"
+	EventError      = "error"
+)
+
+// EventVerificationNotExecuted is the tracking event of VAST 4.1
+// verifications, fired with the [REASON] macro when the verification
+// script could not be run.
+const EventVerificationNotExecuted = "verificationNotExecuted"
+
+var linearEvents = map[string]bool{
+	EventCreativeView: true, EventStart: true, EventFirstQuartile: true, EventMidpoint: true,
+	EventThirdQuartile: true, EventComplete: true, EventMute: true, EventUnmute: true,
+	EventPause: true, EventResume: true, EventRewind: true, EventSkip: true,
+	EventProgress: true, EventFullscreen: true, EventExitFullscreen: true,
+	EventPlayerExpand: true, EventPlayerCollapse: true, EventExpand: true,
+	EventCollapse: true, EventCloseLinear: true, EventLoaded: true, EventNotUsed: true,
+	EventOtherAdInteraction: true, EventAcceptInvitationLinear: true, EventTimeSpentViewing: true,
+}
+
+// KnownEvent reports whether Event is one of the VAST linear, VAST
+// verification or VMAP break tracking events. Event names are case sensitive.
+func (t TrackingEvent) KnownEvent() bool {
+	switch t.Event {
+	case EventBreakStart, EventBreakEnd, EventError, EventVerificationNotExecuted:
+		return true
+	}
+	return linearEvents[t.Event]
+}
+
+func (t *TrackingEvent) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain TrackingEvent
+	var p plain
+	progress := slices.ContainsFunc(start.Attr, func(attr xml.Attr) bool {
+		return attr.Name.Local == "event" && attr.Value == EventProgress
+	})
+	if !progress {
+		start.Attr = slices.DeleteFunc(slices.Clone(start.Attr), func(attr xml.Attr) bool {
+			return attr.Name.Local == "offset"
+		})
+	}
+	if err := d.DecodeElement(&p, &start); err != nil {
+		return err
+	}
+	p.Text = strings.TrimSpace(p.Text)
+	*t = TrackingEvent(p)
+	return nil
 }
 
 type VASTData struct {
@@ -38,44 +266,485 @@ type VASTData struct {
 }
 
 type VAST struct {
-	Text                      string `xml:",chardata" json:"text"`
+	Text string `xml:",chardata" json:"text"`
+	// Xmlns is the default namespace of VAST 4 documents, http://www.iab.com/VAST.
+	// Elements are matched by local name, so documents decode the same with
+	// or without it.
+	Xmlns                     string `xml:"xmlns,attr,omitempty" json:"xmlns"`
 	Xsi                       string `xml:"xsi,attr" json:"xsi"`
 	NoNamespaceSchemaLocation string `xml:"noNamespaceSchemaLocation,attr" json:"noNamespaceSchemaLocation"`
 	Version                   string `xml:"version,attr" json:"version"`
 	Ad                        []Ad   `xml:"Ad" json:"ad"`
+	// Error holds the beacons to fire when the response has no ads.
+	Error []Error `xml:"Error" json:"error"`
 }
 
 type Ad struct {
-	Id       string  `xml:"id,attr" json:"id"`
-	Sequence int     `xml:"sequence,attr" json:"sequence"`
-	InLine   *InLine `xml:"InLine" json:"inLine"`
+	Id string `xml:"id,attr" json:"id"`
+	// Sequence is the position of the ad in an ad pod. It is nil for a
+	// stand-alone ad, including one with an empty sequence attribute.
+	Sequence *int   `xml:"sequence,attr,omitempty" json:"sequence"`
+	SeatId   string `xml:"seatId,attr,omitempty" json:"seatId"`
+	// ConditionalAd, from VAST 4.0, marks an ad that the player may decline
+	// depending on conditions it evaluates itself.
+	ConditionalAd *bool `xml:"conditionalAd,attr,omitempty" json:"conditionalAd"`
+	// AdType, from VAST 4.1, is one of AdTypeVideo, AdTypeAudio and AdTypeHybrid.
+	AdType  string   `xml:"adType,attr,omitempty" json:"adType"`
+	InLine  *InLine  `xml:"InLine" json:"inLine"`
+	Wrapper *Wrapper `xml:"Wrapper" json:"wrapper"`
+
+	// WrapperChain holds the wrappers that ResolveWrappers followed to reach
+	// this ad, outermost first. It is not part of the VAST document.
+	WrapperChain []Wrapper `xml:"-" json:"-"`
 }
 
-type AdTagURI struct{}
+func (a *Ad) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain Ad
+	var p plain
+	// Drop an empty sequence, which would otherwise fail to parse as an int.
+	start.Attr = slices.DeleteFunc(slices.Clone(start.Attr), func(attr xml.Attr) bool {
+		return attr.Name.Local == "sequence" && strings.TrimSpace(attr.Value) == ""
+	})
+	if err := d.DecodeElement(&p, &start); err != nil {
+		return err
+	}
+	*a = Ad(p)
+	return nil
+}
+
+// Values of Ad.AdType. An ad without adType is a video ad.
+const (
+	AdTypeVideo  = "video"
+	AdTypeAudio  = "audio"
+	AdTypeHybrid = "hybrid"
+)
+
+// AdTagURI is an ad source that points at a VAST document on a remote ad server.
+// The URI is trimmed on decode and written as CDATA on marshal.
+type AdTagURI struct {
+	TemplateType string `xml:"templateType,attr" json:"templateType"`
+	URI          string `xml:",cdata" json:"uri"`
+}
+
+func (a *AdTagURI) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain AdTagURI
+	var p plain
+	if err := d.DecodeElement(&p, &start); err != nil {
+		return err
+	}
+	p.URI = strings.TrimSpace(p.URI)
+	*a = AdTagURI(p)
+	return nil
+}
+
+// CustomAdData is an ad source in a format other than VAST, as named by
+// TemplateType. Its content is kept verbatim.
+type CustomAdData struct {
+	TemplateType string `xml:"templateType,attr" json:"templateType"`
+	Data         string `xml:",innerxml" json:"data"`
+}
 
 type InLine struct {
-	AdSystem   string       `xml:"AdSystem" json:"adSystem"`
-	AdTitle    string       `xml:"AdTitle" json:"adTitle"`
-	Impression []Impression `xml:"Impression" json:"impression"`
-	Creatives  []Creative   `xml:"Creatives>Creative" json:"creatives"`
-	Extensions []Extension  `xml:"Extensions>Extension" json:"extensions"`
-	Error      *Error       `xml:"Error" json:"error"`
+	AdSystem           *AdSystem           `xml:"AdSystem" json:"adSystem"`
+	AdTitle            string              `xml:"AdTitle" json:"adTitle"`
+	AdServingId        string              `xml:"AdServingId,omitempty" json:"adServingId"`
+	Impression         []Impression        `xml:"Impression" json:"impression"`
+	Categories         []Category          `xml:"Category" json:"categories"`
+	Description        CDATAText           `xml:"Description,omitempty" json:"description"`
+	Advertiser         *Advertiser         `xml:"Advertiser,omitempty" json:"advertiser"`
+	Pricing            *Pricing            `xml:"Pricing" json:"pricing"`
+	Survey             *Survey             `xml:"Survey" json:"survey"`
+	ViewableImpression *ViewableImpression `xml:"ViewableImpression" json:"viewableImpression"`
+	AdVerifications    AdVerifications     `xml:"AdVerifications,omitempty" json:"adVerifications"`
+	Creatives          []Creative          `xml:"Creatives>Creative" json:"creatives"`
+	Extensions         []Extension         `xml:"Extensions>Extension" json:"extensions"`
+	Error              []Error             `xml:"Error" json:"error"`
+}
+
+// Wrapper is an ad that redirects to another VAST document at VASTAdTagURI.
+// Its impressions, tracking and extensions apply to the ad that is finally
+// returned by the chain of ad servers.
+type Wrapper struct {
+	AdSystem     *AdSystem    `xml:"AdSystem" json:"adSystem"`
+	VASTAdTagURI string       `xml:"VASTAdTagURI" json:"vastAdTagURI"`
+	Error        []Error      `xml:"Error" json:"error"`
+	Impression   []Impression `xml:"Impression" json:"impression"`
+	// BlockedAdCategories, from VAST 4.1, lists categories the wrapped ad must not belong to.
+	BlockedAdCategories []BlockedAdCategory `xml:"BlockedAdCategories" json:"blockedAdCategories"`
+	Pricing             *Pricing            `xml:"Pricing" json:"pricing"`
+	AdVerifications     AdVerifications     `xml:"AdVerifications,omitempty" json:"adVerifications"`
+	Creatives           []WrapperCreative   `xml:"Creatives>Creative" json:"creatives"`
+	Extensions          []Extension         `xml:"Extensions>Extension" json:"extensions"`
+
+	// Control attributes from VAST 3 and 4, nil when absent. They tell a
+	// resolver whether to follow further wrappers, whether the next ad
+	// server may return a pod, and whether to fall back when it returns no ad.
+	FollowAdditionalWrappers *bool `xml:"followAdditionalWrappers,attr,omitempty" json:"followAdditionalWrappers"`
+	AllowMultipleAds         *bool `xml:"allowMultipleAds,attr,omitempty" json:"allowMultipleAds"`
+	FallbackOnNoAd           *bool `xml:"fallbackOnNoAd,attr,omitempty" json:"fallbackOnNoAd"`
+}
+
+func (w *Wrapper) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain Wrapper
+	var p plain
+	if err := d.DecodeElement(&p, &start); err != nil {
+		return err
+	}
+	p.VASTAdTagURI = strings.TrimSpace(p.VASTAdTagURI)
+	*w = Wrapper(p)
+	return nil
+}
+
+// BlockedAdCategory is a comma separated list of category codes, from the
+// taxonomy identified by Authority, that an ad server must not return.
+type BlockedAdCategory struct {
+	Authority string `xml:"authority,attr" json:"authority"`
+	Value     string `xml:",chardata" json:"value"`
+}
+
+// Codes returns the trimmed category codes of the list.
+func (b BlockedAdCategory) Codes() []string {
+	var codes []string
+	for _, code := range strings.Split(b.Value, ",") {
+		if code = strings.TrimSpace(code); code != "" {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+// WrapperCreative is a creative of a Wrapper. It carries no media, only
+// tracking to be added to the creative of the wrapped ad.
+type WrapperCreative struct {
+	Id           string         `xml:"id,attr" json:"id"`
+	AdId         string         `xml:"adId,attr" json:"adId"`
+	Sequence     int            `xml:"sequence,attr,omitempty" json:"sequence"`
+	Linear       *WrapperLinear `xml:"Linear" json:"linear"`
+	CompanionAds *CompanionAds  `xml:"CompanionAds" json:"companionAds"`
+}
+
+// WrapperLinear holds the tracking that a Wrapper adds to a linear creative.
+type WrapperLinear struct {
+	TrackingEvents []TrackingEvent `xml:"TrackingEvents>Tracking" json:"trackingEvents"`
+	ClickTracking  []ClickTracking `xml:"VideoClicks>ClickTracking" json:"clickTracking"`
+}
+
+// CompanionAds is a placeholder for the companions of a creative. Companions
+// are not modelled yet, so the content is kept as raw XML.
+type CompanionAds struct {
+	Required string `xml:"required,attr,omitempty" json:"required"`
+	InnerXML string `xml:",innerxml" json:"innerXml"`
+}
+
+// AdSystem names the ad server that returned the ad, with an optional version.
+type AdSystem struct {
+	Version string `xml:"version,attr,omitempty" json:"version"`
+	Name    string `xml:",chardata" json:"name"`
+}
+
+// String returns the name of the ad server, or an empty string if a is nil.
+func (a *AdSystem) String() string {
+	if a == nil {
+		return ""
+	}
+	return a.Name
+}
+
+// Survey points at a survey for the ad, such as a brand-lift study. It was
+// removed in VAST 4.3. Type is the MIME type of the resource. The URI is
+// trimmed on decode and written as CDATA on marshal.
+type Survey struct {
+	Type string `xml:"type,attr,omitempty" json:"type"`
+	URI  string `xml:",cdata" json:"uri"`
+}
+
+func (s *Survey) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain Survey
+	var p plain
+	if err := d.DecodeElement(&p, &start); err != nil {
+		return err
+	}
+	p.URI = strings.TrimSpace(p.URI)
+	*s = Survey(p)
+	return nil
+}
+
+// CDATAText is free text that is trimmed on decode and written as a CDATA
+// section on marshal, so that markup and entities in it survive unchanged.
+type CDATAText string
+
+func (t *CDATAText) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var s string
+	if err := d.DecodeElement(&s, &start); err != nil {
+		return err
+	}
+	*t = CDATAText(strings.TrimSpace(s))
+	return nil
+}
+
+func (t CDATAText) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(struct {
+		Text string `xml:",cdata"`
+	}{string(t)}, start)
+}
+
+// Advertiser names the advertiser of the ad, with an optional id, e.g. from
+// a registry of advertisers. The name is trimmed on decode.
+type Advertiser struct {
+	Id   string `xml:"id,attr,omitempty" json:"id"`
+	Name string `xml:",chardata" json:"name"`
+}
+
+func (a *Advertiser) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain Advertiser
+	var p plain
+	if err := d.DecodeElement(&p, &start); err != nil {
+		return err
+	}
+	p.Name = strings.TrimSpace(p.Name)
+	*a = Advertiser(p)
+	return nil
+}
+
+// String returns the name of the advertiser, or an empty string if a is nil.
+func (a *Advertiser) String() string {
+	if a == nil {
+		return ""
+	}
+	return a.Name
 }
 
 type Error struct {
 	Value string `xml:",chardata" json:"value"`
 }
 
+func (e *Error) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain Error
+	var p plain
+	if err := d.DecodeElement(&p, &start); err != nil {
+		return err
+	}
+	p.Value = strings.TrimSpace(p.Value)
+	*e = Error(p)
+	return nil
+}
+
+// Category is a content classification code of the ad, e.g. from the IAB
+// content taxonomy identified by Authority.
+type Category struct {
+	Authority string `xml:"authority,attr" json:"authority"`
+	Value     string `xml:",chardata" json:"value"`
+}
+
+// Pricing is the price of the impression, used in programmatic reporting.
+type Pricing struct {
+	// Model is one of the PricingModel constants. Ad servers differ in case.
+	Model string `xml:"model,attr" json:"model"`
+	// Currency is an ISO 4217 currency code such as "USD".
+	Currency string `xml:"currency,attr" json:"currency"`
+	// Value is the price as written in the document, trimmed, so that it is
+	// marshalled without rounding. Use ParseValue to get it as a number.
+	Value string `xml:",chardata" json:"value"`
+}
+
+// Values of Pricing.Model.
+const (
+	PricingModelCPM = "cpm"
+	PricingModelCPC = "cpc"
+	PricingModelCPE = "cpe"
+	PricingModelCPV = "cpv"
+)
+
+func (p *Pricing) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain Pricing
+	var raw plain
+	if err := d.DecodeElement(&raw, &start); err != nil {
+		return err
+	}
+	raw.Value = strings.TrimSpace(raw.Value)
+	*p = Pricing(raw)
+	return nil
+}
+
+// ParseValue returns the price as a float64.
+func (p Pricing) ParseValue() (float64, error) {
+	v, err := strconv.ParseFloat(strings.TrimSpace(p.Value), 64)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing pricing value: %w", err)
+	}
+	return v, nil
+}
+
+// knownModel reports whether Model is one of the PricingModel constants,
+// ignoring case.
+func (p Pricing) knownModel() bool {
+	for _, m := range []string{PricingModelCPM, PricingModelCPC, PricingModelCPE, PricingModelCPV} {
+		if strings.EqualFold(strings.TrimSpace(p.Model), m) {
+			return true
+		}
+	}
+	return false
+}
+
 type Impression struct {
 	Id   string `xml:"id,attr" json:"id"`
 	Text string `xml:",chardata" json:"url"`
 }
 
+func (imp *Impression) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain Impression
+	var p plain
+	if err := d.DecodeElement(&p, &start); err != nil {
+		return err
+	}
+	p.Text = strings.TrimSpace(p.Text)
+	*imp = Impression(p)
+	return nil
+}
+
+// ViewableImpression, from VAST 4.0, holds the URLs to request once the
+// viewability of the ad impression has been measured, as viewable, not
+// viewable or undetermined. The URLs are trimmed on decode.
+type ViewableImpression struct {
+	Id               string   `xml:"id,attr,omitempty" json:"id"`
+	Viewable         []string `xml:"Viewable" json:"viewable"`
+	NotViewable      []string `xml:"NotViewable" json:"notViewable"`
+	ViewUndetermined []string `xml:"ViewUndetermined" json:"viewUndetermined"`
+}
+
+func (vi *ViewableImpression) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain ViewableImpression
+	var p plain
+	if err := d.DecodeElement(&p, &start); err != nil {
+		return err
+	}
+	for _, urls := range [][]string{p.Viewable, p.NotViewable, p.ViewUndetermined} {
+		for i := range urls {
+			urls[i] = strings.TrimSpace(urls[i])
+		}
+	}
+	*vi = ViewableImpression(p)
+	return nil
+}
+
+// AdVerifications, from VAST 4.0, lists the Open Measurement verifications
+// of an ad. Unlike Creatives, the AdVerifications element is only marshalled
+// when there are verifications, so that documents of earlier VAST versions
+// do not gain it.
+type AdVerifications []Verification
+
+func (a *AdVerifications) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var p struct {
+		Verification []Verification `xml:"Verification"`
+	}
+	if err := d.DecodeElement(&p, &start); err != nil {
+		return err
+	}
+	*a = append(*a, p.Verification...)
+	return nil
+}
+
+func (a AdVerifications) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(struct {
+		Verification []Verification `xml:"Verification"`
+	}{a}, start)
+}
+
+// Verification is a script of a measurement vendor that the player hands to
+// the Open Measurement SDK, together with its parameters.
+type Verification struct {
+	Vendor              string               `xml:"vendor,attr,omitempty" json:"vendor"`
+	JavaScriptResources []JavaScriptResource `xml:"JavaScriptResource" json:"javaScriptResources"`
+	ExecutableResources []ExecutableResource `xml:"ExecutableResource" json:"executableResources"`
+	// TrackingEvents holds verificationNotExecuted trackers.
+	TrackingEvents         []TrackingEvent        `xml:"TrackingEvents>Tracking" json:"trackingEvents"`
+	VerificationParameters VerificationParameters `xml:"VerificationParameters,omitempty" json:"verificationParameters"`
+}
+
+// VerificationParameters is opaque data for a verification script, often
+// JSON. Unlike CDATAText it is kept verbatim, whitespace included, and it is
+// written as a CDATA section on marshal.
+type VerificationParameters string
+
+func (p VerificationParameters) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return CDATAText(p).MarshalXML(e, start)
+}
+
+// JavaScriptResource is the URL of a verification script run in a web view.
+type JavaScriptResource struct {
+	Text            string `xml:",chardata" json:"url"`
+	ApiFramework    string `xml:"apiFramework,attr,omitempty" json:"apiFramework"`
+	BrowserOptional *bool  `xml:"browserOptional,attr,omitempty" json:"browserOptional"`
+}
+
+func (r *JavaScriptResource) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain JavaScriptResource
+	var p plain
+	if err := d.DecodeElement(&p, &start); err != nil {
+		return err
+	}
+	p.Text = strings.TrimSpace(p.Text)
+	*r = JavaScriptResource(p)
+	return nil
+}
+
+// ExecutableResource is the URL of a verification script run natively.
+type ExecutableResource struct {
+	Text         string `xml:",chardata" json:"url"`
+	ApiFramework string `xml:"apiFramework,attr,omitempty" json:"apiFramework"`
+	MediaType    string `xml:"type,attr,omitempty" json:"mediaType"`
+}
+
+func (r *ExecutableResource) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain ExecutableResource
+	var p plain
+	if err := d.DecodeElement(&p, &start); err != nil {
+		return err
+	}
+	p.Text = strings.TrimSpace(p.Text)
+	*r = ExecutableResource(p)
+	return nil
+}
+
 type Creative struct {
-	Id            string         `xml:"id,attr" json:"id"`
-	AdId          string         `xml:"adId,attr" json:"adId"`
-	UniversalAdId *UniversalAdId `xml:"UniversalAdId" json:"universalAdId"`
-	Linear        *Linear        `xml:"Linear" json:"linear"`
+	Id             string          `xml:"id,attr" json:"id"`
+	AdId           string          `xml:"adId,attr" json:"adId"`
+	Sequence       int             `xml:"sequence,attr,omitempty" json:"sequence"`
+	UniversalAdIds []UniversalAdId `xml:"UniversalAdId" json:"universalAdIds"`
+	// CreativeExtensions holds vendor specific data for this creative only,
+	// unlike the Extensions of the InLine.
+	CreativeExtensions CreativeExtensions `xml:"CreativeExtensions,omitempty" json:"creativeExtensions"`
+	Linear             *Linear            `xml:"Linear" json:"linear"`
+}
+
+// CreativeExtensions lists the CreativeExtension elements of a creative.
+// Like AdVerifications, the CreativeExtensions element is only marshalled
+// when there are extensions.
+type CreativeExtensions []CreativeExtension
+
+func (x *CreativeExtensions) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var p struct {
+		CreativeExtension []CreativeExtension `xml:"CreativeExtension"`
+	}
+	if err := d.DecodeElement(&p, &start); err != nil {
+		return err
+	}
+	*x = append(*x, p.CreativeExtension...)
+	return nil
+}
+
+func (x CreativeExtensions) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(struct {
+		CreativeExtension []CreativeExtension `xml:"CreativeExtension"`
+	}{x}, start)
+}
+
+// CreativeExtension keeps the content of a CreativeExtension element as raw
+// XML, since its format is defined by the vendor. All decoders keep the
+// content byte for byte, and it is marshalled verbatim.
+type CreativeExtension struct {
+	ExtensionType string `xml:"type,attr,omitempty" json:"type"`
+	InnerXML      string `xml:",innerxml" json:"innerXml"`
 }
 
 type UniversalAdId struct {
@@ -84,12 +753,27 @@ type UniversalAdId struct {
 }
 
 type Linear struct {
-	Duration       Duration        `xml:"Duration" json:"duration"`
-	TrackingEvents []TrackingEvent `xml:"TrackingEvents>Tracking" json:"trackingEvents"`
-	MediaFiles     []MediaFile     `xml:"MediaFiles>MediaFile" json:"mediaFiles"`
-	ClickThrough   *ClickThrough   `xml:"VideoClicks>ClickThrough" json:"clickThrough"`
-	ClickTracking  []ClickTracking `xml:"VideoClicks>ClickTracking" json:"clickTracking"`
-	CustomClick    []CustomClick   `xml:"VideoClicks>CustomClick" json:"customClick"`
+	Duration                 Duration                  `xml:"Duration" json:"duration"`
+	AdParameters             *AdParameters             `xml:"AdParameters" json:"adParameters"`
+	TrackingEvents           []TrackingEvent           `xml:"TrackingEvents>Tracking" json:"trackingEvents"`
+	MediaFiles               []MediaFile               `xml:"MediaFiles>MediaFile" json:"mediaFiles"`
+	Mezzanine                []Mezzanine               `xml:"MediaFiles>Mezzanine" json:"mezzanine"`
+	InteractiveCreativeFiles []InteractiveCreativeFile `xml:"MediaFiles>InteractiveCreativeFile" json:"interactiveCreativeFiles"` //nolint:lll
+	ClickThrough             *ClickThrough             `xml:"VideoClicks>ClickThrough" json:"clickThrough"`
+	ClickTracking            []ClickTracking           `xml:"VideoClicks>ClickTracking" json:"clickTracking"`
+	CustomClick              []CustomClick             `xml:"VideoClicks>CustomClick" json:"customClick"`
+	Icons                    Icons                     `xml:"Icons,omitempty" json:"icons"`
+	SkipOffset               *TimeOffset               `xml:"skipoffset,attr,omitempty" json:"skipOffset"`
+}
+
+// AdParameters holds the data that the player passes to a VPAID or SIMID
+// creative when it is initialized. The payload is kept verbatim and always
+// written as CDATA, split over two sections where it contains "]]>". All
+// decoders join such adjacent sections again.
+type AdParameters struct {
+	// XMLEncoded is set when the payload is XML encoded data.
+	XMLEncoded *bool  `xml:"xmlEncoded,attr,omitempty" json:"xmlEncoded"`
+	Value      string `xml:",cdata" json:"value"`
 }
 
 type ClickThrough struct {
@@ -97,24 +781,323 @@ type ClickThrough struct {
 	Text string `xml:",chardata" json:"url"`
 }
 
+func (ct *ClickThrough) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain ClickThrough
+	var p plain
+	if err := d.DecodeElement(&p, &start); err != nil {
+		return err
+	}
+	p.Text = strings.TrimSpace(p.Text)
+	*ct = ClickThrough(p)
+	return nil
+}
+
 type ClickTracking struct {
 	Id   string `xml:"id,attr" json:"id"`
 	Text string `xml:",chardata" json:"url"`
 }
 
+func (ct *ClickTracking) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain ClickTracking
+	var p plain
+	if err := d.DecodeElement(&p, &start); err != nil {
+		return err
+	}
+	p.Text = strings.TrimSpace(p.Text)
+	*ct = ClickTracking(p)
+	return nil
+}
+
 type CustomClick struct {
 	Id   string `xml:"id,attr" json:"id"`
 	Text string `xml:",chardata" json:"url"`
 }
 
+func (cc *CustomClick) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain CustomClick
+	var p plain
+	if err := d.DecodeElement(&p, &start); err != nil {
+		return err
+	}
+	p.Text = strings.TrimSpace(p.Text)
+	*cc = CustomClick(p)
+	return nil
+}
+
+// Delivery is how a media file is delivered to the player.
+type Delivery string
+
+const (
+	DeliveryProgressive Delivery = "progressive"
+	DeliveryStreaming   Delivery = "streaming"
+)
+
+// Valid reports whether d is one of the delivery methods defined by VAST.
+func (d Delivery) Valid() bool {
+	return d == DeliveryProgressive || d == DeliveryStreaming
+}
+
 type MediaFile struct {
-	Text      string `xml:",chardata" json:"text"`
-	Bitrate   int    `xml:"bitrate,attr" json:"bitrate"`
-	Width     int    `xml:"width,attr" json:"width"`
-	Height    int    `xml:"height,attr" json:"height"`
-	Delivery  string `xml:"delivery,attr" json:"delivery"`
-	MediaType string `xml:"type,attr" json:"mediaType"`
-	Codec     string `xml:"codec,attr" json:"codec"`
+	Text      string   `xml:",chardata" json:"text"`
+	Bitrate   int      `xml:"bitrate,attr" json:"bitrate"`
+	Width     int      `xml:"width,attr" json:"width"`
+	Height    int      `xml:"height,attr" json:"height"`
+	Delivery  Delivery `xml:"delivery,attr" json:"delivery"`
+	MediaType string   `xml:"type,attr" json:"mediaType"`
+	Codec     string   `xml:"codec,attr" json:"codec"`
+	Id        string   `xml:"id,attr,omitempty" json:"id"`
+	// MinBitrate and MaxBitrate, in kbps, give the range of a streaming
+	// media file instead of Bitrate. See BitrateRange.
+	MinBitrate          int    `xml:"minBitrate,attr,omitempty" json:"minBitrate"`
+	MaxBitrate          int    `xml:"maxBitrate,attr,omitempty" json:"maxBitrate"`
+	Scalable            *bool  `xml:"scalable,attr,omitempty" json:"scalable"`
+	MaintainAspectRatio *bool  `xml:"maintainAspectRatio,attr,omitempty" json:"maintainAspectRatio"`
+	ApiFramework        string `xml:"apiFramework,attr,omitempty" json:"apiFramework"`
+	// FileSize is the size of the file in bytes.
+	FileSize int `xml:"fileSize,attr,omitempty" json:"fileSize"`
+}
+
+func (m *MediaFile) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain MediaFile
+	var p plain
+	// Leave booleans that do not parse nil rather than failing the document.
+	start.Attr = slices.DeleteFunc(slices.Clone(start.Attr), func(a xml.Attr) bool {
+		if a.Name.Local != "scalable" && a.Name.Local != "maintainAspectRatio" {
+			return false
+		}
+		_, err := parseBool([]byte(a.Value))
+		return err != nil
+	})
+	if err := d.DecodeElement(&p, &start); err != nil {
+		return err
+	}
+	p.Text = strings.TrimSpace(p.Text)
+	*m = MediaFile(p)
+	return nil
+}
+
+// Mezzanine is the raw, high quality media file that VAST 4 servers provide
+// for transcoding by server-side stitchers.
+type Mezzanine struct {
+	Text      string   `xml:",chardata" json:"url"`
+	Id        string   `xml:"id,attr,omitempty" json:"id"`
+	Delivery  Delivery `xml:"delivery,attr" json:"delivery"`
+	MediaType string   `xml:"type,attr" json:"mediaType"`
+	Width     int      `xml:"width,attr" json:"width"`
+	Height    int      `xml:"height,attr" json:"height"`
+	Codec     string   `xml:"codec,attr,omitempty" json:"codec"`
+	FileSize  int      `xml:"fileSize,attr,omitempty" json:"fileSize"`
+}
+
+func (m *Mezzanine) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain Mezzanine
+	var p plain
+	if err := d.DecodeElement(&p, &start); err != nil {
+		return err
+	}
+	p.Text = strings.TrimSpace(p.Text)
+	*m = Mezzanine(p)
+	return nil
+}
+
+// InteractiveCreativeFile is a VAST 4 file for the interactive layer of a
+// linear ad, such as a SIMID creative.
+type InteractiveCreativeFile struct {
+	Text         string `xml:",chardata" json:"url"`
+	MediaType    string `xml:"type,attr,omitempty" json:"mediaType"`
+	ApiFramework string `xml:"apiFramework,attr,omitempty" json:"apiFramework"`
+	// VariableDuration tells whether the interactive creative may extend the
+	// duration of the ad, e.g. while the viewer interacts with it.
+	VariableDuration *bool `xml:"variableDuration,attr,omitempty" json:"variableDuration"`
+}
+
+// APIFrameworkSIMID is the apiFramework of interactive creative files that
+// follow the IAB Secure Interactive Media Interface Definition.
+const APIFrameworkSIMID = "SIMID"
+
+func (f *InteractiveCreativeFile) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain InteractiveCreativeFile
+	var p plain
+	// Leave a variableDuration that does not parse nil, like MediaFile does.
+	start.Attr = slices.DeleteFunc(slices.Clone(start.Attr), func(a xml.Attr) bool {
+		if a.Name.Local != "variableDuration" {
+			return false
+		}
+		_, err := parseBool([]byte(a.Value))
+		return err != nil
+	})
+	if err := d.DecodeElement(&p, &start); err != nil {
+		return err
+	}
+	p.Text = strings.TrimSpace(p.Text)
+	*f = InteractiveCreativeFile(p)
+	return nil
+}
+
+// Icons lists the icons of a linear creative, such as the AdChoices icon.
+// Like AdVerifications, the Icons element is only marshalled when there are
+// icons.
+type Icons []Icon
+
+func (ic *Icons) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var p struct {
+		Icon []Icon `xml:"Icon"`
+	}
+	if err := d.DecodeElement(&p, &start); err != nil {
+		return err
+	}
+	*ic = append(*ic, p.Icon...)
+	return nil
+}
+
+func (ic Icons) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(struct {
+		Icon []Icon `xml:"Icon"`
+	}{ic}, start)
+}
+
+// Icon is an icon shown over a linear creative, e.g. the AdChoices icon of
+// the "AdChoices" program. It is displayed from Offset into the creative for
+// Duration, or for the whole creative if they are nil.
+type Icon struct {
+	Program string `xml:"program,attr,omitempty" json:"program"`
+	Width   int    `xml:"width,attr,omitempty" json:"width"`
+	Height  int    `xml:"height,attr,omitempty" json:"height"`
+	// XPosition and YPosition are pixel offsets from the left and top of the
+	// video, or an edge: "left" or "right", and "top" or "bottom". See
+	// ParseIconPosition.
+	XPosition        string           `xml:"xPosition,attr,omitempty" json:"xPosition"`
+	YPosition        string           `xml:"yPosition,attr,omitempty" json:"yPosition"`
+	Duration         *Duration        `xml:"duration,attr,omitempty" json:"duration"`
+	Offset           *TimeOffset      `xml:"offset,attr,omitempty" json:"offset"`
+	StaticResources  []StaticResource `xml:"StaticResource" json:"staticResources"`
+	IFrameResources  []IFrameResource `xml:"IFrameResource" json:"iFrameResources"`
+	HTMLResources    []HTMLResource   `xml:"HTMLResource" json:"htmlResources"`
+	IconClicks       *IconClicks      `xml:"IconClicks" json:"iconClicks"`
+	IconViewTracking []string         `xml:"IconViewTracking" json:"iconViewTracking"`
+}
+
+func (ic *Icon) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain Icon
+	var p plain
+	if err := d.DecodeElement(&p, &start); err != nil {
+		return err
+	}
+	for i := range p.IconViewTracking {
+		p.IconViewTracking[i] = strings.TrimSpace(p.IconViewTracking[i])
+	}
+	*ic = Icon(p)
+	return nil
+}
+
+// IconClicks holds the landing page of an icon and the URLs to fire when it
+// is clicked.
+type IconClicks struct {
+	IconClickThrough  string   `xml:"IconClickThrough,omitempty" json:"iconClickThrough"`
+	IconClickTracking []string `xml:"IconClickTracking" json:"iconClickTracking"`
+}
+
+func (icc *IconClicks) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain IconClicks
+	var p plain
+	if err := d.DecodeElement(&p, &start); err != nil {
+		return err
+	}
+	p.IconClickThrough = strings.TrimSpace(p.IconClickThrough)
+	for i := range p.IconClickTracking {
+		p.IconClickTracking[i] = strings.TrimSpace(p.IconClickTracking[i])
+	}
+	*icc = IconClicks(p)
+	return nil
+}
+
+// Icon positions that name an edge of the video rather than a pixel offset.
+const (
+	IconPositionLeft   = "left"
+	IconPositionRight  = "right"
+	IconPositionTop    = "top"
+	IconPositionBottom = "bottom"
+)
+
+// IconPosition is a parsed Icon.XPosition or Icon.YPosition.
+type IconPosition struct {
+	// Edge is one of the IconPosition constants, or empty for a pixel offset.
+	Edge string
+	// Pixels is the offset from the left or top of the video when Edge is empty.
+	Pixels int
+}
+
+// ParseIconPosition parses an icon position: a non-negative number of pixels
+// or one of "left", "right", "top" and "bottom".
+func ParseIconPosition(s string) (IconPosition, error) {
+	s = strings.TrimSpace(s)
+	switch s {
+	case IconPositionLeft, IconPositionRight, IconPositionTop, IconPositionBottom:
+		return IconPosition{Edge: s}, nil
+	}
+	px, err := strconv.Atoi(s)
+	if err != nil || px < 0 {
+		return IconPosition{}, fmt.Errorf("invalid icon position %q", s)
+	}
+	return IconPosition{Pixels: px}, nil
+}
+
+// Position parses the XPosition and YPosition of the icon. It fails if either
+// is invalid, or names an edge along the wrong axis, e.g. xPosition="top".
+func (ic *Icon) Position() (x, y IconPosition, err error) {
+	if x, err = ParseIconPosition(ic.XPosition); err != nil {
+		return x, y, err
+	}
+	if x.Edge == IconPositionTop || x.Edge == IconPositionBottom {
+		return x, y, fmt.Errorf("invalid icon xPosition %q", ic.XPosition)
+	}
+	if y, err = ParseIconPosition(ic.YPosition); err != nil {
+		return x, y, err
+	}
+	if y.Edge == IconPositionLeft || y.Edge == IconPositionRight {
+		return x, y, fmt.Errorf("invalid icon yPosition %q", ic.YPosition)
+	}
+	return x, y, nil
+}
+
+// StaticResource is a URL to a static creative file, such as an image, of the
+// given MIME type. It is shared by the resource based elements of VAST.
+type StaticResource struct {
+	CreativeType string `xml:"creativeType,attr" json:"creativeType"`
+	URL          string `xml:",chardata" json:"url"`
+}
+
+func (r *StaticResource) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain StaticResource
+	var p plain
+	if err := d.DecodeElement(&p, &start); err != nil {
+		return err
+	}
+	p.URL = strings.TrimSpace(p.URL)
+	*r = StaticResource(p)
+	return nil
+}
+
+// IFrameResource is a URL to an HTML page to be displayed in an iframe.
+type IFrameResource struct {
+	URL string `xml:",chardata" json:"url"`
+}
+
+func (r *IFrameResource) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain IFrameResource
+	var p plain
+	if err := d.DecodeElement(&p, &start); err != nil {
+		return err
+	}
+	p.URL = strings.TrimSpace(p.URL)
+	*r = IFrameResource(p)
+	return nil
+}
+
+// HTMLResource is an HTML snippet to be inserted into the page. The content
+// is always written as CDATA so the markup survives marshalling verbatim.
+type HTMLResource struct {
+	HTML string `xml:",cdata" json:"html"`
 }
 
 // NOTE: Specifically built for FreeWheel's CreativeParamer extension at the moment.
@@ -132,14 +1115,25 @@ type CreativeParameter struct {
 
 type Duration struct{ time.Duration }
 
+// UnmarshalText parses a duration in the format HH:MM:SS or HH:MM:SS.mmm. The
+// fraction of a second may have fewer digits, so that "00:00:05.5" is 5.5
+// seconds, or more, down to nanoseconds.
 func (d *Duration) UnmarshalText(data []byte) error {
 	var parts [4]int
 	currentPart := 0
+	// fractionDigits counts the digits of the fraction of a second in parts[3].
+	fractionDigits := 0
 
 	for i := 0; i < len(data); i++ {
 		b := data[i]
 		switch {
 		case b >= '0' && b <= '9':
+			if currentPart == 3 {
+				if fractionDigits == 9 {
+					continue
+				}
+				fractionDigits++
+			}
 			parts[currentPart] = parts[currentPart]*10 + int(b-'0')
 		case b == ':' || b == '.':
 			currentPart++
@@ -153,10 +1147,13 @@ func (d *Duration) UnmarshalText(data []byte) error {
 		return fmt.Errorf("invalid duration format: %s", string(data))
 	}
 
+	for ; fractionDigits < 9; fractionDigits++ {
+		parts[3] *= 10
+	}
 	d.Duration = time.Duration(parts[0])*time.Hour +
 		time.Duration(parts[1])*time.Minute +
 		time.Duration(parts[2])*time.Second +
-		time.Duration(parts[3])*time.Millisecond
+		time.Duration(parts[3])
 	return nil
 }
 
@@ -177,6 +1174,45 @@ func (d Duration) MarshalText() ([]byte, error) {
 	return []byte(sb.String()), nil
 }
 
+// ErrInvalidFrameRate is returned by NewDurationFromFrames for a frame rate
+// that is not positive.
+var ErrInvalidFrameRate = errors.New("frame rate must be positive")
+
+// frameEpsilon absorbs the nanosecond rounding of NewDurationFromFrames, so
+// that Frames returns the frame count a duration was made from.
+const frameEpsilon = 1e-6
+
+// Frames returns the number of whole frames at fps frames per second that
+// fit in d, e.g. 299 for ten seconds at 29.97. It returns 0 if fps is not
+// positive.
+func (d Duration) Frames(fps float64) int {
+	if fps <= 0 {
+		return 0
+	}
+	return int(d.Seconds()*fps + frameEpsilon)
+}
+
+// NewDurationFromFrames returns the duration of frames frames at fps frames
+// per second, rounded to the nearest nanosecond. It returns
+// ErrInvalidFrameRate if fps is not positive.
+func NewDurationFromFrames(frames int, fps float64) (Duration, error) {
+	if fps <= 0 {
+		return Duration{}, ErrInvalidFrameRate
+	}
+	return Duration{time.Duration(math.Round(float64(frames) / fps * float64(time.Second)))}, nil
+}
+
+// NewDurationFromSeconds returns the duration of s seconds, e.g. a player's
+// current time, rounded to the nearest nanosecond.
+func NewDurationFromSeconds(s float64) Duration {
+	return Duration{time.Duration(math.Round(s * float64(time.Second)))}
+}
+
+// NewDurationFromMilliseconds returns the duration of ms milliseconds.
+func NewDurationFromMilliseconds(ms int) Duration {
+	return Duration{time.Duration(ms) * time.Millisecond}
+}
+
 // TimeOffset represents the time offset for an ad break in the VMAP document.
 type TimeOffset struct {
 	// If this is not nil, we're dealing with a duration offset.
@@ -205,11 +1241,15 @@ func (to *TimeOffset) UnmarshalText(data []byte) error {
 		return nil
 	}
 	if strings.HasSuffix(string(data), "%") {
-		p, err := strconv.ParseInt(strings.TrimSuffix(string(data), "%"), 10, 8)
+		// MarshalText writes percentages with decimals, e.g. "25.000000%".
+		p, err := strconv.ParseFloat(strings.TrimSuffix(string(data), "%"), 32)
 		if err != nil {
 			return fmt.Errorf("error parsing percentage offset: %w", err)
 		}
-		to.Percent = float32(p) / 100
+		if math.IsNaN(p) || math.IsInf(p, 0) {
+			return fmt.Errorf("invalid percentage offset: %s", string(data))
+		}
+		to.Percent = float32(p / 100)
 		return nil
 	}
 	if strings.HasPrefix(string(data), "#") {
@@ -229,7 +1269,13 @@ func (to TimeOffset) MarshalText() ([]byte, error) {
 	if to.Duration != nil {
 		return to.Duration.MarshalText()
 	}
-	if to.Position != 0 {
+	switch to.Position {
+	case 0:
+	case OffsetStart:
+		return []byte("start"), nil
+	case OffsetEnd:
+		return []byte("end"), nil
+	default:
 		return []byte(fmt.Sprintf("#%d", to.Position)), nil
 	}
 	if to.Percent != 0 {
@@ -237,3 +1283,76 @@ func (to TimeOffset) MarshalText() ([]byte, error) {
 	}
 	return []byte(""), nil
 }
+
+// Kinds of TimeOffset, in the order Compare places them when they cannot be
+// compared by value.
+const (
+	offsetKindStart = iota
+	offsetKindDuration
+	offsetKindPercent
+	offsetKindPosition
+	offsetKindEnd
+)
+
+func (to TimeOffset) kind() int {
+	switch {
+	case to.Duration != nil:
+		return offsetKindDuration
+	case to.Position == OffsetStart:
+		return offsetKindStart
+	case to.Position == OffsetEnd:
+		return offsetKindEnd
+	case to.Position != 0:
+		return offsetKindPosition
+	}
+	return offsetKindPercent
+}
+
+// Compare returns -1, 0 or +1 depending on whether to comes before, at the
+// same time as, or after other in playback order. Start comes before and end
+// after every other offset. Offsets of the same kind compare by value, but
+// durations, percentages and positions cannot be compared with each other
+// without knowing the content, so they are ordered by kind in that order.
+func (to TimeOffset) Compare(other TimeOffset) int {
+	k, ok := to.kind(), other.kind()
+	if k != ok {
+		return cmp.Compare(k, ok)
+	}
+	switch k {
+	case offsetKindDuration:
+		return cmp.Compare(to.Duration.Duration, other.Duration.Duration)
+	case offsetKindPercent:
+		return cmp.Compare(to.Percent, other.Percent)
+	case offsetKindPosition:
+		return cmp.Compare(to.Position, other.Position)
+	}
+	return 0
+}
+
+// ToSeconds returns the offset as a number of seconds into the content.
+// Percentage and end offsets are resolved against contentDuration. Position
+// offsets refer to chapters outside the VMAP document and return an error.
+func (to TimeOffset) ToSeconds(contentDuration Duration) (float64, error) {
+	d, err := to.resolve(contentDuration.Duration)
+	if err != nil {
+		return 0, err
+	}
+	return d.Seconds(), nil
+}
+
+// resolve returns the time into the content at which the offset is, with
+// percentage and end offsets resolved against contentDuration. It is the
+// conversion behind ToSeconds, AdBreaksInRange and ResolveOffsets.
+func (to TimeOffset) resolve(contentDuration time.Duration) (time.Duration, error) {
+	switch to.kind() {
+	case offsetKindStart:
+		return 0, nil
+	case offsetKindDuration:
+		return to.Duration.Duration, nil
+	case offsetKindPercent:
+		return time.Duration(float64(contentDuration) * float64(to.Percent)), nil
+	case offsetKindEnd:
+		return contentDuration, nil
+	}
+	return 0, fmt.Errorf("cannot convert position offset #%d to a time", to.Position)
+}
@@ -3,6 +3,7 @@ package vmap
 import (
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -30,7 +31,7 @@ func TestUnmarshalVMAP(t *testing.T) {
 	is.Equal(len(vmap.AdBreaks), 3)
 	firstBreak := vmap.AdBreaks[0]
 	is.Equal(firstBreak.Id, "midroll.ad-1")
-	is.Equal(firstBreak.BreakType, "linear")
+	is.Equal(firstBreak.BreakType, BreakTypeLinear)
 	is.True(firstBreak.TimeOffset.Duration == nil)
 	is.Equal(firstBreak.TimeOffset.Position, OffsetStart)
 	is.True(firstBreak.AdSource.VASTData.VAST != nil)
@@ -38,14 +39,14 @@ func TestUnmarshalVMAP(t *testing.T) {
 
 	secondBreak := vmap.AdBreaks[1]
 	is.Equal(secondBreak.Id, "midroll.ad-2")
-	is.Equal(secondBreak.BreakType, "linear")
+	is.Equal(secondBreak.BreakType, BreakTypeLinear)
 	is.Equal(*secondBreak.TimeOffset.Duration, Duration{5 * time.Minute})
 	is.True(firstBreak.AdSource.VASTData.VAST != nil)
 	is.Equal(len(secondBreak.TrackingEvents), 1)
 
 	thirdBreak := vmap.AdBreaks[2]
 	is.Equal(thirdBreak.Id, "midroll.ad-3")
-	is.Equal(thirdBreak.BreakType, "linear")
+	is.Equal(thirdBreak.BreakType, BreakTypeLinear)
 	is.Equal(*thirdBreak.TimeOffset.Duration, Duration{7 * time.Minute})
 	is.True(thirdBreak.AdSource.VASTData.VAST != nil)
 	is.Equal(len(thirdBreak.TrackingEvents), 1)
@@ -101,7 +102,7 @@ func TestDecodeVmap(t *testing.T) {
 	is.Equal(len(vmap.AdBreaks), 3)
 	firstBreak := vmap.AdBreaks[0]
 	is.Equal(firstBreak.Id, "midroll.ad-1")
-	is.Equal(firstBreak.BreakType, "linear")
+	is.Equal(firstBreak.BreakType, BreakTypeLinear)
 	is.True(firstBreak.TimeOffset.Duration == nil)
 	is.Equal(firstBreak.TimeOffset.Position, OffsetStart)
 	is.True(firstBreak.AdSource.VASTData.VAST != nil)
@@ -109,14 +110,14 @@ func TestDecodeVmap(t *testing.T) {
 
 	secondBreak := vmap.AdBreaks[1]
 	is.Equal(secondBreak.Id, "midroll.ad-2")
-	is.Equal(secondBreak.BreakType, "linear")
+	is.Equal(secondBreak.BreakType, BreakTypeLinear)
 	is.Equal(*secondBreak.TimeOffset.Duration, Duration{5 * time.Minute})
 	is.True(firstBreak.AdSource.VASTData.VAST != nil)
 	is.Equal(len(secondBreak.TrackingEvents), 1)
 
 	thirdBreak := vmap.AdBreaks[2]
 	is.Equal(thirdBreak.Id, "midroll.ad-3")
-	is.Equal(thirdBreak.BreakType, "linear")
+	is.Equal(thirdBreak.BreakType, BreakTypeLinear)
 	is.Equal(*thirdBreak.TimeOffset.Duration, Duration{7 * time.Minute})
 	is.True(thirdBreak.AdSource.VASTData.VAST != nil)
 	is.Equal(len(thirdBreak.TrackingEvents), 1)
@@ -138,13 +139,13 @@ func TestUnmarshalVast(t *testing.T) {
 	firstAd := vast.Ad[0]
 	is.Equal(firstAd.Id, "POD_AD-ID_001")
 	firstAdInLine := firstAd.InLine
-	is.Equal(firstAdInLine.AdSystem, "Test Adserver")
+	is.Equal(firstAdInLine.AdSystem.String(), "Test Adserver")
 	is.Equal(firstAdInLine.AdTitle, "Ad That Test-Adserver Wants Player To See #1")
 
 	// Error validation
 	firstAdError := firstAdInLine.Error
-	is.True(firstAdError != nil)
-	is.Equal(firstAdError.Value, "https://error-url/code")
+	is.Equal(len(firstAdError), 1)
+	is.Equal(firstAdError[0].Value, "https://error-url/code")
 	// Extension validation
 	firstAdExtensions := firstAdInLine.Extensions
 	is.Equal(len(firstAdExtensions), 1)
@@ -175,7 +176,7 @@ func TestUnmarshalVast(t *testing.T) {
 	is.Equal(mediaFile.Width, 718)
 	is.Equal(mediaFile.Height, 404)
 	is.Equal(mediaFile.MediaType, "video/mp4")
-	is.Equal(mediaFile.Delivery, "progressive")
+	is.Equal(mediaFile.Delivery, DeliveryProgressive)
 	is.Equal(mediaFile.Bitrate, 1300)
 	is.Equal(mediaFile.Codec, "H.264")
 }
@@ -196,13 +197,13 @@ func TestDecodeVast(t *testing.T) {
 	firstAd := vast.Ad[0]
 	is.Equal(firstAd.Id, "POD_AD-ID_001")
 	firstAdInLine := firstAd.InLine
-	is.Equal(firstAdInLine.AdSystem, "Test Adserver")
+	is.Equal(firstAdInLine.AdSystem.String(), "Test Adserver")
 	is.Equal(firstAdInLine.AdTitle, "Ad That Test-Adserver Wants Player To See #1")
 
 	// Error validation
 	firstAdError := firstAdInLine.Error
-	is.True(firstAdError != nil)
-	is.Equal(firstAdError.Value, "https://error-url/code")
+	is.Equal(len(firstAdError), 1)
+	is.Equal(firstAdError[0].Value, "https://error-url/code")
 	// Extension validation
 	firstAdExtensions := firstAdInLine.Extensions
 	is.Equal(len(firstAdExtensions), 1)
@@ -233,11 +234,24 @@ func TestDecodeVast(t *testing.T) {
 	is.Equal(mediaFile.Width, 718)
 	is.Equal(mediaFile.Height, 404)
 	is.Equal(mediaFile.MediaType, "video/mp4")
-	is.Equal(mediaFile.Delivery, "progressive")
+	is.Equal(mediaFile.Delivery, DeliveryProgressive)
 	is.Equal(mediaFile.Bitrate, 1300)
 	is.Equal(mediaFile.Codec, "H.264")
 }
 
+func TestBreakType(t *testing.T) {
+	is := is.New(t)
+	is.True(BreakTypeLinear.Valid())
+	is.True(BreakType("linear, nonlinear").Valid())
+	is.True(!BreakType("").Valid())
+	is.True(!BreakType("linear,video").Valid())
+	is.True(!BreakType("Linear").Valid())
+
+	is.Equal(BreakType(" linear, nonlinear,,display ").Split(),
+		[]BreakType{BreakTypeLinear, BreakTypeNonLinear, BreakTypeDisplay})
+	is.Equal(BreakType("").Split(), nil)
+}
+
 func TestUnmarshalDuration(t *testing.T) {
 	is := is.New(t)
 	d := Duration{}
@@ -257,6 +271,22 @@ func TestUnmarshalDuration(t *testing.T) {
 	is.NoErr(err)
 	is.Equal(d.Duration, 4*time.Hour+1*time.Minute+12*time.Second+345*time.Millisecond)
 
+	for text, want := range map[string]time.Duration{
+		"00:00:05.5":           5500 * time.Millisecond,
+		"00:00:05.50":          5500 * time.Millisecond,
+		"00:00:05.500":         5500 * time.Millisecond,
+		"00:00:05.05":          5050 * time.Millisecond,
+		"00:00:05.005":         5005 * time.Millisecond,
+		"00:00:05.0005":        5000500 * time.Microsecond,
+		"00:00:05.123456789":   5123456789 * time.Nanosecond,
+		"00:00:05.12345678999": 5123456789 * time.Nanosecond,
+		"00:00:05.":            5 * time.Second,
+	} {
+		err = d.UnmarshalText([]byte(text))
+		is.NoErr(err)
+		is.Equal(d.Duration, want)
+	}
+
 	err = d.UnmarshalText([]byte("01:04:01:12.345"))
 	is.True(err != nil)
 
@@ -264,6 +294,68 @@ func TestUnmarshalDuration(t *testing.T) {
 	is.True(err != nil)
 }
 
+func TestUnmarshalTimeOffsetPercent(t *testing.T) {
+	is := is.New(t)
+	for text, want := range map[string]float32{
+		"10%":        0.1,
+		"25.000000%": 0.25,
+		"12.5%":      0.125,
+		"150%":       1.5,
+	} {
+		var to TimeOffset
+		is.NoErr(to.UnmarshalText([]byte(text)))
+		is.Equal(to.Percent, want)
+	}
+	for _, text := range []string{"%", "abc%", "NaN%", "Inf%", "-Inf%"} {
+		var to TimeOffset
+		is.True(to.UnmarshalText([]byte(text)) != nil)
+	}
+}
+
+func TestDurationFrames(t *testing.T) {
+	is := is.New(t)
+	tenSeconds := Duration{10 * time.Second}
+	for fps, want := range map[float64]int{
+		23.976: 239, 24: 240, 25: 250, 29.97: 299, 30: 300, 50: 500, 59.94: 599, 60: 600,
+	} {
+		is.Equal(tenSeconds.Frames(fps), want)
+
+		d, err := NewDurationFromFrames(want, fps)
+		is.NoErr(err)
+		is.Equal(d.Frames(fps), want)
+		is.True(d.Duration <= tenSeconds.Duration)
+	}
+
+	d, err := NewDurationFromFrames(30000, 30000.0/1001)
+	is.NoErr(err)
+	is.Equal(d.Duration, 1001*time.Second)
+	d, err = NewDurationFromFrames(25, 25)
+	is.NoErr(err)
+	is.Equal(d.Duration, time.Second)
+
+	is.Equal(tenSeconds.Frames(0), 0)
+	is.Equal(tenSeconds.Frames(-25), 0)
+	_, err = NewDurationFromFrames(25, 0)
+	is.True(errors.Is(err, ErrInvalidFrameRate))
+}
+
+func TestNewDurationFromSeconds(t *testing.T) {
+	is := is.New(t)
+	is.Equal(NewDurationFromSeconds(0).Duration, time.Duration(0))
+	is.Equal(NewDurationFromSeconds(12.5).Duration, 12500*time.Millisecond)
+	is.Equal(NewDurationFromSeconds(0.1).Duration, 100*time.Millisecond)
+	text, err := NewDurationFromSeconds(300.123).MarshalText()
+	is.NoErr(err)
+	is.Equal(string(text), "00:05:00.123")
+	is.Equal(NewDurationFromSeconds(-1.5).Duration, -1500*time.Millisecond)
+
+	is.Equal(NewDurationFromMilliseconds(0).Duration, time.Duration(0))
+	is.Equal(NewDurationFromMilliseconds(1500).Duration, 1500*time.Millisecond)
+	text, err = NewDurationFromMilliseconds(3723004).MarshalText()
+	is.NoErr(err)
+	is.Equal(string(text), "01:02:03.004")
+}
+
 func TestMarshalJson(t *testing.T) {
 	is := is.New(t)
 	f, err := os.Open("sample-vmap/testVmap.xml")
@@ -285,6 +377,26 @@ func TestMarshalJson(t *testing.T) {
 	is.Equal(vmap, vmap2)
 }
 
+func TestSkipOffsetJSON(t *testing.T) {
+	is := is.New(t)
+	for _, tc := range []struct {
+		skip *TimeOffset
+		json string
+	}{
+		{&TimeOffset{Duration: &Duration{5 * time.Second}}, `"skipOffset":"00:00:05"`},
+		{&TimeOffset{Percent: 0.25}, `"skipOffset":"25.000000%"`},
+		{nil, `"skipOffset":null`},
+	} {
+		data, err := json.Marshal(Linear{SkipOffset: tc.skip})
+		is.NoErr(err)
+		is.True(strings.Contains(string(data), tc.json))
+
+		var l Linear
+		is.NoErr(json.Unmarshal(data, &l))
+		is.Equal(l.SkipOffset, tc.skip)
+	}
+}
+
 func BenchmarkUnmarshal(b *testing.B) {
 	doc, err := os.ReadFile("sample-vmap/testVmap.xml")
 	if err != nil {
@@ -362,7 +474,8 @@ func TestDecodeVmapScan(t *testing.T) {
 			is.Equal(v1.Ad[j].Sequence, v2.Ad[j].Sequence)
 			if v1.Ad[j].InLine != nil {
 				is.True(v2.Ad[j].InLine != nil)
-				is.Equal(strings.TrimSpace(v1.Ad[j].InLine.AdSystem), strings.TrimSpace(v2.Ad[j].InLine.AdSystem))
+				is.Equal(strings.TrimSpace(v1.Ad[j].InLine.AdSystem.String()),
+					strings.TrimSpace(v2.Ad[j].InLine.AdSystem.String()))
 				is.Equal(strings.TrimSpace(v1.Ad[j].InLine.AdTitle), strings.TrimSpace(v2.Ad[j].InLine.AdTitle))
 				is.Equal(v1.Ad[j].InLine.Error, v2.Ad[j].InLine.Error)
 				is.Equal(len(v1.Ad[j].InLine.Creatives), len(v2.Ad[j].InLine.Creatives))
@@ -390,7 +503,7 @@ func TestDecodeVastScan(t *testing.T) {
 		is.Equal(a.Sequence, b.Sequence)
 		if a.InLine != nil {
 			is.True(b.InLine != nil)
-			is.Equal(strings.TrimSpace(a.InLine.AdSystem), strings.TrimSpace(b.InLine.AdSystem))
+			is.Equal(strings.TrimSpace(a.InLine.AdSystem.String()), strings.TrimSpace(b.InLine.AdSystem.String()))
 			is.Equal(strings.TrimSpace(a.InLine.AdTitle), strings.TrimSpace(b.InLine.AdTitle))
 			is.Equal(a.InLine.Error, b.InLine.Error)
 			is.Equal(len(a.InLine.Impression), len(b.InLine.Impression))
@@ -444,232 +557,2067 @@ func TestSpecialCharacters(t *testing.T) {
 	is.Equal(vastDecoded.Ad[0].InLine.AdTitle, "Hej&ö\n<>\"")
 }
 
-// --- Fast Marshal Tests ---
-
-func TestMarshalVmapFast(t *testing.T) {
+func TestDecodeVmapExtensions(t *testing.T) {
 	is := is.New(t)
-	doc, err := os.ReadFile("sample-vmap/testVmap.xml")
+	doc, err := os.ReadFile("sample-vmap/testVmapExtensions.xml")
 	is.NoErr(err)
 
-	var v VMAP
-	err = xml.Unmarshal(doc, &v)
+	const session = `<yospace:Session xmlns:yospace="http://www.yospace.com/extension" id="abc-123">` +
+		`<yospace:Analytics url="https://analytics.example.com/?a=1&amp;b=2"/><![CDATA[raw & unescaped]]>` +
+		`</yospace:Session>`
+
+	var unmarshaled VMAP
+	err = xml.Unmarshal(doc, &unmarshaled)
+	is.NoErr(err)
+	decoded, err := DecodeVmap(doc)
+	is.NoErr(err)
+	scanned, err := DecodeVmapScan(doc)
 	is.NoErr(err)
 
-	expected, err := xml.Marshal(v)
+	for _, v := range []VMAP{unmarshaled, decoded, scanned} {
+		is.Equal(len(v.AdBreaks), 1)
+		is.Equal(len(v.Extensions), 2)
+		is.Equal(v.Extensions[0].ExtensionType, "YospaceSession")
+		is.Equal(v.Extensions[0].InnerXML, session)
+		is.Equal(v.Extensions[1].ExtensionType, "")
+		is.Equal(v.Extensions[1].InnerXML, "<Note>untyped</Note>")
+	}
+}
+
+func TestDecodePricing(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVastPricing.xml")
 	is.NoErr(err)
 
-	got, err := MarshalVmap(&v)
+	var unmarshaled VAST
+	err = xml.Unmarshal(doc, &unmarshaled)
+	is.NoErr(err)
+	decoded, err := DecodeVast(doc)
+	is.NoErr(err)
+	scanned, err := DecodeVastScan(doc)
 	is.NoErr(err)
 
-	is.Equal(string(expected), string(got))
+	for _, v := range []VAST{unmarshaled, decoded, scanned} {
+		is.Equal(len(v.Ad), 3)
+		is.Equal(*v.Ad[0].InLine.Pricing, Pricing{Model: "CPM", Currency: "USD", Value: "25.50"})
+		is.Equal(*v.Ad[1].InLine.Pricing, Pricing{Model: "cpc", Currency: "EUR", Value: "1.2"})
+		is.Equal(*v.Ad[2].Wrapper.Pricing, Pricing{Model: "cpv", Currency: "SEK", Value: "0.100"})
+		price, err := v.Ad[0].InLine.Pricing.ParseValue()
+		is.NoErr(err)
+		is.Equal(price, 25.5)
+	}
+
+	// the value is kept as text and only checked by ParseValue and Validate
+	invalid := []byte(`<VAST version="3.0"><Ad><InLine>` +
+		`<Pricing model="cpm" currency="USD">free</Pricing>` +
+		`</InLine></Ad></VAST>`)
+	var free VAST
+	err = xml.Unmarshal(invalid, &free)
+	is.NoErr(err)
+	_, err = free.Ad[0].InLine.Pricing.ParseValue()
+	is.True(strings.Contains(err.Error(), "error parsing pricing value"))
+	_, err = DecodeVast(invalid)
+	is.NoErr(err)
 }
 
-func TestMarshalVmapEmptyFast(t *testing.T) {
+func TestDecodeAdBreakExtensions(t *testing.T) {
 	is := is.New(t)
-	doc, err := os.ReadFile("sample-vmap/testVmap2.xml")
+	doc, err := os.ReadFile("sample-vmap/testVmapBreakExtensions.xml")
 	is.NoErr(err)
 
-	var v VMAP
-	err = xml.Unmarshal(doc, &v)
-	is.NoErr(err)
+	const targeting = `<Targeting><Key name="genre">drama</Key><Key name="pod">2</Key></Targeting>`
 
-	expected, err := xml.Marshal(v)
+	var unmarshaled VMAP
+	err = xml.Unmarshal(doc, &unmarshaled)
+	is.NoErr(err)
+	decoded, err := DecodeVmap(doc)
+	is.NoErr(err)
+	scanned, err := DecodeVmapScan(doc)
 	is.NoErr(err)
 
-	got, err := MarshalVmap(&v)
+	for _, v := range []VMAP{unmarshaled, decoded, scanned} {
+		is.Equal(len(v.Extensions), 0)
+		is.Equal(len(v.AdBreaks), 1)
+		ab := v.AdBreaks[0]
+		is.Equal(len(ab.TrackingEvents), 1)
+		is.Equal(len(ab.Extensions), 1)
+		is.Equal(ab.Extensions[0].ExtensionType, "targeting")
+		is.Equal(ab.Extensions[0].InnerXML, targeting)
+	}
+
+	jsonBytes, err := json.Marshal(scanned)
+	is.NoErr(err)
+	var fromJSON VMAP
+	err = json.Unmarshal(jsonBytes, &fromJSON)
 	is.NoErr(err)
+	is.Equal(fromJSON.AdBreaks[0].Extensions, scanned.AdBreaks[0].Extensions)
+}
 
-	is.Equal(string(expected), string(got))
+func TestDecodeVast4MediaFiles(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVast4MediaFiles.xml")
+	is.NoErr(err)
+
+	var unmarshaled VAST
+	err = xml.Unmarshal(doc, &unmarshaled)
+	is.NoErr(err)
+	decoded, err := DecodeVast(doc)
+	is.NoErr(err)
+	scanned, err := DecodeVastScan(doc)
+	is.NoErr(err)
+
+	yes := true
+	for _, v := range []VAST{unmarshaled, decoded, scanned} {
+		linear := v.Ad[0].InLine.Creatives[0].Linear
+		is.Equal(len(linear.MediaFiles), 1)
+		is.Equal(linear.MediaFiles[0].Width, 1280)
+		is.Equal(len(linear.Mezzanine), 1)
+		is.Equal(linear.Mezzanine[0], Mezzanine{
+			Text:      "https://cdn.example.com/ads/vast4-30s-mezzanine.mp4",
+			Id:        "MEZZ_001",
+			Delivery:  "progressive",
+			MediaType: "video/mp4",
+			Width:     1920,
+			Height:    1080,
+			Codec:     "H.264",
+			FileSize:  104857600,
+		})
+		is.Equal(len(linear.InteractiveCreativeFiles), 1)
+		is.Equal(linear.InteractiveCreativeFiles[0], InteractiveCreativeFile{
+			Text:             "https://cdn.example.com/ads/simid/index.html",
+			MediaType:        "text/html",
+			ApiFramework:     APIFrameworkSIMID,
+			VariableDuration: &yes,
+		})
+	}
+
+	// empty numeric attributes decode to 0, as encoding/xml does
+	doc = []byte(`<VAST version="4.1"><Ad id="1"><InLine><Creatives><Creative><Linear><MediaFiles>` +
+		`<Mezzanine delivery="progressive" type="video/mp4" width="" height="" fileSize="">` +
+		`https://cdn.example.com/m.mp4</Mezzanine>` +
+		`</MediaFiles></Linear></Creative></Creatives></InLine></Ad></VAST>`)
+	unmarshaled = VAST{}
+	is.NoErr(xml.Unmarshal(doc, &unmarshaled))
+	decoded, err = DecodeVast(doc)
+	is.NoErr(err)
+	scanned, err = DecodeVastScan(doc)
+	is.NoErr(err)
+	for _, v := range []VAST{unmarshaled, decoded, scanned} {
+		is.Equal(v.Ad[0].InLine.Creatives[0].Linear.Mezzanine, []Mezzanine{{
+			Text:      "https://cdn.example.com/m.mp4",
+			Delivery:  "progressive",
+			MediaType: "video/mp4",
+		}})
+	}
 }
 
-func TestMarshalVmapEmptyVastFast(t *testing.T) {
+func TestDecodeSkipOffset(t *testing.T) {
 	is := is.New(t)
-	doc, err := os.ReadFile("sample-vmap/testVmapEmptyVast.xml")
+	doc, err := os.ReadFile("sample-vmap/testVastSkippable.xml")
 	is.NoErr(err)
 
-	var v VMAP
-	err = xml.Unmarshal(doc, &v)
+	var unmarshaled VAST
+	err = xml.Unmarshal(doc, &unmarshaled)
 	is.NoErr(err)
-
-	expected, err := xml.Marshal(v)
+	decoded, err := DecodeVast(doc)
 	is.NoErr(err)
-
-	got, err := MarshalVmap(&v)
+	scanned, err := DecodeVastScan(doc)
 	is.NoErr(err)
 
-	is.Equal(string(expected), string(got))
+	for _, v := range []VAST{unmarshaled, decoded, scanned} {
+		durationOffset := v.Ad[0].InLine.Creatives[0].Linear.SkipOffset
+		is.True(durationOffset != nil)
+		is.Equal(*durationOffset.Duration, Duration{5 * time.Second})
+
+		percentOffset := v.Ad[1].InLine.Creatives[0].Linear.SkipOffset
+		is.True(percentOffset != nil)
+		is.True(percentOffset.Duration == nil)
+		is.Equal(percentOffset.Percent, float32(0.25))
+
+		is.True(v.Ad[1].InLine.Creatives[1].Linear.SkipOffset == nil)
+	}
 }
 
-func TestMarshalVastFast(t *testing.T) {
+func TestDecodeCategories(t *testing.T) {
 	is := is.New(t)
-	doc, err := os.ReadFile("sample-vmap/testVast.xml")
+	doc, err := os.ReadFile("sample-vmap/testVastCategory.xml")
 	is.NoErr(err)
 
-	var v VAST
-	err = xml.Unmarshal(doc, &v)
+	var unmarshaled VAST
+	err = xml.Unmarshal(doc, &unmarshaled)
 	is.NoErr(err)
-
-	expected, err := xml.Marshal(v)
+	decoded, err := DecodeVast(doc)
+	is.NoErr(err)
+	scanned, err := DecodeVastScan(doc)
 	is.NoErr(err)
 
-	got, err := MarshalVast(&v)
+	for _, v := range []VAST{unmarshaled, decoded, scanned} {
+		inline := v.Ad[0].InLine
+		is.Equal(len(inline.Categories), 3)
+		is.Equal(inline.Categories[0], Category{Authority: "https://www.iab.com/categories", Value: "IAB1-5"})
+		is.True(inline.HasCategory("https://www.iab.com/categories", "IAB19"))
+		is.True(inline.HasCategory("https://example.com/brand-safety", "family-safe"))
+		is.True(!inline.HasCategory("https://example.com/brand-safety", "IAB19"))
+	}
+
+	js, err := json.Marshal(decoded.Ad[0].InLine.Categories[0])
 	is.NoErr(err)
+	is.Equal(string(js), `{"authority":"https://www.iab.com/categories","value":"IAB1-5"}`)
 
-	is.Equal(string(expected), string(got))
+	var nilInLine *InLine
+	is.True(!nilInLine.HasCategory("https://www.iab.com/categories", "IAB1-5"))
 }
 
-func TestMarshalVastEmptyFast(t *testing.T) {
+func TestDecodeAdTagURI(t *testing.T) {
 	is := is.New(t)
-	doc, err := os.ReadFile("sample-vmap/testVast3.xml")
+	doc, err := os.ReadFile("sample-vmap/testVmapAdTagURI.xml")
 	is.NoErr(err)
 
-	var v VAST
-	err = xml.Unmarshal(doc, &v)
-	is.NoErr(err)
+	const preroll = "https://pubads.g.doubleclick.net/gampad/ads?slotname=/124319096/external/ad_rule_samples" +
+		"&sz=640x480&ciu_szs=300x250&cust_params=deployment%3Ddevsite%26sample_ar%3Dpremidpost&url=" +
+		"&unviewed_position_start=1&output=xml_vast3&impl=s&env=vp&gdfp_req=1&ad_rule=0&vad_type=linear" +
+		"&vpos=preroll&pod=1&ppos=1&lip=true&min_ad_duration=0&max_ad_duration=30000&vrid=6256&cmsid=496" +
+		"&video_doc_id=short_onecue&kfa=0&tfcd=0&correlator=[CACHEBUSTING]"
+	const midroll = "https://pubads.g.doubleclick.net/gampad/ads?slotname=/124319096/external/ad_rule_samples" +
+		"&sz=640x480&output=xml_vast3&vpos=midroll&pod=2&ppos=1&correlator=[CACHEBUSTING]"
 
-	expected, err := xml.Marshal(v)
+	var unmarshaled VMAP
+	err = xml.Unmarshal(doc, &unmarshaled)
 	is.NoErr(err)
-
-	got, err := MarshalVast(&v)
+	decoded, err := DecodeVmap(doc)
+	is.NoErr(err)
+	scanned, err := DecodeVmapScan(doc)
 	is.NoErr(err)
 
-	is.Equal(string(expected), string(got))
+	for _, v := range []VMAP{unmarshaled, decoded, scanned} {
+		is.Equal(len(v.AdBreaks), 2)
+		for _, ab := range v.AdBreaks {
+			is.True(ab.AdSource != nil)
+			is.True(ab.AdSource.VASTData == nil)
+			is.Equal(ab.AdSource.AdTagURI.TemplateType, "vast3")
+		}
+		is.Equal(v.AdBreaks[0].AdSource.AdTagURI.URI, preroll)
+		is.Equal(v.AdBreaks[1].AdSource.AdTagURI.URI, midroll)
+		is.Equal(len(v.Validate()), 0)
+	}
+
+	// Marshaled output wraps the URI in CDATA and decodes to the same URIs
+	out, err := MarshalVmap(&unmarshaled)
+	is.NoErr(err)
+	is.True(strings.Contains(string(out), `<AdTagURI templateType="vast3"><![CDATA[`+midroll+`]]></AdTagURI>`))
+	var roundTripped VMAP
+	err = xml.Unmarshal(out, &roundTripped)
+	is.NoErr(err)
+	is.Equal(roundTripped.AdBreaks[0].AdSource.AdTagURI, unmarshaled.AdBreaks[0].AdSource.AdTagURI)
+	is.Equal(roundTripped.AdBreaks[1].AdSource.AdTagURI, unmarshaled.AdBreaks[1].AdSource.AdTagURI)
 }
 
-func TestMarshalVast2Fast(t *testing.T) {
+func TestDecodeAdvertiserAndDescription(t *testing.T) {
 	is := is.New(t)
-	doc, err := os.ReadFile("sample-vmap/testVast2.xml")
+	doc, err := os.ReadFile("sample-vmap/testVastAdvertiser.xml")
 	is.NoErr(err)
 
-	var v VAST
-	err = xml.Unmarshal(doc, &v)
+	var unmarshaled VAST
+	err = xml.Unmarshal(doc, &unmarshaled)
 	is.NoErr(err)
-
-	expected, err := xml.Marshal(v)
+	decoded, err := DecodeVast(doc)
+	is.NoErr(err)
+	scanned, err := DecodeVastScan(doc)
 	is.NoErr(err)
 
-	got, err := MarshalVast(&v)
+	for _, v := range []VAST{unmarshaled, decoded, scanned} {
+		first := v.Ad[0].InLine
+		is.Equal(*first.Advertiser, Advertiser{Id: "acme-001", Name: "Acme Corp"})
+		is.Equal(first.Description, CDATAText("Thirty second spot for the spring campaign"))
+		is.True(first.HasAdvertiser())
+		is.True(first.HasDescription())
+
+		second := v.Ad[1].InLine
+		is.Equal(second.Advertiser, nil)
+		is.True(!second.HasAdvertiser())
+		is.True(!second.HasDescription())
+	}
+
+	js, err := json.Marshal(decoded.Ad[0].InLine.Advertiser)
 	is.NoErr(err)
+	is.Equal(string(js), `{"id":"acme-001","name":"Acme Corp"}`)
+	is.True(!(&InLine{Advertiser: &Advertiser{Id: "acme-001"}}).HasAdvertiser())
 
-	is.Equal(string(expected), string(got))
+	var nilInLine *InLine
+	is.True(!nilInLine.HasAdvertiser())
+	is.True(!nilInLine.HasDescription())
 }
 
-func TestMarshalSpecialCharsFast(t *testing.T) {
+func TestDecodeSurvey(t *testing.T) {
 	is := is.New(t)
-	doc, err := os.ReadFile("sample-vmap/testVastSpecialChars.xml")
+	doc, err := os.ReadFile("sample-vmap/testVastAdvertiser.xml")
 	is.NoErr(err)
 
-	var v VAST
-	err = xml.Unmarshal(doc, &v)
+	var unmarshaled VAST
+	err = xml.Unmarshal(doc, &unmarshaled)
+	is.NoErr(err)
+	decoded, err := DecodeVast(doc)
+	is.NoErr(err)
+	scanned, err := DecodeVastScan(doc)
 	is.NoErr(err)
 
-	expected, err := xml.Marshal(v)
+	for _, v := range []VAST{unmarshaled, decoded, scanned} {
+		is.Equal(*v.Ad[0].InLine.Survey, Survey{Type: "text/html", URI: "https://survey.example.com/lift?ad=1"})
+		is.Equal(*v.Ad[1].InLine.Survey, Survey{URI: "https://survey.example.com/lift?ad=2&s=1"})
+	}
+
+	plain, err := os.ReadFile("sample-vmap/testVast.xml")
+	is.NoErr(err)
+	noSurvey, err := DecodeVast(plain)
 	is.NoErr(err)
+	is.Equal(noSurvey.Ad[0].InLine.Survey, nil)
+}
 
-	got, err := MarshalVast(&v)
+func TestDecodeViewableImpression(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVastViewable.xml")
 	is.NoErr(err)
 
-	is.Equal(string(expected), string(got))
-}
+	var unmarshaled VAST
+	err = xml.Unmarshal(doc, &unmarshaled)
+	is.NoErr(err)
+	decoded, err := DecodeVast(doc)
+	is.NoErr(err)
+	scanned, err := DecodeVastScan(doc)
+	is.NoErr(err)
 
-// --- Fast Marshal Benchmarks ---
+	for _, v := range []VAST{unmarshaled, decoded, scanned} {
+		is.Equal(*v.Ad[0].InLine.ViewableImpression, ViewableImpression{
+			Id: "VIEWABLE-ID_001",
+			Viewable: []string{
+				"https://viewability.example.com/viewable?ad=1",
+				"https://adserver.example.com/viewable?ad=1&v=1",
+			},
+			NotViewable:      []string{"https://viewability.example.com/notviewable?ad=1"},
+			ViewUndetermined: []string{"https://viewability.example.com/undetermined?ad=1"},
+		})
+		is.Equal(*v.Ad[1].InLine.ViewableImpression, ViewableImpression{Id: "VIEWABLE-ID_002"})
+		is.Equal(len(v.Ad[1].InLine.Creatives), 0)
+	}
 
-func BenchmarkXMLMarshalVmap(b *testing.B) {
-	doc, err := os.ReadFile("sample-vmap/testVmap.xml")
-	if err != nil {
-		b.Fatal(err)
+	js, err := json.Marshal(decoded.Ad[0].InLine.ViewableImpression)
+	is.NoErr(err)
+	is.True(strings.Contains(string(js), `"notViewable":["https://viewability.example.com/notviewable?ad=1"]`))
+}
+
+func TestDecodeAdVerifications(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVastAdVerifications.xml")
+	is.NoErr(err)
+
+	var unmarshaled VAST
+	err = xml.Unmarshal(doc, &unmarshaled)
+	is.NoErr(err)
+	decoded, err := DecodeVast(doc)
+	is.NoErr(err)
+	scanned, err := DecodeVastScan(doc)
+	is.NoErr(err)
+
+	yes, no := true, false
+	want := AdVerifications{
+		{
+			Vendor: "integralads.com-omid",
+			JavaScriptResources: []JavaScriptResource{{
+				Text:            "https://static.adsafeprotected.example.com/omid/ias-omid.js",
+				ApiFramework:    "omid",
+				BrowserOptional: &yes,
+			}},
+			TrackingEvents: []TrackingEvent{{
+				Event: "verificationNotExecuted",
+				Text:  "https://pixel.adsafeprotected.example.com/jload?reason=[REASON]",
+			}},
+			VerificationParameters: `{"anId":"927083","advId":"spring","campId":"1x1","pubId":"acme"}`,
+		},
+		{
+			Vendor: "moat.com-omid",
+			JavaScriptResources: []JavaScriptResource{{
+				Text:            "https://z.moatads.example.com/omid/moatvideo.js",
+				ApiFramework:    "omid",
+				BrowserOptional: &no,
+			}},
+			ExecutableResources: []ExecutableResource{{
+				Text:         "https://z.moatads.example.com/omid/native.js",
+				ApiFramework: "omid",
+				MediaType:    "application/javascript",
+			}},
+			VerificationParameters: "\n  moatClientLevel1=Acme&moatClientLevel2=Spring\n",
+		},
 	}
-	var v VMAP
-	if err := xml.Unmarshal(doc, &v); err != nil {
-		b.Fatal(err)
+	for _, v := range []VAST{unmarshaled, decoded, scanned} {
+		is.Equal(v.Ad[0].InLine.AdVerifications, want)
+		is.Equal(v.Ad[1].Wrapper.AdVerifications, AdVerifications{
+			{
+				Vendor: "doubleverify.com-omid",
+				JavaScriptResources: []JavaScriptResource{{
+					Text:         "https://cdn.doubleverify.example.com/dvtp_src.js",
+					ApiFramework: "omid",
+				}},
+			},
+			{Vendor: "empty.example.com"},
+		})
 	}
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		_, _ = xml.Marshal(v)
+}
+
+func TestDecodeAdParameters(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVastAdParameters.xml")
+	is.NoErr(err)
+
+	var unmarshaled VAST
+	err = xml.Unmarshal(doc, &unmarshaled)
+	is.NoErr(err)
+	decoded, err := DecodeVast(doc)
+	is.NoErr(err)
+	scanned, err := DecodeVastScan(doc)
+	is.NoErr(err)
+
+	payload := `{"matrix":[[1,2],[3,4]],"cmp":"a]]b > c","html":"<b>&amp;</b>","q":"x&lt;y"}`
+	for _, v := range []VAST{unmarshaled, decoded, scanned} {
+		simid := v.Ad[0].InLine.Creatives[0].Linear.AdParameters
+		is.Equal(*simid.XMLEncoded, false)
+		is.Equal(simid.Value, "\n  "+payload+"\n")
+		vpaid := v.Ad[1].InLine.Creatives[0].Linear.AdParameters
+		is.Equal(*vpaid.XMLEncoded, true)
+		is.Equal(vpaid.Value, `<params id="a&b"></params>`)
 	}
+
+	plain, err := os.ReadFile("sample-vmap/testVast.xml")
+	is.NoErr(err)
+	noParams, err := DecodeVast(plain)
+	is.NoErr(err)
+	is.Equal(noParams.Ad[0].InLine.Creatives[0].Linear.AdParameters, nil)
 }
 
-func BenchmarkFastMarshalVmap(b *testing.B) {
-	doc, err := os.ReadFile("sample-vmap/testVmap.xml")
-	if err != nil {
-		b.Fatal(err)
+func TestDecodeIcons(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVastIcons.xml")
+	is.NoErr(err)
+
+	var unmarshaled VAST
+	err = xml.Unmarshal(doc, &unmarshaled)
+	is.NoErr(err)
+	decoded, err := DecodeVast(doc)
+	is.NoErr(err)
+	scanned, err := DecodeVastScan(doc)
+	is.NoErr(err)
+
+	thirty := Duration{30 * time.Second}
+	want := Icons{
+		{
+			Program:   "AdChoices",
+			Width:     77,
+			Height:    15,
+			XPosition: IconPositionRight,
+			YPosition: IconPositionTop,
+			Duration:  &thirty,
+			Offset:    &TimeOffset{Duration: &Duration{}},
+			StaticResources: []StaticResource{
+				{CreativeType: "image/png", URL: "https://cdn.example.com/icons/adchoices.png"},
+			},
+			IconClicks: &IconClicks{
+				IconClickThrough: "https://optout.example.com/?ad=icons1",
+				IconClickTracking: []string{
+					"https://adserver.example.com/icon/click?ad=icons1&p=adchoices",
+					"https://thirdparty.example.com/icon/click?ad=icons1",
+				},
+			},
+			IconViewTracking: []string{"https://adserver.example.com/icon/view?ad=icons1"},
+		},
+		{
+			Program:         "Sponsor",
+			Width:           120,
+			Height:          40,
+			XPosition:       "24",
+			YPosition:       IconPositionBottom,
+			Offset:          &TimeOffset{Duration: &Duration{5500 * time.Millisecond}},
+			IFrameResources: []IFrameResource{{URL: "https://cdn.example.com/icons/sponsor.html?a=1&b=2"}},
+			HTMLResources: []HTMLResource{
+				{HTML: `<div class="sponsor"><a href="https://brand.example.com/?a=1&b=2">Brand</a></div>`},
+			},
+		},
 	}
-	var v VMAP
-	if err := xml.Unmarshal(doc, &v); err != nil {
-		b.Fatal(err)
+	for _, v := range []VAST{unmarshaled, decoded, scanned} {
+		is.Equal(v.Ad[0].InLine.Creatives[0].Linear.Icons, want)
 	}
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		_, _ = MarshalVmap(&v)
+
+	plain, err := os.ReadFile("sample-vmap/testVast.xml")
+	is.NoErr(err)
+	noIcons, err := DecodeVast(plain)
+	is.NoErr(err)
+	is.Equal(len(noIcons.Ad[0].InLine.Creatives[0].Linear.Icons), 0)
+
+	// empty sizes decode to 0, as encoding/xml does
+	doc = []byte(`<VAST version="4.1"><Ad id="1"><InLine><Creatives><Creative><Linear><Icons>` +
+		`<Icon program="AdChoices" width="" height=""></Icon>` +
+		`</Icons></Linear></Creative></Creatives></InLine></Ad></VAST>`)
+	unmarshaled = VAST{}
+	is.NoErr(xml.Unmarshal(doc, &unmarshaled))
+	decoded, err = DecodeVast(doc)
+	is.NoErr(err)
+	scanned, err = DecodeVastScan(doc)
+	is.NoErr(err)
+	for _, v := range []VAST{unmarshaled, decoded, scanned} {
+		is.Equal(v.Ad[0].InLine.Creatives[0].Linear.Icons, Icons{{Program: "AdChoices"}})
 	}
 }
 
-func BenchmarkXMLMarshalVast(b *testing.B) {
-	doc, err := os.ReadFile("sample-vmap/testVast.xml")
-	if err != nil {
-		b.Fatal(err)
+func TestIconPosition(t *testing.T) {
+	is := is.New(t)
+	for s, want := range map[string]IconPosition{
+		"left": {Edge: IconPositionLeft}, "bottom": {Edge: IconPositionBottom}, "0": {}, " 24 ": {Pixels: 24},
+	} {
+		p, err := ParseIconPosition(s)
+		is.NoErr(err)
+		is.Equal(p, want)
 	}
-	var v VAST
-	if err := xml.Unmarshal(doc, &v); err != nil {
-		b.Fatal(err)
+	for _, s := range []string{"", "-5", "center", "Left", "10px"} {
+		_, err := ParseIconPosition(s)
+		is.True(err != nil)
 	}
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		_, _ = xml.Marshal(v)
+
+	x, y, err := (&Icon{XPosition: "right", YPosition: "12"}).Position()
+	is.NoErr(err)
+	is.Equal(x, IconPosition{Edge: IconPositionRight})
+	is.Equal(y, IconPosition{Pixels: 12})
+	_, _, err = (&Icon{XPosition: "top", YPosition: "left"}).Position()
+	is.Equal(err.Error(), `invalid icon xPosition "top"`)
+	_, _, err = (&Icon{XPosition: "0", YPosition: "left"}).Position()
+	is.Equal(err.Error(), `invalid icon yPosition "left"`)
+}
+
+func TestDecodeAdBreakExtraAttrs(t *testing.T) {
+	is := is.New(t)
+	doc := []byte(`<vmap:VMAP xmlns:vmap="http://www.iab.net/videosuite/vmap" version="1.0">` +
+		`<vmap:AdBreak breakId="pre" breakType="linear" timeOffset="start" placementId="p-42"` +
+		` xmlns:fw="http://www.freewheel.tv/vmap" fw:slot="skipped" vendorData='a&amp;b "c"'>` +
+		`<vmap:AdSource><vmap:AdTagURI templateType="vast3"><![CDATA[https://ads.example.com/pre]]>` +
+		`</vmap:AdTagURI></vmap:AdSource></vmap:AdBreak>` +
+		`<vmap:AdBreak breakId="post" breakType="linear" timeOffset="end"/>` +
+		`</vmap:VMAP>`)
+
+	var unmarshaled VMAP
+	is.NoErr(xml.Unmarshal(doc, &unmarshaled))
+	decoded, err := DecodeVmap(doc)
+	is.NoErr(err)
+	scanned, err := DecodeVmapScan(doc)
+	is.NoErr(err)
+
+	want := map[string]string{"placementId": "p-42", "vendorData": `a&b "c"`}
+	for _, v := range []VMAP{unmarshaled, decoded, scanned} {
+		is.Equal(len(v.AdBreaks), 2)
+		is.Equal(v.AdBreaks[0].Id, "pre")
+		is.Equal(v.AdBreaks[0].TimeOffset.Position, OffsetStart)
+		is.Equal(v.AdBreaks[0].ExtraAttrs, want)
+		is.Equal(v.AdBreaks[1].ExtraAttrs, nil)
 	}
 }
 
-func BenchmarkFastMarshalVast(b *testing.B) {
-	doc, err := os.ReadFile("sample-vmap/testVast.xml")
-	if err != nil {
-		b.Fatal(err)
+func TestDecodeMediaFileAttributes(t *testing.T) {
+	is := is.New(t)
+	doc := []byte(`<VAST version="4.2"><Ad id="1"><InLine><AdTitle>attrs</AdTitle><Creatives><Creative><Linear>` +
+		`<Duration>00:00:15</Duration><MediaFiles>` +
+		`<MediaFile id="mf-1" delivery="streaming" type="application/x-mpegURL" width="1920" height="1080"` +
+		` bitrate="" minBitrate="800" maxBitrate=" 6000 " scalable="TRUE" maintainAspectRatio="1"` +
+		` apiFramework="VPAID" fileSize="123456" codec="avc1"><![CDATA[https://cdn.example.com/ad.m3u8]]></MediaFile>` +
+		`<MediaFile delivery="progressive" type="video/mp4" width="640" height="360" bitrate="500"` +
+		` scalable="yes" maintainAspectRatio="">https://cdn.example.com/ad.mp4</MediaFile>` +
+		`</MediaFiles></Linear></Creative></Creatives></InLine></Ad></VAST>`)
+
+	var unmarshaled VAST
+	is.NoErr(xml.Unmarshal(doc, &unmarshaled))
+	decoded, err := DecodeVast(doc)
+	is.NoErr(err)
+	scanned, err := DecodeVastScan(doc)
+	is.NoErr(err)
+
+	yes := true
+	want := []MediaFile{
+		{
+			Text: "https://cdn.example.com/ad.m3u8", Width: 1920, Height: 1080, Delivery: DeliveryStreaming,
+			MediaType: "application/x-mpegURL", Codec: "avc1", Id: "mf-1", MinBitrate: 800, MaxBitrate: 6000,
+			Scalable: &yes, MaintainAspectRatio: &yes, ApiFramework: "VPAID", FileSize: 123456,
+		},
+		// booleans that do not parse are left nil
+		{Text: "https://cdn.example.com/ad.mp4", Bitrate: 500, Width: 640, Height: 360, Delivery: DeliveryProgressive,
+			MediaType: "video/mp4"},
 	}
-	var v VAST
-	if err := xml.Unmarshal(doc, &v); err != nil {
-		b.Fatal(err)
+	for _, v := range []VAST{unmarshaled, decoded, scanned} {
+		is.Equal(v.Ad[0].InLine.Creatives[0].Linear.MediaFiles, want)
 	}
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		_, _ = MarshalVast(&v)
+
+	j, err := json.Marshal(want[0])
+	is.NoErr(err)
+	is.True(strings.Contains(string(j), `"minBitrate":800,"maxBitrate":6000,"scalable":true,`+
+		`"maintainAspectRatio":true,"apiFramework":"VPAID","fileSize":123456`))
+}
+
+func TestResourceTypes(t *testing.T) {
+	is := is.New(t)
+	type resources struct {
+		XMLName xml.Name         `xml:"Resources"`
+		Static  []StaticResource `xml:"StaticResource"`
+		IFrame  []IFrameResource `xml:"IFrameResource"`
+		HTML    []HTMLResource   `xml:"HTMLResource"`
 	}
+	doc := `<Resources>` +
+		`<StaticResource creativeType="image/png"><![CDATA[https://example.com/logo.png]]></StaticResource>` +
+		`<IFrameResource>https://example.com/frame.html?a=1&amp;b=2</IFrameResource>` +
+		`<HTMLResource><![CDATA[<div class="ad"><a href="https://example.com/?a=1&b=2">Go</a></div>]]></HTMLResource>` +
+		`<HTMLResource>&lt;p&gt;escaped&lt;/p&gt;</HTMLResource>` +
+		`</Resources>`
+
+	var r resources
+	err := xml.Unmarshal([]byte(doc), &r)
+	is.NoErr(err)
+	is.Equal(r.Static[0].CreativeType, "image/png")
+	is.Equal(r.Static[0].URL, "https://example.com/logo.png")
+	is.Equal(r.IFrame[0].URL, "https://example.com/frame.html?a=1&b=2")
+	is.Equal(r.HTML[0].HTML, `<div class="ad"><a href="https://example.com/?a=1&b=2">Go</a></div>`)
+	is.Equal(r.HTML[1].HTML, "<p>escaped</p>")
+
+	out, err := xml.Marshal(r)
+	is.NoErr(err)
+	is.True(strings.Contains(string(out),
+		`<HTMLResource><![CDATA[<div class="ad"><a href="https://example.com/?a=1&b=2">Go</a></div>]]></HTMLResource>`))
+	is.True(strings.Contains(string(out), `<HTMLResource><![CDATA[<p>escaped</p>]]></HTMLResource>`))
 }
 
-func TestDecodeCompliance(t *testing.T) {
-	wg := sync.WaitGroup{}
-	//Check for race conditions
-	for range 1000 {
-		wg.Add(1)
-		go func(wg *sync.WaitGroup, t *testing.T) {
-			defer wg.Done()
-			is := is.New(t)
-			doc, err := os.ReadFile("sample-vmap/testVmap.xml")
-			is.NoErr(err)
+func TestDecodeCustomAdData(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVmapCustomAdData.xml")
+	is.NoErr(err)
 
-			var vmap1 VMAP
-			err = xml.Unmarshal(doc, &vmap1)
-			is.NoErr(err)
+	const pod = `<Pod id="42"><Slot duration="15" url="https://ads.example.com/slot?a=1&amp;b=2"/>` +
+		`<Tracking event="start">https://ads.example.com/t</Tracking></Pod>`
 
-			vmap2, err := DecodeVmap(doc)
-			is.NoErr(err)
+	var unmarshaled VMAP
+	err = xml.Unmarshal(doc, &unmarshaled)
+	is.NoErr(err)
+	decoded, err := DecodeVmap(doc)
+	is.NoErr(err)
+	scanned, err := DecodeVmapScan(doc)
+	is.NoErr(err)
 
-			is.Equal(vmap1.Version, vmap2.Version)
-			is.Equal(vmap1.Vmap, vmap2.Vmap)
-			is.Equal(vmap1.XMLName.Local, vmap2.XMLName.Local)
-			is.Equal(vmap1.XMLName.Space, vmap2.XMLName.Space)
+	for _, v := range []VMAP{unmarshaled, decoded, scanned} {
+		is.Equal(len(v.AdBreaks), 2)
+		preroll := v.AdBreaks[0]
+		is.Equal(preroll.AdSource.VASTData, nil)
+		is.Equal(len(preroll.TrackingEvents), 0) // Tracking inside the custom data is not a break event
+		is.Equal(*preroll.AdSource.CustomAdData, CustomAdData{TemplateType: "acme-pod", Data: pod})
+		is.Equal(*v.AdBreaks[1].AdSource.CustomAdData,
+			CustomAdData{TemplateType: "acme-json", Data: `<![CDATA[{"pod":43,"slots":[15,30]}]]>`})
+	}
+}
 
-			is.Equal(len(vmap1.AdBreaks), len(vmap2.AdBreaks))
-			for i := range vmap1.AdBreaks {
-				adb1 := vmap1.AdBreaks[i]
-				adb2 := vmap2.AdBreaks[i]
-				is.Equal(adb1.BreakType, adb2.BreakType)
-				is.Equal(adb1.Id, adb2.Id)
-				is.Equal(adb1.TimeOffset, adb2.TimeOffset)
-				is.Equal(adb1.TimeOffset.Duration, adb2.TimeOffset.Duration)
-				is.Equal(adb1.TimeOffset.Position, adb2.TimeOffset.Position)
+func TestDecodeAdServingId(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVast4MediaFiles.xml")
+	is.NoErr(err)
 
-				if adb1.TrackingEvents != nil {
-					te1 := adb1.TrackingEvents
-					te2 := adb2.TrackingEvents
+	var unmarshaled VAST
+	err = xml.Unmarshal(doc, &unmarshaled)
+	is.NoErr(err)
+	decoded, err := DecodeVast(doc)
+	is.NoErr(err)
+	scanned, err := DecodeVastScan(doc)
+	is.NoErr(err)
+
+	for _, v := range []VAST{unmarshaled, decoded, scanned} {
+		is.Equal(v.Ad[0].InLine.AdServingId, "a532d16d-4d7f-4440-bd29-2ec0e693fc80")
+		is.True(v.Ad[0].InLine.HasAdServingId())
+		is.Equal(len(v.Validate()), 0)
+	}
+
+	var nilInLine *InLine
+	is.True(!nilInLine.HasAdServingId())
+}
+
+func TestDecodeAdSystem(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVastAdvertiser.xml")
+	is.NoErr(err)
+
+	var unmarshaled VAST
+	err = xml.Unmarshal(doc, &unmarshaled)
+	is.NoErr(err)
+	decoded, err := DecodeVast(doc)
+	is.NoErr(err)
+	scanned, err := DecodeVastScan(doc)
+	is.NoErr(err)
+
+	for _, v := range []VAST{unmarshaled, decoded, scanned} {
+		is.Equal(*v.Ad[0].InLine.AdSystem, AdSystem{Version: "2.0", Name: "Test Adserver"})
+		is.Equal(*v.Ad[1].InLine.AdSystem, AdSystem{Name: "Test Adserver"})
+		is.Equal(v.Ad[0].InLine.AdSystem.String(), "Test Adserver")
+	}
+
+	out, err := MarshalVast(&decoded)
+	is.NoErr(err)
+	is.True(strings.Contains(string(out), `<AdSystem version="2.0">Test Adserver</AdSystem>`))
+	is.True(strings.Contains(string(out), `<AdSystem>Test Adserver</AdSystem>`))
+
+	js, err := json.Marshal(decoded.Ad[0].InLine)
+	is.NoErr(err)
+	is.True(strings.Contains(string(js), `"adSystem":{"version":"2.0","name":"Test Adserver"}`))
+	var fromJSON InLine
+	is.NoErr(json.Unmarshal(js, &fromJSON))
+	is.Equal(*fromJSON.AdSystem, *decoded.Ad[0].InLine.AdSystem)
+
+	var nilAdSystem *AdSystem
+	is.Equal(nilAdSystem.String(), "")
+}
+
+func TestSeatIdRoundTrip(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVastAdvertiser.xml")
+	is.NoErr(err)
+
+	var unmarshaled VAST
+	err = xml.Unmarshal(doc, &unmarshaled)
+	is.NoErr(err)
+	decoded, err := DecodeVast(doc)
+	is.NoErr(err)
+	scanned, err := DecodeVastScan(doc)
+	is.NoErr(err)
+
+	for _, v := range []VAST{unmarshaled, decoded, scanned} {
+		is.Equal(v.Ad[0].SeatId, "dsp-seat-1234")
+		is.True(v.Ad[0].HasSeat())
+		is.True(!v.Ad[1].HasSeat())
+	}
+
+	out, err := MarshalVast(&decoded)
+	is.NoErr(err)
+	is.True(strings.Contains(string(out), `<Ad id="ADVERTISER_AD_001" sequence="1" seatId="dsp-seat-1234">`))
+	// an ad without a seat has no seatId attribute
+	is.True(!strings.Contains(string(out), `seatId=""`))
+	std, err := xml.Marshal(&decoded)
+	is.NoErr(err)
+	is.Equal(string(out), string(std))
+	roundTripped, err := DecodeVast(out)
+	is.NoErr(err)
+	is.Equal(roundTripped.Ad[0].SeatId, "dsp-seat-1234")
+}
+
+func TestDecodeWrapper(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVastWrapper.xml")
+	is.NoErr(err)
+
+	const companions = `<Companion width="300" height="250"><TrackingEvents>` +
+		`<Tracking event="creativeView">https://wrapper.example.com/companion/view</Tracking>` +
+		`</TrackingEvents></Companion>`
+
+	var unmarshaled VAST
+	err = xml.Unmarshal(doc, &unmarshaled)
+	is.NoErr(err)
+	decoded, err := DecodeVast(doc)
+	is.NoErr(err)
+	scanned, err := DecodeVastScan(doc)
+	is.NoErr(err)
+
+	for _, v := range []VAST{unmarshaled, decoded, scanned} {
+		ad := v.Ad[0]
+		is.Equal(ad.InLine, nil)
+		w := ad.Wrapper
+		is.True(w != nil)
+		is.Equal(*w.AdSystem, AdSystem{Version: "1.0", Name: "Wrapper Adserver"})
+		is.Equal(w.VASTAdTagURI, "https://secondary.example.com/vast?slot=pre&cb=1234")
+		is.Equal(*w.FollowAdditionalWrappers, true)
+		is.Equal(*w.AllowMultipleAds, false)
+		is.Equal(*w.FallbackOnNoAd, true)
+		is.Equal(w.Error[0].Value, "https://wrapper.example.com/error?code=[ERRORCODE]")
+		is.Equal(len(w.Impression), 2)
+		is.Equal(w.Impression[1].Text, "https://thirdparty.example.com/imp?a=1&b=2")
+		is.Equal(w.BlockedAdCategories, []BlockedAdCategory{
+			{Authority: "https://iabtechlab.com/", Value: "IAB8-5, IAB8-18"},
+			{Value: "IAB25"},
+		})
+		is.Equal(w.BlockedAdCategories[0].Codes(), []string{"IAB8-5", "IAB8-18"})
+
+		is.Equal(len(w.Creatives), 2)
+		linear := w.Creatives[0].Linear
+		is.Equal(w.Creatives[0].AdId, "wrapper-30s")
+		is.Equal(len(linear.TrackingEvents), 2)
+		is.Equal(linear.TrackingEvents[1].Event, "complete")
+		is.Equal(linear.TrackingEvents[1].Text, "https://wrapper.example.com/tracking/complete")
+		is.Equal(len(linear.ClickTracking), 1)
+		is.Equal(linear.ClickTracking[0].Id, "WRAPPER_CLICK_001")
+		is.Equal(linear.ClickTracking[0].Text, "https://wrapper.example.com/click")
+		is.Equal(w.Creatives[0].CompanionAds, nil)
+
+		is.Equal(w.Creatives[1].Linear, nil)
+		is.Equal(*w.Creatives[1].CompanionAds, CompanionAds{Required: "any", InnerXML: companions})
+
+		is.Equal(len(w.Extensions), 1)
+		is.Equal(w.Extensions[0].CreativeParameters[0].Name, "AdType")
+	}
+}
+
+func TestDecodeTrimsIndentedURLs(t *testing.T) {
+	is := is.New(t)
+	doc := []byte(`<VAST version="3.0">
+  <Ad id="1">
+    <InLine>
+      <Impression id="plain">
+        https://adserver.example.com/impression?a=1&amp;b=two words
+      </Impression>
+      <Impression id="cdata">
+        <![CDATA[
+          https://adserver.example.com/impression?c=3
+        ]]>
+      </Impression>
+      <Creatives>
+        <Creative>
+          <Linear>
+            <TrackingEvents>
+              <Tracking event="start">
+                https://adserver.example.com/start
+              </Tracking>
+            </TrackingEvents>
+            <MediaFiles>
+              <MediaFile delivery="progressive">
+                https://cdn.example.com/ad.mp4
+              </MediaFile>
+            </MediaFiles>
+            <VideoClicks>
+              <ClickThrough>
+                https://advertiser.example.com/
+              </ClickThrough>
+            </VideoClicks>
+          </Linear>
+        </Creative>
+      </Creatives>
+      <Error>
+        https://adserver.example.com/error?code=[ERRORCODE]
+      </Error>
+    </InLine>
+  </Ad>
+</VAST>`)
+
+	var unmarshaled VAST
+	err := xml.Unmarshal(doc, &unmarshaled)
+	is.NoErr(err)
+	decoded, err := DecodeVast(doc)
+	is.NoErr(err)
+	scanned, err := DecodeVastScan(doc)
+	is.NoErr(err)
+
+	for _, v := range []VAST{unmarshaled, decoded, scanned} {
+		il := v.Ad[0].InLine
+		is.Equal(il.Impression[0].Text, "https://adserver.example.com/impression?a=1&b=two words")
+		is.Equal(il.Impression[1].Text, "https://adserver.example.com/impression?c=3")
+		is.Equal(il.Error[0].Value, "https://adserver.example.com/error?code=[ERRORCODE]")
+		linear := il.Creatives[0].Linear
+		is.Equal(linear.TrackingEvents[0].Text, "https://adserver.example.com/start")
+		is.Equal(linear.MediaFiles[0].Text, "https://cdn.example.com/ad.mp4")
+		is.Equal(linear.ClickThrough.Text, "https://advertiser.example.com/")
+	}
+}
+
+func TestDecodeRepeatAfter(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVmapRepeatAfter.xml")
+	is.NoErr(err)
+
+	var unmarshaled VMAP
+	err = xml.Unmarshal(doc, &unmarshaled)
+	is.NoErr(err)
+	decoded, err := DecodeVmap(doc)
+	is.NoErr(err)
+	scanned, err := DecodeVmapScan(doc)
+	is.NoErr(err)
+
+	for _, v := range []VMAP{unmarshaled, decoded, scanned} {
+		is.Equal(v.AdBreaks[0].RepeatAfter, nil)
+		is.Equal(*v.AdBreaks[1].RepeatAfter, Duration{15 * time.Minute})
+	}
+
+	_, err = DecodeVmap([]byte(`<VMAP><AdBreak breakId="x" timeOffset="start" repeatAfter="often"></AdBreak></VMAP>`))
+	is.True(err != nil)
+}
+
+func TestDecodeVastError(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVmapNoAd.xml")
+	is.NoErr(err)
+
+	var unmarshaled VMAP
+	err = xml.Unmarshal(doc, &unmarshaled)
+	is.NoErr(err)
+	decoded, err := DecodeVmap(doc)
+	is.NoErr(err)
+	scanned, err := DecodeVmapScan(doc)
+	is.NoErr(err)
+
+	for _, v := range []VMAP{unmarshaled, decoded, scanned} {
+		is.Equal(v.AdBreaks[0].AdSource.VASTData.VAST.Error, nil)
+		vast := v.AdBreaks[1].AdSource.VASTData.VAST
+		is.Equal(len(vast.Ad), 0)
+		is.Equal(vast.Error, []Error{
+			{Value: "https://adserver.example.com/noad?code=[ERRORCODE]&pos=mid"},
+			{Value: "https://thirdparty.example.com/noad?pos=mid"},
+		})
+	}
+}
+
+func TestDecodeNamespacedVast(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVast41Namespaced.xml")
+	is.NoErr(err)
+
+	var unmarshaled VAST
+	err = xml.Unmarshal(doc, &unmarshaled)
+	is.NoErr(err)
+	decoded, err := DecodeVast(doc)
+	is.NoErr(err)
+	scanned, err := DecodeVastScan(doc)
+	is.NoErr(err)
+
+	for _, v := range []VAST{unmarshaled, decoded, scanned} {
+		is.Equal(v.Xmlns, "http://www.iab.com/VAST")
+		is.Equal(v.Xsi, "http://www.w3.org/2001/XMLSchema-instance")
+		is.Equal(v.Version, "4.1")
+		is.Equal(len(v.Ad), 2)
+		is.Equal(v.Ad[0].InLine.AdServingId, "ns-serving-id-001")
+		is.Equal(v.Ad[0].InLine.Creatives[0].Linear.MediaFiles[0].Text, "https://cdn.example.com/ns-15s.mp4")
+		is.Equal(v.Ad[1].InLine.AdTitle, "Second Namespaced Ad")
+		is.Equal(*v.Ad[0].ConditionalAd, true)
+		is.Equal(v.Ad[0].InLine.Creatives[0].CreativeExtensions, CreativeExtensions{
+			{ExtensionType: "application/json", InnerXML: `<![CDATA[{"brand":"example"}]]>`},
+			{ExtensionType: "moat", InnerXML: `<Moat partner="example"><Tag id="1">abc</Tag></Moat>`},
+		})
+		is.Equal(v.Ad[0].AdType, AdTypeVideo)
+		is.Equal(*v.Ad[1].ConditionalAd, false)
+		is.True(v.Ad[1].IsAudio())
+		is.Equal(v.Ad[0].InLine.Creatives[0].UniversalAdIds, []UniversalAdId{
+			{IdRegistry: "ad-id.org", Id: "CNPA0484000H"},
+			{IdRegistry: "broadcaster.example.com", Id: "BRD-2024-0042"},
+		})
+	}
+}
+
+func TestDecodeAdSequence(t *testing.T) {
+	is := is.New(t)
+	doc := []byte(`<VAST version="4.1">` +
+		`<Ad id="none"><InLine><AdTitle>a</AdTitle></InLine></Ad>` +
+		`<Ad id="empty" sequence=""><InLine><AdTitle>b</AdTitle></InLine></Ad>` +
+		`<Ad id="blank" sequence="  "><InLine><AdTitle>c</AdTitle></InLine></Ad>` +
+		`<Ad id="zero" sequence=" 0 "><InLine><AdTitle>d</AdTitle></InLine></Ad>` +
+		`<Ad id="second" sequence="2"><InLine><AdTitle>e</AdTitle></InLine></Ad>` +
+		`</VAST>`)
+
+	var unmarshaled VAST
+	err := xml.Unmarshal(doc, &unmarshaled)
+	is.NoErr(err)
+	decoded, err := DecodeVast(doc)
+	is.NoErr(err)
+	scanned, err := DecodeVastScan(doc)
+	is.NoErr(err)
+
+	for _, v := range []VAST{unmarshaled, decoded, scanned} {
+		is.Equal(len(v.Ad), 5)
+		is.Equal(v.Ad[0].Sequence, nil)
+		is.Equal(v.Ad[1].Sequence, nil)
+		is.Equal(v.Ad[2].Sequence, nil)
+		is.Equal(*v.Ad[3].Sequence, 0)
+		is.Equal(*v.Ad[4].Sequence, 2)
+	}
+
+	b, err := json.Marshal(decoded.Ad[0])
+	is.NoErr(err)
+	is.True(strings.Contains(string(b), `"sequence":null`))
+}
+
+func TestDecodeCreativeSequence(t *testing.T) {
+	is := is.New(t)
+	doc := []byte(`<VAST version="4.1">` +
+		`<Ad id="1"><InLine><Creatives><Creative sequence=""></Creative><Creative sequence="2"></Creative>` +
+		`</Creatives></InLine></Ad>` +
+		`<Ad id="2"><Wrapper><Creatives><Creative sequence=""></Creative></Creatives></Wrapper></Ad>` +
+		`</VAST>`)
+
+	var unmarshaled VAST
+	err := xml.Unmarshal(doc, &unmarshaled)
+	is.NoErr(err)
+	decoded, err := DecodeVast(doc)
+	is.NoErr(err)
+	scanned, err := DecodeVastScan(doc)
+	is.NoErr(err)
+
+	for _, v := range []VAST{unmarshaled, decoded, scanned} {
+		is.Equal(v.Ad[0].InLine.Creatives[0].Sequence, 0)
+		is.Equal(v.Ad[0].InLine.Creatives[1].Sequence, 2)
+		is.Equal(v.Ad[1].Wrapper.Creatives[0].Sequence, 0)
+	}
+}
+
+func TestDecodeTrackingOffset(t *testing.T) {
+	is := is.New(t)
+	doc := []byte(`<VAST version="4.1"><Ad id="1"><InLine><Creatives><Creative id="c1"><Linear>` +
+		`<TrackingEvents>` +
+		`<Tracking event="start"><![CDATA[https://t.example.com/start]]></Tracking>` +
+		`<Tracking event="progress" offset="00:00:05.000">https://t.example.com/5s</Tracking>` +
+		`<Tracking event="progress" offset="50%">https://t.example.com/half</Tracking>` +
+		`<Tracking event="progress" offset="00:00:10">https://t.example.com/10s</Tracking>` +
+		`<Tracking event="complete" offset="00:00:10">https://t.example.com/complete</Tracking>` +
+		`</TrackingEvents></Linear></Creative></Creatives></InLine></Ad></VAST>`)
+
+	var unmarshaled VAST
+	err := xml.Unmarshal(doc, &unmarshaled)
+	is.NoErr(err)
+	decoded, err := DecodeVast(doc)
+	is.NoErr(err)
+	scanned, err := DecodeVastScan(doc)
+	is.NoErr(err)
+
+	for _, v := range []VAST{unmarshaled, decoded, scanned} {
+		events := v.Ad[0].InLine.Creatives[0].Linear.TrackingEvents
+		is.Equal(len(events), 5)
+		is.Equal(events[0].Offset, nil)
+		is.Equal(events[1].Offset.Duration.Duration, 5*time.Second)
+		is.Equal(events[2].Offset.Percent, float32(0.5))
+		is.Equal(events[3].Offset.Duration.Duration, 10*time.Second)
+		is.Equal(events[4].Offset, nil) // only progress events have an offset
+		is.True(events[1].KnownEvent())
+	}
+}
+
+func TestDecodeDescription(t *testing.T) {
+	is := is.New(t)
+	doc := []byte(`<VAST version="3.0">` +
+		`<Ad id="1"><InLine><Description>` + "\n  Spring &amp; summer &lt;sale&gt;\n  second line\n" +
+		`</Description></InLine></Ad>` +
+		`<Ad id="2"><InLine><Description><![CDATA[ Fish &amp; chips <b>now</b>` + "\n" + `]]></Description>` +
+		`</InLine></Ad>` +
+		`<Ad id="3"><InLine><Description>  </Description></InLine></Ad>` +
+		`</VAST>`)
+
+	var unmarshaled VAST
+	err := xml.Unmarshal(doc, &unmarshaled)
+	is.NoErr(err)
+	decoded, err := DecodeVast(doc)
+	is.NoErr(err)
+	scanned, err := DecodeVastScan(doc)
+	is.NoErr(err)
+
+	for _, v := range []VAST{unmarshaled, decoded, scanned} {
+		is.Equal(v.Ad[0].InLine.Description, CDATAText("Spring & summer <sale>\n  second line"))
+		is.Equal(v.Ad[1].InLine.Description, CDATAText("Fish &amp; chips <b>now</b>"))
+		is.Equal(v.Ad[2].InLine.Description, CDATAText(""))
+		is.True(!v.Ad[2].InLine.HasDescription())
+	}
+
+	js, err := json.Marshal(decoded.Ad[1].InLine)
+	is.NoErr(err)
+	is.True(strings.Contains(string(js), `"description":"Fish \u0026amp; chips \u003cb\u003enow\u003c/b\u003e"`))
+}
+
+func TestDecodeCharacterReferences(t *testing.T) {
+	is := is.New(t)
+	// encoding/xml writes &#34; for quotes in text, and &#x9; and friends
+	// for control characters.
+	doc := []byte(`<VAST version="3.0"><Ad id="1"><InLine>` +
+		`<AdTitle>Say &#34;hi&#34; &#x26; &#65;&#x42;C &lt;now&gt;</AdTitle>` +
+		`<Impression>https://adserver.example.com/imp?a=1&amp;t=a&#x9;b</Impression>` +
+		`</InLine></Ad></VAST>`)
+
+	var unmarshaled VAST
+	err := xml.Unmarshal(doc, &unmarshaled)
+	is.NoErr(err)
+	decoded, err := DecodeVast(doc)
+	is.NoErr(err)
+	scanned, err := DecodeVastScan(doc)
+	is.NoErr(err)
+
+	for _, v := range []VAST{unmarshaled, decoded, scanned} {
+		is.Equal(v.Ad[0].InLine.AdTitle, `Say "hi" & ABC <now>`)
+		is.Equal(v.Ad[0].InLine.Impression[0].Text, "https://adserver.example.com/imp?a=1&t=a\tb")
+	}
+}
+
+// --- Fast Marshal Tests ---
+
+func TestMarshalVmapFast(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVmap.xml")
+	is.NoErr(err)
+
+	var v VMAP
+	err = xml.Unmarshal(doc, &v)
+	is.NoErr(err)
+
+	expected, err := xml.Marshal(v)
+	is.NoErr(err)
+
+	got, err := MarshalVmap(&v)
+	is.NoErr(err)
+
+	is.Equal(string(expected), string(got))
+}
+
+func TestMarshalVmapEmptyFast(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVmap2.xml")
+	is.NoErr(err)
+
+	var v VMAP
+	err = xml.Unmarshal(doc, &v)
+	is.NoErr(err)
+
+	expected, err := xml.Marshal(v)
+	is.NoErr(err)
+
+	got, err := MarshalVmap(&v)
+	is.NoErr(err)
+
+	is.Equal(string(expected), string(got))
+}
+
+func TestMarshalVmapEmptyVastFast(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVmapEmptyVast.xml")
+	is.NoErr(err)
+
+	var v VMAP
+	err = xml.Unmarshal(doc, &v)
+	is.NoErr(err)
+
+	expected, err := xml.Marshal(v)
+	is.NoErr(err)
+
+	got, err := MarshalVmap(&v)
+	is.NoErr(err)
+
+	is.Equal(string(expected), string(got))
+}
+
+func TestMarshalVmapExtensionsFast(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVmapExtensions.xml")
+	is.NoErr(err)
+
+	v, err := DecodeVmapScan(doc)
+	is.NoErr(err)
+	v.AdBreaks[0].Id = "preroll-modified"
+
+	expected, err := xml.Marshal(v)
+	is.NoErr(err)
+
+	got, err := MarshalVmap(&v)
+	is.NoErr(err)
+
+	is.Equal(string(expected), string(got))
+	is.True(strings.Contains(string(got), `breakId="preroll-modified"`))
+	is.True(strings.Contains(string(got), `<Extensions><Extension type="YospaceSession">`+v.Extensions[0].InnerXML))
+
+	// without extensions the Extensions element is omitted
+	v.Extensions = nil
+	expected, err = xml.Marshal(v)
+	is.NoErr(err)
+	got, err = MarshalVmap(&v)
+	is.NoErr(err)
+	is.Equal(string(expected), string(got))
+	is.True(strings.HasSuffix(string(got), `</AdBreak></VMAP>`))
+	got, err = MarshalVmap(&VMAP{Version: "1.0"})
+	is.NoErr(err)
+	is.Equal(string(got), `<VMAP vmap="" version="1.0"></VMAP>`)
+}
+
+func TestMarshalAdBreakExtensionsFast(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVmapBreakExtensions.xml")
+	is.NoErr(err)
+
+	var v VMAP
+	err = xml.Unmarshal(doc, &v)
+	is.NoErr(err)
+
+	expected, err := xml.Marshal(v)
+	is.NoErr(err)
+
+	got, err := MarshalVmap(&v)
+	is.NoErr(err)
+
+	is.Equal(string(expected), string(got))
+	is.True(strings.Contains(string(got), v.AdBreaks[0].Extensions[0].InnerXML))
+
+	// without extensions the Extensions element is omitted
+	v.AdBreaks[0].Extensions = nil
+	expected, err = xml.Marshal(v)
+	is.NoErr(err)
+	got, err = MarshalVmap(&v)
+	is.NoErr(err)
+	is.Equal(string(expected), string(got))
+	is.True(!strings.Contains(string(got), `</TrackingEvents><Extensions>`))
+}
+
+func TestMarshalAdTagURIFast(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVmapAdTagURI.xml")
+	is.NoErr(err)
+
+	var v VMAP
+	err = xml.Unmarshal(doc, &v)
+	is.NoErr(err)
+	v.AdBreaks[0].AdSource.AdTagURI.URI += "&nested=]]>"
+
+	expected, err := xml.Marshal(v)
+	is.NoErr(err)
+
+	got, err := MarshalVmap(&v)
+	is.NoErr(err)
+
+	is.Equal(string(expected), string(got))
+}
+
+func TestMarshalVastFast(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVast.xml")
+	is.NoErr(err)
+
+	var v VAST
+	err = xml.Unmarshal(doc, &v)
+	is.NoErr(err)
+
+	expected, err := xml.Marshal(v)
+	is.NoErr(err)
+
+	got, err := MarshalVast(&v)
+	is.NoErr(err)
+
+	is.Equal(string(expected), string(got))
+}
+
+func TestMarshalVastEmptyFast(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVast3.xml")
+	is.NoErr(err)
+
+	var v VAST
+	err = xml.Unmarshal(doc, &v)
+	is.NoErr(err)
+
+	expected, err := xml.Marshal(v)
+	is.NoErr(err)
+
+	got, err := MarshalVast(&v)
+	is.NoErr(err)
+
+	is.Equal(string(expected), string(got))
+}
+
+func TestMarshalVast2Fast(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVast2.xml")
+	is.NoErr(err)
+
+	var v VAST
+	err = xml.Unmarshal(doc, &v)
+	is.NoErr(err)
+
+	expected, err := xml.Marshal(v)
+	is.NoErr(err)
+
+	got, err := MarshalVast(&v)
+	is.NoErr(err)
+
+	is.Equal(string(expected), string(got))
+}
+
+func TestMarshalPricingFast(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVastPricing.xml")
+	is.NoErr(err)
+
+	var v VAST
+	err = xml.Unmarshal(doc, &v)
+	is.NoErr(err)
+
+	expected, err := xml.Marshal(v)
+	is.NoErr(err)
+
+	got, err := MarshalVast(&v)
+	is.NoErr(err)
+
+	is.Equal(string(expected), string(got))
+	is.True(strings.Contains(string(got), `<Pricing model="CPM" currency="USD">25.50</Pricing>`))
+	is.True(strings.Contains(string(got), `<Pricing model="cpv" currency="SEK">0.100</Pricing>`))
+}
+
+func TestMarshalVast4MediaFilesFast(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVast4MediaFiles.xml")
+	is.NoErr(err)
+
+	var v VAST
+	err = xml.Unmarshal(doc, &v)
+	is.NoErr(err)
+
+	expected, err := xml.Marshal(v)
+	is.NoErr(err)
+
+	got, err := MarshalVast(&v)
+	is.NoErr(err)
+
+	is.Equal(string(expected), string(got))
+}
+
+func TestMarshalSkipOffsetFast(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVastSkippable.xml")
+	is.NoErr(err)
+
+	var v VAST
+	err = xml.Unmarshal(doc, &v)
+	is.NoErr(err)
+
+	expected, err := xml.Marshal(v)
+	is.NoErr(err)
+
+	got, err := MarshalVast(&v)
+	is.NoErr(err)
+
+	is.Equal(string(expected), string(got))
+}
+
+func TestMarshalCategoriesFast(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVastCategory.xml")
+	is.NoErr(err)
+
+	var v VAST
+	err = xml.Unmarshal(doc, &v)
+	is.NoErr(err)
+
+	expected, err := xml.Marshal(v)
+	is.NoErr(err)
+
+	got, err := MarshalVast(&v)
+	is.NoErr(err)
+
+	is.Equal(string(expected), string(got))
+}
+
+func TestMarshalAdvertiserFast(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVastAdvertiser.xml")
+	is.NoErr(err)
+
+	var v VAST
+	err = xml.Unmarshal(doc, &v)
+	is.NoErr(err)
+
+	expected, err := xml.Marshal(v)
+	is.NoErr(err)
+
+	got, err := MarshalVast(&v)
+	is.NoErr(err)
+
+	is.Equal(string(expected), string(got))
+	is.True(strings.Contains(string(got), `<Advertiser id="acme-001">Acme Corp</Advertiser>`))
+	is.True(strings.Contains(string(got),
+		`<Description><![CDATA[Thirty second spot for the spring campaign]]></Description>`))
+	is.True(!strings.Contains(string(got[strings.Index(string(got), `id="ADVERTISER_AD_002"`):]), "<Description"))
+
+	v.Ad[0].InLine.Advertiser.Id = ""
+	got, err = MarshalVast(&v)
+	is.NoErr(err)
+	is.True(strings.Contains(string(got), `<Advertiser>Acme Corp</Advertiser>`))
+}
+
+func TestMarshalSurveyFast(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVastAdvertiser.xml")
+	is.NoErr(err)
+
+	v, err := DecodeVast(doc)
+	is.NoErr(err)
+
+	expected, err := xml.Marshal(v)
+	is.NoErr(err)
+	got, err := MarshalVast(&v)
+	is.NoErr(err)
+
+	is.Equal(string(expected), string(got))
+	is.True(strings.Contains(string(got),
+		`<Survey type="text/html"><![CDATA[https://survey.example.com/lift?ad=1]]></Survey>`))
+	is.True(strings.Contains(string(got), `<Survey><![CDATA[https://survey.example.com/lift?ad=2&s=1]]></Survey>`))
+
+	roundTrip, err := DecodeVast(got)
+	is.NoErr(err)
+	is.Equal(*roundTrip.Ad[0].InLine.Survey, *v.Ad[0].InLine.Survey)
+	is.Equal(*roundTrip.Ad[1].InLine.Survey, *v.Ad[1].InLine.Survey)
+}
+
+func TestMarshalViewableImpressionFast(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVastViewable.xml")
+	is.NoErr(err)
+
+	v, err := DecodeVast(doc)
+	is.NoErr(err)
+
+	expected, err := xml.Marshal(v)
+	is.NoErr(err)
+	got, err := MarshalVast(&v)
+	is.NoErr(err)
+
+	is.Equal(string(expected), string(got))
+	is.True(strings.Contains(string(got),
+		`<Viewable>https://adserver.example.com/viewable?ad=1&amp;v=1</Viewable>`))
+	is.True(strings.Contains(string(got), `<ViewableImpression id="VIEWABLE-ID_002"></ViewableImpression>`))
+
+	roundTrip, err := DecodeVast(got)
+	is.NoErr(err)
+	is.Equal(roundTrip.Ad[0].InLine.ViewableImpression, v.Ad[0].InLine.ViewableImpression)
+}
+
+func TestMarshalAdVerificationsFast(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVastAdVerifications.xml")
+	is.NoErr(err)
+
+	v, err := DecodeVast(doc)
+	is.NoErr(err)
+
+	expected, err := xml.Marshal(v)
+	is.NoErr(err)
+	got, err := MarshalVast(&v)
+	is.NoErr(err)
+
+	is.Equal(string(expected), string(got))
+	is.True(strings.Contains(string(got), `<JavaScriptResource apiFramework="omid" browserOptional="true">`))
+	is.True(strings.Contains(string(got),
+		"<VerificationParameters><![CDATA[\n  moatClientLevel1=Acme&moatClientLevel2=Spring\n]]></VerificationParameters>"))
+
+	roundTrip, err := DecodeVast(got)
+	is.NoErr(err)
+	is.Equal(roundTrip.Ad[0].InLine.AdVerifications, v.Ad[0].InLine.AdVerifications)
+	is.Equal(roundTrip.Ad[1].Wrapper.AdVerifications, v.Ad[1].Wrapper.AdVerifications)
+
+	v.Ad[0].InLine.AdVerifications = nil
+	got, err = MarshalVast(&v)
+	is.NoErr(err)
+	is.True(!strings.Contains(string(got[:strings.Index(string(got), "<Wrapper")]), "AdVerifications"))
+}
+
+func TestMarshalAdParametersFast(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVastAdParameters.xml")
+	is.NoErr(err)
+
+	var v VAST
+	err = xml.Unmarshal(doc, &v)
+	is.NoErr(err)
+	// "]]>" cannot appear in CDATA, so it is split over two sections
+	v.Ad[0].InLine.Creatives[0].Linear.AdParameters = &AdParameters{Value: ` {"end":"]]>"} `}
+
+	expected, err := xml.Marshal(v)
+	is.NoErr(err)
+	got, err := MarshalVast(&v)
+	is.NoErr(err)
+	is.Equal(string(expected), string(got))
+	is.True(strings.Contains(string(got),
+		`<Duration>00:00:30</Duration><AdParameters><![CDATA[ {"end":"]]]]><![CDATA[>"} ]]></AdParameters>`))
+	is.True(strings.Contains(string(got),
+		`<AdParameters xmlEncoded="true"><![CDATA[<params id="a&b"></params>]]></AdParameters>`))
+
+	decoded, err := DecodeVast(got)
+	is.NoErr(err)
+	scanned, err := DecodeVastScan(got)
+	is.NoErr(err)
+	for _, rt := range []VAST{decoded, scanned} {
+		is.Equal(rt.Ad[0].InLine.Creatives[0].Linear.AdParameters.Value, ` {"end":"]]>"} `)
+		is.Equal(*rt.Ad[1].InLine.Creatives[0].Linear.AdParameters, *v.Ad[1].InLine.Creatives[0].Linear.AdParameters)
+	}
+}
+
+func TestMarshalIconsFast(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVastIcons.xml")
+	is.NoErr(err)
+
+	var v VAST
+	err = xml.Unmarshal(doc, &v)
+	is.NoErr(err)
+	expected, err := xml.Marshal(v)
+	is.NoErr(err)
+	got, err := MarshalVast(&v)
+	is.NoErr(err)
+	is.Equal(string(expected), string(got))
+	is.True(strings.Contains(string(got), `<Icons><Icon program="AdChoices" width="77" height="15" `+
+		`xPosition="right" yPosition="top" duration="00:00:30" offset="00:00:00">`))
+	is.True(strings.Contains(string(got), `<IconClicks><IconClickThrough>https://optout.example.com/?ad=icons1`+
+		`</IconClickThrough><IconClickTracking>https://adserver.example.com/icon/click?ad=icons1&amp;p=adchoices`+
+		`</IconClickTracking><IconClickTracking>https://thirdparty.example.com/icon/click?ad=icons1`+
+		`</IconClickTracking></IconClicks>`))
+
+	rt, err := DecodeVastScan(got)
+	is.NoErr(err)
+	is.Equal(rt.Ad[0].InLine.Creatives[0].Linear.Icons, v.Ad[0].InLine.Creatives[0].Linear.Icons)
+
+	j, err := json.Marshal(v.Ad[0].InLine.Creatives[0].Linear)
+	is.NoErr(err)
+	is.True(strings.Contains(string(j), `"duration":"00:00:30","offset":"00:00:00"`))
+	var l Linear
+	is.NoErr(json.Unmarshal(j, &l))
+	is.Equal(l.Icons, v.Ad[0].InLine.Creatives[0].Linear.Icons)
+
+	// a Linear without icons has no Icons element
+	v.Ad[0].InLine.Creatives[0].Linear.Icons = nil
+	expected, err = xml.Marshal(v)
+	is.NoErr(err)
+	got, err = MarshalVast(&v)
+	is.NoErr(err)
+	is.Equal(string(expected), string(got))
+	is.True(!strings.Contains(string(got), "<Icons>"))
+}
+
+func TestMarshalAdBreakExtraAttrsFast(t *testing.T) {
+	is := is.New(t)
+	v := &VMAP{Version: "1.0", AdBreaks: []AdBreak{{
+		Id:         "pre",
+		BreakType:  BreakTypeLinear,
+		TimeOffset: TimeOffset{Duration: &Duration{10 * time.Second}},
+		ExtraAttrs: map[string]string{"vendorData": `a&b "c"`, "placementId": "p-42"},
+	}}}
+
+	expected, err := xml.Marshal(v)
+	is.NoErr(err)
+	got, err := MarshalVmap(v)
+	is.NoErr(err)
+	is.Equal(string(expected), string(got))
+	is.True(strings.Contains(string(got), `<AdBreak breakId="pre" breakType="linear" timeOffset="00:00:10"`+
+		` placementId="p-42" vendorData="a&amp;b &#34;c&#34;">`))
+
+	for _, decode := range []func([]byte) (VMAP, error){DecodeVmap, DecodeVmapScan} {
+		rt, err := decode(got)
+		is.NoErr(err)
+		is.Equal(rt.AdBreaks[0].ExtraAttrs, v.AdBreaks[0].ExtraAttrs)
+	}
+	c := v.Clone()
+	c.AdBreaks[0].ExtraAttrs["placementId"] = "changed"
+	is.Equal(v.AdBreaks[0].ExtraAttrs["placementId"], "p-42")
+
+	// keys that would repeat a modelled attribute or declare a namespace are skipped
+	c.AdBreaks[0].ExtraAttrs = map[string]string{"breakId": "dup", "timeOffset": "end", "repeatAfter": "x",
+		"breakType": "display", "xmlns": "urn:x", "x:y": "z", "placementId": "p-42"}
+	expected, err = xml.Marshal(c)
+	is.NoErr(err)
+	got, err = MarshalVmap(c)
+	is.NoErr(err)
+	is.Equal(string(expected), string(got))
+	is.True(strings.Contains(string(got), `<AdBreak breakId="pre" breakType="linear" timeOffset="00:00:10"`+
+		` placementId="p-42">`))
+}
+
+func TestMarshalMediaFileAttributesFast(t *testing.T) {
+	is := is.New(t)
+	yes, no := true, false
+	v := &VAST{Version: "4.2", Ad: []Ad{{Id: "1", InLine: &InLine{Creatives: []Creative{{Linear: &Linear{
+		MediaFiles: []MediaFile{
+			{
+				Text: "https://cdn.example.com/ad.m3u8", Delivery: DeliveryStreaming,
+				MediaType: "application/x-mpegURL", Id: "mf-1", MinBitrate: 800, MaxBitrate: 6000,
+				Scalable: &yes, MaintainAspectRatio: &no, ApiFramework: "VPAID", FileSize: 123456,
+			},
+			{Text: "https://cdn.example.com/ad.mp4", Delivery: DeliveryProgressive, MediaType: "video/mp4",
+				Bitrate: 500},
+		},
+	}}}}}}}
+
+	expected, err := xml.Marshal(v)
+	is.NoErr(err)
+	got, err := MarshalVast(v)
+	is.NoErr(err)
+	is.Equal(string(expected), string(got))
+	is.True(strings.Contains(string(got), `<MediaFile bitrate="0" width="0" height="0" delivery="streaming"`+
+		` type="application/x-mpegURL" codec="" id="mf-1" minBitrate="800" maxBitrate="6000" scalable="true"`+
+		` maintainAspectRatio="false" apiFramework="VPAID" fileSize="123456">`))
+	is.True(strings.Contains(string(got), `<MediaFile bitrate="500" width="0" height="0" delivery="progressive"`+
+		` type="video/mp4" codec="">`))
+
+	rt, err := DecodeVastScan(got)
+	is.NoErr(err)
+	is.Equal(rt.Ad[0].InLine.Creatives[0].Linear.MediaFiles, v.Ad[0].InLine.Creatives[0].Linear.MediaFiles)
+}
+
+func TestMarshalInteractiveCreativeFileFast(t *testing.T) {
+	is := is.New(t)
+	yes := true
+	v := &VAST{Version: "4.2", Ad: []Ad{{Id: "1", InLine: &InLine{Creatives: []Creative{{Linear: &Linear{
+		InteractiveCreativeFiles: []InteractiveCreativeFile{
+			{
+				Text: "https://cdn.example.com/ads/simid/index.html", MediaType: "text/html",
+				ApiFramework: APIFrameworkSIMID, VariableDuration: &yes,
+			},
+			{Text: "https://cdn.example.com/ads/simid/other.html"},
+		},
+	}}}}}}}
+
+	expected, err := xml.Marshal(v)
+	is.NoErr(err)
+	got, err := MarshalVast(v)
+	is.NoErr(err)
+	is.Equal(string(expected), string(got))
+	is.True(strings.Contains(string(got), `<InteractiveCreativeFile type="text/html" apiFramework="SIMID"`+
+		` variableDuration="true">`))
+
+	rt, err := DecodeVastScan(got)
+	is.NoErr(err)
+	is.Equal(rt.Ad[0].InLine.Creatives[0].Linear.InteractiveCreativeFiles,
+		v.Ad[0].InLine.Creatives[0].Linear.InteractiveCreativeFiles)
+	cdata, err := MarshalVastWithOptions(v, MarshalOptions{CDATA: true})
+	is.NoErr(err)
+	is.True(strings.Contains(string(cdata),
+		`variableDuration="true"><![CDATA[https://cdn.example.com/ads/simid/index.html]]>`))
+}
+
+func TestMarshalAdServingIdFast(t *testing.T) {
+	is := is.New(t)
+	for _, tc := range []struct {
+		vast VAST
+		want string
+	}{
+		{
+			VAST{Version: "3.0", Ad: []Ad{{Id: "1", InLine: &InLine{AdTitle: "old"}}}},
+			`<AdTitle>old</AdTitle><Creatives>`,
+		},
+		{
+			VAST{Version: "4.1", Ad: []Ad{{Id: "1", InLine: &InLine{AdTitle: "new", AdServingId: "a1"}}}},
+			`<AdTitle>new</AdTitle><AdServingId>a1</AdServingId>`,
+		},
+	} {
+		expected, err := xml.Marshal(tc.vast)
+		is.NoErr(err)
+		got, err := MarshalVast(&tc.vast)
+		is.NoErr(err)
+		is.Equal(string(expected), string(got))
+		is.True(strings.Contains(string(got), tc.want))
+	}
+}
+
+func TestMarshalCustomAdDataFast(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVmapCustomAdData.xml")
+	is.NoErr(err)
+
+	var v VMAP
+	err = xml.Unmarshal(doc, &v)
+	is.NoErr(err)
+
+	expected, err := xml.Marshal(v)
+	is.NoErr(err)
+
+	got, err := MarshalVmap(&v)
+	is.NoErr(err)
+
+	is.Equal(string(expected), string(got))
+
+	var roundTripped VMAP
+	err = xml.Unmarshal(got, &roundTripped)
+	is.NoErr(err)
+	is.Equal(*roundTripped.AdBreaks[0].AdSource.CustomAdData, *v.AdBreaks[0].AdSource.CustomAdData)
+	is.Equal(*roundTripped.AdBreaks[1].AdSource.CustomAdData, *v.AdBreaks[1].AdSource.CustomAdData)
+}
+
+func TestMarshalVastCDATA(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVast2.xml")
+	is.NoErr(err)
+
+	var v VAST
+	err = xml.Unmarshal(doc, &v)
+	is.NoErr(err)
+
+	plain, err := MarshalVastWithOptions(&v, MarshalOptions{})
+	is.NoErr(err)
+	expected, err := xml.Marshal(v)
+	is.NoErr(err)
+	is.Equal(string(plain), string(expected))
+
+	got, err := MarshalVastWithOptions(&v, MarshalOptions{CDATA: true})
+	is.NoErr(err)
+	out := string(got)
+	imp := v.Ad[0].InLine.Impression[0]
+	is.True(strings.Contains(out, `<Impression id="`+imp.Id+`"><![CDATA[`+imp.Text+`]]></Impression>`))
+	is.True(strings.Contains(out, `<ClickTracking id="`))
+	is.True(!strings.Contains(out, "&amp;"))
+
+	var roundTripped VAST
+	err = xml.Unmarshal(got, &roundTripped)
+	is.NoErr(err)
+	is.Equal(roundTripped, v)
+}
+
+func TestMarshalWrapperFast(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVastWrapper.xml")
+	is.NoErr(err)
+
+	var v VAST
+	err = xml.Unmarshal(doc, &v)
+	is.NoErr(err)
+
+	expected, err := xml.Marshal(v)
+	is.NoErr(err)
+
+	got, err := MarshalVast(&v)
+	is.NoErr(err)
+
+	is.Equal(string(expected), string(got))
+
+	roundTripped, err := DecodeVast(got)
+	is.NoErr(err)
+	is.Equal(roundTripped.Ad[0].Wrapper.VASTAdTagURI, v.Ad[0].Wrapper.VASTAdTagURI)
+	is.Equal(*roundTripped.Ad[0].Wrapper.Creatives[1].CompanionAds, *v.Ad[0].Wrapper.Creatives[1].CompanionAds)
+}
+
+func TestMarshalWrapperControlsFast(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVastWrapper.xml")
+	is.NoErr(err)
+
+	var v VAST
+	err = xml.Unmarshal(doc, &v)
+	is.NoErr(err)
+
+	got, err := MarshalVast(&v)
+	is.NoErr(err)
+	is.True(strings.Contains(string(got),
+		`<Wrapper followAdditionalWrappers="true" allowMultipleAds="false" fallbackOnNoAd="true">`))
+
+	v.Ad[0].Wrapper.AllowMultipleAds = nil
+	expected, err := xml.Marshal(v)
+	is.NoErr(err)
+	got, err = MarshalVast(&v)
+	is.NoErr(err)
+	is.Equal(string(expected), string(got))
+	is.True(strings.Contains(string(got), `<Wrapper followAdditionalWrappers="true" fallbackOnNoAd="true">`))
+}
+
+func TestMarshalRepeatAfterFast(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVmapRepeatAfter.xml")
+	is.NoErr(err)
+
+	var v VMAP
+	err = xml.Unmarshal(doc, &v)
+	is.NoErr(err)
+
+	expected, err := xml.Marshal(v)
+	is.NoErr(err)
+
+	got, err := MarshalVmap(&v)
+	is.NoErr(err)
+
+	is.Equal(string(expected), string(got))
+	is.True(strings.Contains(string(got), `timeOffset="00:10:00" repeatAfter="00:15:00">`))
+}
+
+func TestMarshalVastErrorFast(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVmapNoAd.xml")
+	is.NoErr(err)
+
+	var v VMAP
+	err = xml.Unmarshal(doc, &v)
+	is.NoErr(err)
+
+	expected, err := xml.Marshal(v)
+	is.NoErr(err)
+
+	got, err := MarshalVmap(&v)
+	is.NoErr(err)
+
+	is.Equal(string(expected), string(got))
+	is.True(strings.Contains(string(got), `<Error>https://thirdparty.example.com/noad?pos=mid</Error></VAST>`))
+
+	got, err = MarshalVmapWithOptions(&v, MarshalOptions{CDATA: true})
+	is.NoErr(err)
+	is.True(strings.Contains(string(got),
+		`<Error><![CDATA[https://adserver.example.com/noad?code=[ERRORCODE]&pos=mid]]></Error>`))
+}
+
+func TestMarshalNamespacedVastFast(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVast41Namespaced.xml")
+	is.NoErr(err)
+
+	var v VAST
+	err = xml.Unmarshal(doc, &v)
+	is.NoErr(err)
+
+	expected, err := xml.Marshal(v)
+	is.NoErr(err)
+
+	got, err := MarshalVast(&v)
+	is.NoErr(err)
+
+	is.Equal(string(expected), string(got))
+	is.True(strings.HasPrefix(string(got), `<VAST xmlns="http://www.iab.com/VAST" xsi=`))
+	is.True(strings.Contains(string(got),
+		`<Ad id="NS_AD_001" sequence="1" conditionalAd="true" adType="video">`))
+	is.True(strings.Contains(string(got),
+		`<CreativeExtension type="moat"><Moat partner="example"><Tag id="1">abc</Tag></Moat></CreativeExtension>`))
+	is.True(strings.Contains(string(got), `<UniversalAdId idRegistry="ad-id.org">CNPA0484000H</UniversalAdId>`+
+		`<UniversalAdId idRegistry="broadcaster.example.com">BRD-2024-0042</UniversalAdId>`))
+
+	v.Xmlns = ""
+	got, err = MarshalVast(&v)
+	is.NoErr(err)
+	is.True(strings.HasPrefix(string(got), `<VAST xsi=`))
+
+	// creatives without extensions stay without them after a round trip
+	v.Ad[0].InLine.Creatives[0].CreativeExtensions = nil
+	expected, err = xml.Marshal(v)
+	is.NoErr(err)
+	got, err = MarshalVast(&v)
+	is.NoErr(err)
+	is.Equal(string(expected), string(got))
+	is.True(!strings.Contains(string(got), `<CreativeExtensions>`))
+	for _, decode := range []func([]byte) (VAST, error){DecodeVast, DecodeVastScan} {
+		rt, err := decode(got)
+		is.NoErr(err)
+		is.Equal(rt.Ad[0].InLine.Creatives[0].CreativeExtensions, nil)
+	}
+}
+
+func TestCreativeExtensionRoundTrip(t *testing.T) {
+	is := is.New(t)
+	inner := `
+              <celtra:Meta xmlns:celtra="http://celtra.example.com/ns" celtra:version="2">
+                <celtra:Id>a&amp;b</celtra:Id>
+                <celtra:Empty/><!-- placement -->
+                <celtra:Data><![CDATA[{"x":1}]]></celtra:Data>
+              </celtra:Meta>
+            `
+	doc := []byte(`<VAST version="4.1"><Ad id="a"><InLine><Creatives><Creative id="c"><CreativeExtensions>` +
+		`<CreativeExtension type="celtra">` + inner + `</CreativeExtension>` +
+		`</CreativeExtensions></Creative></Creatives></InLine></Ad></VAST>`)
+
+	var unmarshaled VAST
+	err := xml.Unmarshal(doc, &unmarshaled)
+	is.NoErr(err)
+	scanned, err := DecodeVastScan(doc)
+	is.NoErr(err)
+	decoded, err := DecodeVast(doc)
+	is.NoErr(err)
+
+	for _, v := range []VAST{unmarshaled, scanned, decoded} {
+		is.Equal(v.Ad[0].InLine.Creatives[0].CreativeExtensions,
+			CreativeExtensions{{ExtensionType: "celtra", InnerXML: inner}})
+
+		expected, err := xml.Marshal(v)
+		is.NoErr(err)
+		got, err := MarshalVast(&v)
+		is.NoErr(err)
+		is.Equal(string(expected), string(got))
+		is.True(strings.Contains(string(got), `<CreativeExtension type="celtra">`+inner+`</CreativeExtension>`))
+	}
+}
+
+func TestMarshalAdSequenceFast(t *testing.T) {
+	is := is.New(t)
+	zero, two := 0, 2
+	v := VAST{Version: "4.1", Ad: []Ad{
+		{Id: "standalone", InLine: &InLine{AdTitle: "a"}},
+		{Id: "zero", Sequence: &zero, InLine: &InLine{AdTitle: "b"}},
+		{Id: "second", Sequence: &two, InLine: &InLine{AdTitle: "c"}},
+	}}
+
+	expected, err := xml.Marshal(v)
+	is.NoErr(err)
+
+	got, err := MarshalVast(&v)
+	is.NoErr(err)
+
+	is.Equal(string(expected), string(got))
+	is.True(strings.Contains(string(got), `<Ad id="standalone">`))
+	is.True(strings.Contains(string(got), `<Ad id="zero" sequence="0">`))
+	is.True(strings.Contains(string(got), `<Ad id="second" sequence="2">`))
+}
+
+func TestMarshalTrackingOffsetFast(t *testing.T) {
+	is := is.New(t)
+	doc := []byte(`<VAST version="4.1"><Ad id="1"><InLine><Creatives><Creative id="c1"><Linear>` +
+		`<TrackingEvents>` +
+		`<Tracking event="start"><![CDATA[https://t.example.com/start]]></Tracking>` +
+		`<Tracking event="progress" offset="00:00:05.000">https://t.example.com/5s</Tracking>` +
+		`<Tracking event="progress" offset="50%">https://t.example.com/half</Tracking>` +
+		`<Tracking event="progress" offset="00:00:10">https://t.example.com/10s</Tracking>` +
+		`<Tracking event="complete" offset="00:00:10">https://t.example.com/complete</Tracking>` +
+		`</TrackingEvents></Linear></Creative></Creatives></InLine></Ad></VAST>`)
+
+	var v VAST
+	err := xml.Unmarshal(doc, &v)
+	is.NoErr(err)
+
+	expected, err := xml.Marshal(v)
+	is.NoErr(err)
+
+	got, err := MarshalVast(&v)
+	is.NoErr(err)
+
+	is.Equal(string(expected), string(got))
+	is.True(strings.Contains(string(got), `<Tracking event="start">`))
+	is.True(strings.Contains(string(got), `<Tracking event="progress" offset="00:00:05">`))
+}
+
+func TestMarshalDescriptionFast(t *testing.T) {
+	is := is.New(t)
+	v := VAST{Version: "3.0", Ad: []Ad{
+		{Id: "1", InLine: &InLine{Description: "Spring & summer <sale>\nsecond line"}},
+		{Id: "2", InLine: &InLine{}},
+	}}
+
+	expected, err := xml.Marshal(v)
+	is.NoErr(err)
+
+	got, err := MarshalVast(&v)
+	is.NoErr(err)
+
+	is.Equal(string(expected), string(got))
+	is.True(strings.Contains(string(got), "<Description><![CDATA[Spring & summer <sale>\nsecond line]]></Description>"))
+	is.Equal(strings.Count(string(got), "<Description>"), 1)
+
+	for _, decode := range []func([]byte) (VAST, error){DecodeVast, DecodeVastScan} {
+		rt, err := decode(got)
+		is.NoErr(err)
+		is.Equal(rt.Ad[0].InLine.Description, v.Ad[0].InLine.Description)
+	}
+
+	// a CDATA end marker in the text is split over two sections
+	v.Ad[0].InLine.Description = "before ]]> after"
+	expected, err = xml.Marshal(v)
+	is.NoErr(err)
+	got, err = MarshalVast(&v)
+	is.NoErr(err)
+	is.Equal(string(expected), string(got))
+}
+
+func TestMarshalSpecialCharsFast(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVastSpecialChars.xml")
+	is.NoErr(err)
+
+	var v VAST
+	err = xml.Unmarshal(doc, &v)
+	is.NoErr(err)
+
+	expected, err := xml.Marshal(v)
+	is.NoErr(err)
+
+	got, err := MarshalVast(&v)
+	is.NoErr(err)
+
+	is.Equal(string(expected), string(got))
+}
+
+// --- Fast Marshal Benchmarks ---
+
+func BenchmarkXMLMarshalVmap(b *testing.B) {
+	doc, err := os.ReadFile("sample-vmap/testVmap.xml")
+	if err != nil {
+		b.Fatal(err)
+	}
+	var v VMAP
+	if err := xml.Unmarshal(doc, &v); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = xml.Marshal(v)
+	}
+}
+
+func BenchmarkFastMarshalVmap(b *testing.B) {
+	doc, err := os.ReadFile("sample-vmap/testVmap.xml")
+	if err != nil {
+		b.Fatal(err)
+	}
+	var v VMAP
+	if err := xml.Unmarshal(doc, &v); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = MarshalVmap(&v)
+	}
+}
+
+func BenchmarkXMLMarshalVast(b *testing.B) {
+	doc, err := os.ReadFile("sample-vmap/testVast.xml")
+	if err != nil {
+		b.Fatal(err)
+	}
+	var v VAST
+	if err := xml.Unmarshal(doc, &v); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = xml.Marshal(v)
+	}
+}
+
+func BenchmarkFastMarshalVast(b *testing.B) {
+	doc, err := os.ReadFile("sample-vmap/testVast.xml")
+	if err != nil {
+		b.Fatal(err)
+	}
+	var v VAST
+	if err := xml.Unmarshal(doc, &v); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = MarshalVast(&v)
+	}
+}
+
+func TestDecodeCompliance(t *testing.T) {
+	wg := sync.WaitGroup{}
+	//Check for race conditions
+	for range 1000 {
+		wg.Add(1)
+		go func(wg *sync.WaitGroup, t *testing.T) {
+			defer wg.Done()
+			is := is.New(t)
+			doc, err := os.ReadFile("sample-vmap/testVmap.xml")
+			is.NoErr(err)
+
+			var vmap1 VMAP
+			err = xml.Unmarshal(doc, &vmap1)
+			is.NoErr(err)
+
+			vmap2, err := DecodeVmap(doc)
+			is.NoErr(err)
+
+			is.Equal(vmap1.Version, vmap2.Version)
+			is.Equal(vmap1.Vmap, vmap2.Vmap)
+			is.Equal(vmap1.XMLName.Local, vmap2.XMLName.Local)
+			is.Equal(vmap1.XMLName.Space, vmap2.XMLName.Space)
+
+			is.Equal(len(vmap1.AdBreaks), len(vmap2.AdBreaks))
+			for i := range vmap1.AdBreaks {
+				adb1 := vmap1.AdBreaks[i]
+				adb2 := vmap2.AdBreaks[i]
+				is.Equal(adb1.BreakType, adb2.BreakType)
+				is.Equal(adb1.Id, adb2.Id)
+				is.Equal(adb1.TimeOffset, adb2.TimeOffset)
+				is.Equal(adb1.TimeOffset.Duration, adb2.TimeOffset.Duration)
+				is.Equal(adb1.TimeOffset.Position, adb2.TimeOffset.Position)
+
+				if adb1.TrackingEvents != nil {
+					te1 := adb1.TrackingEvents
+					te2 := adb2.TrackingEvents
 
 					for j := range te1 {
 						abt1 := te1[j]
@@ -694,12 +2642,10 @@ func TestDecodeCompliance(t *testing.T) {
 					is.Equal(ad1.Id, ad2.Id)
 					is.Equal(ad1.Sequence, ad2.Sequence)
 					if ad1.InLine != nil {
-						is.Equal(strings.TrimSpace(ad1.InLine.AdSystem), strings.TrimSpace(ad2.InLine.AdSystem))
+						is.Equal(strings.TrimSpace(ad1.InLine.AdSystem.String()),
+							strings.TrimSpace(ad2.InLine.AdSystem.String()))
 						is.Equal(strings.TrimSpace(ad1.InLine.AdTitle), strings.TrimSpace(ad2.InLine.AdTitle))
 						is.Equal(ad1.InLine.Error, ad2.InLine.Error)
-						if ad1.InLine.Error != nil {
-							is.Equal(ad1.InLine.Error.Value, ad2.InLine.Error.Value)
-						}
 						if ad1.InLine.Creatives != nil {
 							for i := range ad1.InLine.Creatives {
 								for j := range ad1.InLine.Creatives[i].Linear.TrackingEvents {
@@ -0,0 +1,94 @@
+package vmap
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Tracker fires tracking URLs for a player in the background. Failed
+// requests are retried as FetchWithRetry does; URLs that still fail are
+// reported to OnError.
+//
+// The requests are bound to the ctx given to FireImpressions and FireEvent,
+// so cancelling it abandons the requests and retries still pending. When
+// firing on behalf of a request that ends before the beacons are sent, pass
+// a context that outlives it, e.g. context.WithoutCancel(ctx).
+type Tracker struct {
+	// OnError, if set, is called with each URL that could not be fired and
+	// the last error. It is called from the request goroutine and must be
+	// safe for concurrent use.
+	OnError func(url string, err error)
+	// Retry controls how failed requests are repeated.
+	Retry RetryOptions
+
+	client *http.Client
+	wg     sync.WaitGroup
+}
+
+// NewTracker returns a Tracker that fires requests with client, making up
+// to three attempts with a backoff starting at 500ms. If client is nil,
+// http.DefaultClient is used.
+func NewTracker(client *http.Client) *Tracker {
+	return &Tracker{client: client, Retry: RetryOptions{MaxAttempts: 3, InitialBackoff: 500 * time.Millisecond}}
+}
+
+// FireImpressions fires the impression URLs of the ad, with macros
+// substituted as by Impression.Fire. It does not wait for the requests.
+func (t *Tracker) FireImpressions(ctx context.Context, ad *Ad, macros map[string]string) {
+	var imps []Impression
+	switch {
+	case ad.InLine != nil:
+		imps = ad.InLine.Impression
+	case ad.Wrapper != nil:
+		imps = ad.Wrapper.Impression
+	}
+	m := NewMacroReplacer(withDefaultMacros(macros))
+	for _, imp := range imps {
+		t.fire(ctx, m.Replace(imp.Text))
+	}
+}
+
+// FireEvent fires the URLs of the creative's tracking events named
+// eventName, e.g. EventStart, with macros substituted. [TIMESTAMP] and
+// [CACHEBUSTING] are filled in unless given. It does not wait for the
+// requests.
+func (t *Tracker) FireEvent(ctx context.Context, l *Linear, eventName string, macros map[string]string) {
+	m := NewMacroReplacer(withDefaultMacros(macros))
+	for _, ev := range l.TrackingEvents {
+		if ev.Event == eventName {
+			t.fire(ctx, m.Replace(ev.Text))
+		}
+	}
+}
+
+// Wait blocks until all requests started by the Tracker have completed,
+// including their retries.
+func (t *Tracker) Wait() {
+	t.wg.Wait()
+}
+
+func (t *Tracker) fire(ctx context.Context, u string) {
+	if u == "" {
+		return
+	}
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		if err := t.fireWithRetry(ctx, u); err != nil && t.OnError != nil {
+			t.OnError(u, err)
+		}
+	}()
+}
+
+func (t *Tracker) fireWithRetry(ctx context.Context, u string) error {
+	if _, err := url.Parse(u); err != nil {
+		return fmt.Errorf("error parsing tracking url: %w", err)
+	}
+	return retry(ctx, t.Retry, func(int) error {
+		return fireURL(ctx, t.client, u)
+	}, nil)
+}
@@ -0,0 +1,91 @@
+package vmap
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestTracker(t *testing.T) {
+	is := is.New(t)
+	var mu sync.Mutex
+	var got []string
+	attempts := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, r.URL.RequestURI())
+		attempts[r.URL.Path]++
+		switch {
+		case r.URL.Path == "/flaky" && attempts["/flaky"] < 3:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case r.URL.Path == "/down":
+			w.WriteHeader(http.StatusBadGateway)
+		case r.URL.Path == "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	var failed []string
+	tr := NewTracker(srv.Client())
+	tr.Retry.InitialBackoff = time.Millisecond
+	tr.OnError = func(url string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		failed = append(failed, url)
+	}
+
+	ad := &Ad{InLine: &InLine{Impression: []Impression{
+		{Text: srv.URL + "/imp?cb=[CACHEBUSTING]"},
+		{Text: " "},
+	}}}
+	tr.FireImpressions(context.Background(), ad, map[string]string{"CACHEBUSTING": "42"})
+
+	l := &Linear{TrackingEvents: []TrackingEvent{
+		{Event: EventStart, Text: srv.URL + "/start?ph=[CONTENTPLAYHEAD]"},
+		{Event: EventStart, Text: srv.URL + "/flaky"},
+		{Event: EventStart, Text: srv.URL + "/down"},
+		{Event: EventStart, Text: srv.URL + "/missing"},
+		{Event: EventComplete, Text: srv.URL + "/complete"},
+	}}
+	tr.FireEvent(context.Background(), l, EventStart, map[string]string{"CONTENTPLAYHEAD": "00:00:05.000"})
+	tr.Wait()
+
+	is.True(slices.Contains(got, "/imp?cb=42"))
+	is.True(slices.Contains(got, "/start?ph=00%3A00%3A05.000"))
+	is.True(!slices.Contains(got, "/complete"))
+	is.Equal(attempts["/flaky"], 3)   // succeeds on the last retry
+	is.Equal(attempts["/down"], 3)    // gives up after MaxAttempts
+	is.Equal(attempts["/missing"], 1) // 4xx is not retried
+	slices.Sort(failed)
+	is.Equal(failed, []string{srv.URL + "/down", srv.URL + "/missing"})
+}
+
+func TestTrackerCancel(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	var errs []error
+	tr := NewTracker(srv.Client())
+	tr.Retry.InitialBackoff = time.Hour
+	tr.OnError = func(_ string, err error) { errs = append(errs, err) }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tr.FireEvent(ctx, &Linear{TrackingEvents: []TrackingEvent{{Event: EventStart, Text: srv.URL}}}, EventStart, nil)
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	tr.Wait()
+	is.Equal(len(errs), 1)
+	is.True(errors.Is(errs[0], context.Canceled))
+}
@@ -0,0 +1,195 @@
+package vmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// MacroReplacer substitutes VAST macros such as [CACHEBUSTING] in tracking
+// URLs. Each macro is replaced in both its [NAME] and %%NAME%% forms.
+type MacroReplacer struct {
+	r *strings.Replacer
+}
+
+// NewMacroReplacer returns a MacroReplacer for macros keyed by name without
+// brackets, e.g. "CACHEBUSTING". Values are URL encoded when substituted.
+func NewMacroReplacer(macros map[string]string) *MacroReplacer {
+	oldnew := make([]string, 0, 4*len(macros))
+	for name, value := range macros {
+		value = url.QueryEscape(value)
+		oldnew = append(oldnew, "["+name+"]", value, "%%"+name+"%%", value)
+	}
+	return &MacroReplacer{r: strings.NewReplacer(oldnew...)}
+}
+
+// Replace returns u with the macros substituted and surrounding whitespace
+// removed. Macros without a value are left in place.
+func (m *MacroReplacer) Replace(u string) string {
+	return m.r.Replace(strings.TrimSpace(u))
+}
+
+// ImpressionError is returned by Impression.Fire when the server responds
+// with a non-2xx status.
+type ImpressionError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *ImpressionError) Error() string {
+	return fmt.Sprintf("unexpected status %d firing impression %s", e.StatusCode, e.URL)
+}
+
+// Fire requests the impression URL with macros substituted. [TIMESTAMP] and
+// [CACHEBUSTING], also accepted as [CACHE_BUSTING], are filled in unless
+// given in macros. A non-2xx response is returned as *ImpressionError.
+// If client is nil, http.DefaultClient is used.
+func (imp Impression) Fire(ctx context.Context, client *http.Client, macros map[string]string) error {
+	return fireImpression(ctx, client, NewMacroReplacer(withDefaultMacros(macros)), imp.Text)
+}
+
+// FireImpressions fires all impression URLs of the ad concurrently, as
+// Impression.Fire does, and returns the errors of the requests that failed.
+func (il *InLine) FireImpressions(ctx context.Context, client *http.Client, macros map[string]string) []error {
+	m := NewMacroReplacer(withDefaultMacros(macros))
+	urls := make([]string, len(il.Impression))
+	for i, imp := range il.Impression {
+		urls[i] = m.Replace(imp.Text)
+	}
+	errs := fireURLs(ctx, client, urls)
+	for i, err := range errs {
+		errs[i] = impressionError(err)
+	}
+	return errs
+}
+
+func fireImpression(ctx context.Context, client *http.Client, m *MacroReplacer, u string) error {
+	return impressionError(fireURL(ctx, client, m.Replace(u)))
+}
+
+// impressionError returns err as an *ImpressionError if it is a
+// *StatusError, and unchanged otherwise.
+func impressionError(err error) error {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return &ImpressionError{URL: statusErr.URL, StatusCode: statusErr.StatusCode}
+	}
+	return err
+}
+
+// withDefaultMacros returns macros with [TIMESTAMP] and [CACHEBUSTING] added
+// unless already present.
+func withDefaultMacros(macros map[string]string) map[string]string {
+	m := maps.Clone(macros)
+	if m == nil {
+		m = make(map[string]string, 3)
+	}
+	if _, ok := m["TIMESTAMP"]; !ok {
+		m["TIMESTAMP"] = time.Now().UTC().Format("2006-01-02T15:04:05.000Z07:00")
+	}
+	cb, ok := m["CACHEBUSTING"]
+	if !ok {
+		cb, ok = m["CACHE_BUSTING"]
+	}
+	if !ok {
+		cb = fmt.Sprintf("%08d", rand.IntN(100_000_000))
+	}
+	m["CACHEBUSTING"], m["CACHE_BUSTING"] = cb, cb
+	return m
+}
+
+// FireNotExecuted fires the verificationNotExecuted tracking URLs of the
+// verification concurrently, with [REASON] replaced by reason, one of the
+// ReasonVerification constants, and macros substituted. [TIMESTAMP] and
+// [CACHEBUSTING] are filled in unless given. It returns the errors of the
+// requests that failed.
+func (v *Verification) FireNotExecuted(ctx context.Context, client *http.Client, reason int,
+	macros map[string]string) []error {
+	m := withDefaultMacros(macros)
+	m["REASON"] = strconv.Itoa(reason)
+	r := NewMacroReplacer(m)
+	var urls []string
+	for _, t := range v.TrackingEvents {
+		if t.Event == EventVerificationNotExecuted {
+			urls = append(urls, r.Replace(t.Text))
+		}
+	}
+	return fireURLs(ctx, client, urls)
+}
+
+// Fire requests the click tracking URL with macros substituted. [TIMESTAMP]
+// and [CACHEBUSTING] are filled in unless given in macros, as for
+// Impression.Fire. If client is nil, http.DefaultClient is used.
+func (ct ClickTracking) Fire(ctx context.Context, client *http.Client, macros map[string]string) error {
+	return fireURL(ctx, client, NewMacroReplacer(withDefaultMacros(macros)).Replace(ct.Text))
+}
+
+// FireClickTracking fires all click tracking URLs of the creative
+// concurrently, as ClickTracking.Fire does, and returns the errors of the
+// requests that failed.
+func (l *Linear) FireClickTracking(ctx context.Context, client *http.Client, macros map[string]string) []error {
+	m := NewMacroReplacer(withDefaultMacros(macros))
+	urls := make([]string, len(l.ClickTracking))
+	for i, ct := range l.ClickTracking {
+		urls[i] = m.Replace(ct.Text)
+	}
+	return fireURLs(ctx, client, urls)
+}
+
+// fireURLs fires the URLs concurrently and returns the non-nil errors. A
+// failed request does not cancel the others.
+func fireURLs(ctx context.Context, client *http.Client, urls []string) []error {
+	errs := make([]error, len(urls))
+	var g errgroup.Group
+	for i, u := range urls {
+		g.Go(func() error {
+			errs[i] = fireURL(ctx, client, u)
+			return nil
+		})
+	}
+	_ = g.Wait()
+	return nonNilErrors(errs)
+}
+
+// nonNilErrors returns the non-nil errors in errs, or nil if there are none.
+func nonNilErrors(errs []error) []error {
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	return failed
+}
+
+// fireURL issues a GET request to a tracking URL and discards the response.
+// If client is nil, http.DefaultClient is used.
+func fireURL(ctx context.Context, client *http.Client, url string) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("error creating tracking request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error firing tracking url: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &StatusError{URL: url, StatusCode: resp.StatusCode}
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package vmap
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestErrorURL(t *testing.T) {
+	is := is.New(t)
+	e := Error{Value: " https://adserver.example.com/error?code=[ERRORCODE]&alt=%%ERRORCODE%% "}
+	is.Equal(e.URL(ErrCodeMediaNotFound), "https://adserver.example.com/error?code=401&alt=401")
+
+	e = Error{Value: "https://adserver.example.com/error"}
+	is.Equal(e.URL(ErrCodeXMLParsing), "https://adserver.example.com/error")
+}
+
+func TestErrorFireWithCode(t *testing.T) {
+	is := is.New(t)
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.RawQuery
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	e := Error{Value: srv.URL + "/error?code=[ERRORCODE]"}
+	err := e.FireWithCode(context.Background(), srv.Client(), ErrCodeWrapperLimit)
+	is.NoErr(err)
+	is.Equal(got, "code=302")
+
+	e = Error{Value: srv.URL + "/missing?code=[ERRORCODE]"}
+	err = e.FireWithCode(context.Background(), srv.Client(), ErrCodeGeneralLinear)
+	is.True(err != nil)
+	is.Equal(got, "code=400")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = e.FireWithCode(ctx, srv.Client(), ErrCodeGeneralLinear)
+	is.True(err != nil)
+}
+
+func TestMacroReplacer(t *testing.T) {
+	is := is.New(t)
+	m := NewMacroReplacer(map[string]string{"CACHEBUSTING": "12345678", "CONTENTPLAYHEAD": "00:00:10.000"})
+	is.Equal(m.Replace(" https://t.example.com/c?cb=[CACHEBUSTING]&ph=%%CONTENTPLAYHEAD%%&ts=[TIMESTAMP] "),
+		"https://t.example.com/c?cb=12345678&ph=00%3A00%3A10.000&ts=[TIMESTAMP]")
+	is.Equal(NewMacroReplacer(nil).Replace("https://t.example.com/c?cb=[CACHEBUSTING]"),
+		"https://t.example.com/c?cb=[CACHEBUSTING]")
+}
+
+func TestFireClickTracking(t *testing.T) {
+	is := is.New(t)
+	var mu sync.Mutex
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		got = append(got, r.URL.RequestURI())
+		mu.Unlock()
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	macros := map[string]string{"CACHEBUSTING": "42"}
+
+	err := ClickTracking{Text: srv.URL + "/click?cb=[CACHEBUSTING]"}.Fire(context.Background(), srv.Client(), macros)
+	is.NoErr(err)
+	is.Equal(got, []string{"/click?cb=42"})
+
+	got = nil
+	l := Linear{ClickTracking: []ClickTracking{
+		{Text: srv.URL + "/a?cb=[CACHEBUSTING]"},
+		{Text: srv.URL + "/b?cb=%%CACHEBUSTING%%"},
+		{Text: srv.URL + "/missing"},
+	}}
+	errs := l.FireClickTracking(context.Background(), srv.Client(), macros)
+	is.Equal(len(errs), 1)
+	slices.Sort(got)
+	is.Equal(got, []string{"/a?cb=42", "/b?cb=42", "/missing"})
+
+	is.Equal((&Linear{}).FireClickTracking(context.Background(), srv.Client(), macros), nil)
+
+	// [CACHEBUSTING] and [TIMESTAMP] are filled in when not given
+	got = nil
+	err = ClickTracking{Text: srv.URL + "/click?cb=[CACHEBUSTING]&ts=[TIMESTAMP]"}.Fire(
+		context.Background(), srv.Client(), nil)
+	is.NoErr(err)
+	is.Equal(len(got), 1)
+	is.True(!strings.Contains(got[0], "CACHEBUSTING"))
+	is.True(!strings.Contains(got[0], "TIMESTAMP"))
+}
+
+func TestFireImpression(t *testing.T) {
+	is := is.New(t)
+	var mu sync.Mutex
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		got = append(got, r.URL.RequestURI())
+		mu.Unlock()
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	imp := Impression{Text: srv.URL + "/imp?cb=[CACHEBUSTING]&cb2=[CACHE_BUSTING]&ts=%%TIMESTAMP%%"}
+	is.NoErr(imp.Fire(context.Background(), srv.Client(), nil))
+	is.Equal(len(got), 1)
+	re := regexp.MustCompile(`^/imp\?cb=(\d{8})&cb2=(\d{8})&ts=\d{4}-\d\d-\d\dT\d\d%3A\d\d%3A\d\d\.\d{3}Z$`)
+	m := re.FindStringSubmatch(got[0])
+	is.True(m != nil)
+	is.Equal(m[1], m[2]) // both spellings get the same value
+
+	got = nil
+	macros := map[string]string{"CACHE_BUSTING": "42", "TIMESTAMP": "now"}
+	is.NoErr(imp.Fire(context.Background(), srv.Client(), macros))
+	is.Equal(got, []string{"/imp?cb=42&cb2=42&ts=now"})
+
+	err := Impression{Text: srv.URL + "/missing?cb=[CACHEBUSTING]"}.Fire(context.Background(), srv.Client(), macros)
+	var impErr *ImpressionError
+	is.True(errors.As(err, &impErr))
+	is.Equal(impErr.StatusCode, http.StatusNotFound)
+	is.Equal(impErr.URL, srv.URL+"/missing?cb=42")
+
+	got = nil
+	il := InLine{Impression: []Impression{
+		{Text: srv.URL + "/a?cb=[CACHEBUSTING]"},
+		{Text: srv.URL + "/b?cb=[CACHEBUSTING]"},
+		{Text: srv.URL + "/missing"},
+	}}
+	errs := il.FireImpressions(context.Background(), srv.Client(), macros)
+	is.Equal(len(errs), 1)
+	is.True(errors.As(errs[0], &impErr))
+	slices.Sort(got)
+	is.Equal(got, []string{"/a?cb=42", "/b?cb=42", "/missing"})
+
+	is.Equal((&InLine{}).FireImpressions(context.Background(), srv.Client(), nil), nil)
+}
+
+func TestFireVerificationNotExecuted(t *testing.T) {
+	is := is.New(t)
+	var mu sync.Mutex
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		got = append(got, r.URL.Path+"?"+r.URL.RawQuery)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	v := Verification{
+		Vendor: "integralads.com-omid",
+		TrackingEvents: []TrackingEvent{
+			{Event: EventVerificationNotExecuted, Text: srv.URL + "/jload?reason=[REASON]&cb=[CACHEBUSTING]"},
+			{Event: EventVerificationNotExecuted, Text: srv.URL + "/dv?r=%%REASON%%&id=[AD]"},
+			{Event: EventStart, Text: srv.URL + "/start"},
+		},
+	}
+	errs := v.FireNotExecuted(context.Background(), srv.Client(), ReasonVerificationRejected,
+		map[string]string{"CACHEBUSTING": "12345678", "AD": "ad-1"})
+	is.Equal(len(errs), 0)
+	slices.Sort(got)
+	is.Equal(got, []string{"/dv?r=1&id=ad-1", "/jload?reason=1&cb=12345678"})
+
+	got = nil
+	errs = v.FireNotExecuted(context.Background(), srv.Client(), ReasonVerificationNotSupported, nil)
+	is.Equal(len(errs), 0)
+	slices.Sort(got)
+	is.Equal(got[0], "/dv?r=2&id=[AD]")
+	is.True(regexp.MustCompile(`^/jload\?reason=2&cb=\d{8}$`).MatchString(got[1]))
+
+	is.True(TrackingEvent{Event: EventVerificationNotExecuted}.KnownEvent())
+	is.Equal(len((&Verification{}).FireNotExecuted(context.Background(), srv.Client(), 1, nil)), 0)
+}
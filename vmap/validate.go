@@ -0,0 +1,339 @@
+package vmap
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ValidationError describes a problem found by Validate.
+type ValidationError struct {
+	// Path locates the offending element, e.g. "AdBreak[midroll-1].AdSource".
+	Path    string
+	Message string
+	// Err is the underlying typed error, if any, such as an *AdSourceError.
+	Err error
+}
+
+func (e ValidationError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+func (e ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// AdSourceError reports an AdSource that does not contain exactly one of
+// VASTAdData, AdTagURI and CustomAdData, or whose VASTAdData holds no VAST.
+type AdSourceError struct {
+	BreakId string
+	Message string
+}
+
+func (e *AdSourceError) Error() string {
+	return "invalid AdSource in AdBreak " + strconv.Quote(e.BreakId) + ": " + e.Message
+}
+
+// ValidateOptions selects checks beyond those of Validate for
+// ValidateWithOptions. The zero value gives the checks of Validate.
+type ValidateOptions struct {
+	// ServerSide adds the requirements of server-side ad insertion, where a
+	// stitcher transcodes the ads itself: every linear creative of a VAST 4
+	// InLine ad must have a Mezzanine.
+	ServerSide bool
+}
+
+// Validate checks the VMAP for problems that the decoders tolerate but the
+// spec does not allow. It returns nil if no problems were found.
+func (v *VMAP) Validate() []ValidationError {
+	return v.ValidateWithOptions(ValidateOptions{})
+}
+
+// ValidateWithOptions checks the VMAP like Validate, adding the checks
+// selected by opts.
+func (v *VMAP) ValidateWithOptions(opts ValidateOptions) []ValidationError {
+	var errs []ValidationError
+	for i := range v.AdBreaks {
+		ab := &v.AdBreaks[i]
+		path := adBreakPath(ab, i)
+		// breakType is required, but documents without it are common enough
+		// that only unknown values are reported.
+		if ab.BreakType != "" && !ab.BreakType.Valid() {
+			errs = append(errs, ValidationError{
+				Path:    path,
+				Message: "unknown breakType " + strconv.Quote(string(ab.BreakType)),
+			})
+		}
+		var asErr *AdSourceError
+		if errors.As(ab.ValidateAdSource(), &asErr) {
+			errs = append(errs, ValidationError{
+				Path:    path + ".AdSource",
+				Message: asErr.Message,
+				Err:     asErr,
+			})
+		}
+		if as := ab.AdSource; as != nil && as.VASTData != nil && as.VASTData.VAST != nil {
+			errs = as.VASTData.VAST.validate(errs, path+".AdSource.VASTAdData.VAST.", opts)
+		}
+	}
+	return errs
+}
+
+// Validate checks the VAST document for problems that the decoders tolerate
+// but the spec does not allow. It returns nil if no problems were found.
+func (v *VAST) Validate() []ValidationError {
+	return v.validate(nil, "", ValidateOptions{})
+}
+
+// ValidateWithOptions checks the VAST document like Validate, adding the
+// checks selected by opts.
+func (v *VAST) ValidateWithOptions(opts ValidateOptions) []ValidationError {
+	return v.validate(nil, "", opts)
+}
+
+// validate appends the problems found in v to errs, prefixing their paths with prefix.
+func (v *VAST) validate(errs []ValidationError, prefix string, opts ValidateOptions) []ValidationError {
+	_, _, versionErr := v.ParsedVersion()
+	// Without a known version, attributes are assumed to be allowed.
+	wrapperControls := versionErr != nil || v.SupportsFeature(FeatureWrapperControls)
+	requireAdServingId := v.SupportsFeature(FeatureAdServingId)
+	requireAuthority := v.SupportsFeature(FeatureCategoryAuthority)
+	requireMezzanine := opts.ServerSide && v.SupportsFeature(FeatureMezzanine)
+	surveyRemoved := v.AtLeast(VASTVersion43)
+	for i := range v.Ad {
+		ad := &v.Ad[i]
+		if ad.Wrapper != nil {
+			errs = ad.Wrapper.validate(errs, prefix+adPath(ad, i)+".Wrapper", wrapperControls)
+		}
+		if ad.InLine == nil {
+			continue
+		}
+		errs = ad.InLine.Pricing.validate(errs, prefix+adPath(ad, i)+".InLine.Pricing")
+		if ad.InLine.Survey != nil && surveyRemoved {
+			errs = append(errs, ValidationError{
+				Path:    prefix + adPath(ad, i) + ".InLine.Survey",
+				Message: "Survey was removed in VAST 4.3",
+			})
+		}
+		for j, cat := range ad.InLine.Categories {
+			if requireAuthority && cat.Value != "" && cat.Authority == "" {
+				errs = append(errs, ValidationError{
+					Path:    prefix + adPath(ad, i) + ".InLine.Category[" + strconv.Itoa(j) + "]",
+					Message: "category " + strconv.Quote(cat.Value) + " has no authority, required since VAST 4.1",
+				})
+			}
+		}
+		for j := range ad.InLine.Creatives {
+			c := &ad.InLine.Creatives[j]
+			if c.Linear != nil {
+				path := prefix + adPath(ad, i) + ".InLine." + creativePath(c.Id, j) + ".Linear"
+				errs = validateTrackingEvents(errs, path, c.Linear.TrackingEvents)
+				errs = c.Linear.validateSkipOffset(errs, path)
+				if requireMezzanine && len(c.Linear.Mezzanine) == 0 {
+					errs = append(errs, ValidationError{
+						Path:    path,
+						Message: "missing Mezzanine, required for server-side ad insertion",
+					})
+				}
+				for k, mf := range c.Linear.MediaFiles {
+					// Like breakType, a missing delivery is tolerated.
+					if mf.Delivery != "" && !mf.Delivery.Valid() {
+						errs = append(errs, ValidationError{
+							Path:    path + ".MediaFile[" + strconv.Itoa(k) + "]",
+							Message: "unknown delivery " + strconv.Quote(string(mf.Delivery)),
+						})
+					}
+				}
+				for k, m := range c.Linear.Mezzanine {
+					if m.Delivery != "" && !m.Delivery.Valid() {
+						errs = append(errs, ValidationError{
+							Path:    path + ".Mezzanine[" + strconv.Itoa(k) + "]",
+							Message: "unknown delivery " + strconv.Quote(string(m.Delivery)),
+						})
+					}
+				}
+			}
+		}
+		if ad.IsAudio() && ad.InLine.onlyVideoMediaFiles() {
+			errs = append(errs, ValidationError{
+				Path:    prefix + adPath(ad, i),
+				Message: "adType is audio but all MediaFiles are video",
+			})
+		}
+		if requireAdServingId && !ad.InLine.HasAdServingId() {
+			errs = append(errs, ValidationError{
+				Path:    prefix + adPath(ad, i) + ".InLine",
+				Message: "missing AdServingId, required since VAST 4.1",
+			})
+		}
+	}
+	return errs
+}
+
+// validate appends the problems found in the wrapper to errs. controls tells
+// whether the version of the document allows the wrapper control attributes.
+func (w *Wrapper) validate(errs []ValidationError, path string, controls bool) []ValidationError {
+	if strings.TrimSpace(w.VASTAdTagURI) == "" {
+		errs = append(errs, ValidationError{Path: path, Message: "missing VASTAdTagURI"})
+	}
+	errs = w.Pricing.validate(errs, path+".Pricing")
+	for i := range w.Creatives {
+		c := &w.Creatives[i]
+		if c.Linear != nil {
+			errs = validateTrackingEvents(errs, path+"."+creativePath(c.Id, i)+".Linear", c.Linear.TrackingEvents)
+		}
+	}
+	if !controls {
+		for _, attr := range []struct {
+			name string
+			set  bool
+		}{
+			{"followAdditionalWrappers", w.FollowAdditionalWrappers != nil},
+			{"allowMultipleAds", w.AllowMultipleAds != nil},
+			{"fallbackOnNoAd", w.FallbackOnNoAd != nil},
+		} {
+			if attr.set {
+				errs = append(errs, ValidationError{Path: path, Message: attr.name + " requires VAST 3.0 or later"})
+			}
+		}
+	}
+	return errs
+}
+
+// validate appends the problems found in the pricing to errs. It is safe to
+// call on a nil Pricing.
+func (p *Pricing) validate(errs []ValidationError, path string) []ValidationError {
+	if p == nil {
+		return errs
+	}
+	if !p.knownModel() {
+		errs = append(errs, ValidationError{Path: path, Message: "unknown pricing model " + strconv.Quote(p.Model)})
+	}
+	if _, err := p.ParseValue(); err != nil {
+		errs = append(errs, ValidationError{
+			Path:    path,
+			Message: "invalid pricing value " + strconv.Quote(p.Value),
+			Err:     err,
+		})
+	}
+	return errs
+}
+
+// validateTrackingEvents appends the linear tracking events with unknown
+// names, and the progress events without an offset, to errs.
+func validateTrackingEvents(errs []ValidationError, path string, events []TrackingEvent) []ValidationError {
+	for _, t := range events {
+		switch {
+		case !linearEvents[t.Event]:
+			errs = append(errs, ValidationError{
+				Path:    path,
+				Message: "unknown tracking event " + strconv.Quote(t.Event),
+			})
+		case t.Event == EventProgress && t.Offset == nil:
+			errs = append(errs, ValidationError{Path: path, Message: "progress tracking event without offset"})
+		}
+	}
+	return errs
+}
+
+// validateSkipOffset appends a skipoffset that lies beyond the end of the
+// linear creative to errs. A duration offset is compared with the Duration of
+// the creative, if it has one, and a percentage must not exceed 100%.
+func (l *Linear) validateSkipOffset(errs []ValidationError, path string) []ValidationError {
+	skip := l.SkipOffset
+	if skip == nil {
+		return errs
+	}
+	switch {
+	case skip.Duration != nil && l.Duration.Duration > 0 && skip.Duration.Duration > l.Duration.Duration:
+		skipText, _ := skip.MarshalText()
+		durationText, _ := l.Duration.MarshalText()
+		errs = append(errs, ValidationError{
+			Path:    path,
+			Message: "skipoffset " + string(skipText) + " is longer than the duration " + string(durationText),
+		})
+	case skip.kind() == offsetKindPercent && skip.Percent > 1:
+		errs = append(errs, ValidationError{Path: path, Message: "skipoffset is more than 100%"})
+	}
+	return errs
+}
+
+// onlyVideoMediaFiles reports whether the InLine has media files and all of
+// them have a video MIME type.
+func (il *InLine) onlyVideoMediaFiles() bool {
+	found := false
+	for _, c := range il.Creatives {
+		if c.Linear == nil {
+			continue
+		}
+		for _, mf := range c.Linear.MediaFiles {
+			if !strings.HasPrefix(strings.TrimSpace(mf.MediaType), "video/") {
+				return false
+			}
+			found = true
+		}
+	}
+	return found
+}
+
+// adBreakPath identifies an AdBreak by its breakId, or by its index if it has none.
+func adBreakPath(ab *AdBreak, i int) string {
+	if ab.Id != "" {
+		return "AdBreak[" + ab.Id + "]"
+	}
+	return "AdBreak[" + strconv.Itoa(i) + "]"
+}
+
+// creativePath identifies a Creative by its id, or by its index if it has none.
+func creativePath(id string, i int) string {
+	if id != "" {
+		return "Creative[" + id + "]"
+	}
+	return "Creative[" + strconv.Itoa(i) + "]"
+}
+
+// adPath identifies an Ad by its id, or by its index if it has none.
+func adPath(ad *Ad, i int) string {
+	if ad.Id != "" {
+		return "Ad[" + ad.Id + "]"
+	}
+	return "Ad[" + strconv.Itoa(i) + "]"
+}
+
+// ValidateAdSource checks that the AdSource of the break contains exactly one
+// of VASTAdData, AdTagURI and CustomAdData, and that VASTAdData holds a VAST
+// document. A break without an AdSource, such as a tracking only break, is valid.
+// The returned error is an *AdSourceError.
+func (ab *AdBreak) ValidateAdSource() error {
+	as := ab.AdSource
+	if as == nil {
+		return nil
+	}
+	var msg string
+	switch sources := as.sources(); {
+	case len(sources) == 0:
+		msg = "contains none of VASTAdData, AdTagURI, CustomAdData"
+	case len(sources) > 1:
+		msg = "contains more than one of " + strings.Join(sources, ", ")
+	case as.VASTData != nil && as.VASTData.VAST == nil:
+		msg = "VASTAdData contains no VAST document"
+	default:
+		return nil
+	}
+	return &AdSourceError{BreakId: ab.Id, Message: msg}
+}
+
+// sources lists the names of the ad source elements present in the AdSource.
+func (as *AdSource) sources() []string {
+	var names []string
+	if as.VASTData != nil {
+		names = append(names, "VASTAdData")
+	}
+	if as.AdTagURI != nil {
+		names = append(names, "AdTagURI")
+	}
+	if as.CustomAdData != nil {
+		names = append(names, "CustomAdData")
+	}
+	return names
+}
@@ -0,0 +1,333 @@
+package vmap
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestValidateAdSourceBothVASTDataAndAdTagURI(t *testing.T) {
+	is := is.New(t)
+	v := VMAP{AdBreaks: []AdBreak{
+		{Id: "preroll", AdSource: &AdSource{VASTData: &VASTData{VAST: &VAST{}}}},
+		{Id: "midroll-1", AdSource: &AdSource{
+			VASTData: &VASTData{VAST: &VAST{}},
+			AdTagURI: &AdTagURI{URI: "https://adserver.example.com/vast"},
+		}},
+	}}
+
+	errs := v.Validate()
+	is.Equal(len(errs), 1)
+	is.Equal(errs[0].Path, "AdBreak[midroll-1].AdSource")
+	is.Equal(errs[0].Error(), "AdBreak[midroll-1].AdSource: contains more than one of VASTAdData, AdTagURI")
+}
+
+func TestValidateBreakType(t *testing.T) {
+	is := is.New(t)
+	v := VMAP{AdBreaks: []AdBreak{
+		{Id: "preroll", BreakType: BreakTypeLinear},
+		{Id: "overlay", BreakType: BreakTypeNonLinear + "," + BreakTypeDisplay},
+		{Id: "untyped"},
+		{Id: "typo", BreakType: "liner"},
+	}}
+
+	errs := v.Validate()
+	is.Equal(len(errs), 1)
+	is.Equal(errs[0].Error(), `AdBreak[typo]: unknown breakType "liner"`)
+}
+
+func TestValidateAdSourceCustomAdData(t *testing.T) {
+	is := is.New(t)
+	v := VMAP{AdBreaks: []AdBreak{
+		{Id: "preroll", AdSource: &AdSource{CustomAdData: &CustomAdData{TemplateType: "proprietary"}}},
+		{AdSource: &AdSource{
+			AdTagURI:     &AdTagURI{URI: "https://adserver.example.com/vast"},
+			CustomAdData: &CustomAdData{TemplateType: "proprietary"},
+		}},
+	}}
+
+	errs := v.Validate()
+	is.Equal(len(errs), 1)
+	is.Equal(errs[0].Error(), "AdBreak[1].AdSource: contains more than one of AdTagURI, CustomAdData")
+}
+
+func TestValidateAdServingId(t *testing.T) {
+	is := is.New(t)
+	vast := VAST{Version: "4.1", Ad: []Ad{
+		{Id: "with-id", InLine: &InLine{AdServingId: "a532d16d-4d7f-4440-bd29-2ec0e693fc80"}},
+		{Id: "without-id", InLine: &InLine{}},
+		{InLine: &InLine{}},
+		{Id: "no-inline"},
+	}}
+
+	errs := vast.Validate()
+	is.Equal(len(errs), 2)
+	is.Equal(errs[0].Error(), "Ad[without-id].InLine: missing AdServingId, required since VAST 4.1")
+	is.Equal(errs[1].Path, "Ad[2].InLine")
+
+	for _, version := range []string{"2.0", "3.0", "4.0", "4", "", "bogus"} {
+		vast.Version = version
+		is.Equal(len(vast.Validate()), 0)
+	}
+	vast.Version = "5.0"
+	is.Equal(len(vast.Validate()), 2)
+
+	v := VMAP{AdBreaks: []AdBreak{
+		{Id: "preroll", AdSource: &AdSource{VASTData: &VASTData{VAST: &VAST{
+			Version: "4.2",
+			Ad:      []Ad{{Id: "1", InLine: &InLine{}}},
+		}}}},
+	}}
+	errs = v.Validate()
+	is.Equal(len(errs), 1)
+	is.Equal(errs[0].Path, "AdBreak[preroll].AdSource.VASTAdData.VAST.Ad[1].InLine")
+}
+
+func TestValidateAdSourcePresence(t *testing.T) {
+	is := is.New(t)
+	v := VMAP{AdBreaks: []AdBreak{
+		{Id: "tracking-only", TrackingEvents: []TrackingEvent{{Event: "breakStart", Text: "https://example.com/t"}}},
+		{Id: "preroll", AdSource: &AdSource{AdTagURI: &AdTagURI{URI: "https://adserver.example.com/vast"}}},
+		{Id: "empty", AdSource: &AdSource{}},
+		{Id: "both", AdSource: &AdSource{
+			AdTagURI:     &AdTagURI{URI: "https://adserver.example.com/vast"},
+			CustomAdData: &CustomAdData{TemplateType: "proprietary"},
+		}},
+		{Id: "no-vast", AdSource: &AdSource{VASTData: &VASTData{}}},
+	}}
+
+	is.NoErr(v.AdBreaks[0].ValidateAdSource())
+	is.NoErr(v.AdBreaks[1].ValidateAdSource())
+
+	errs := v.Validate()
+	is.Equal(len(errs), 3)
+	expected := []struct{ breakId, message string }{
+		{"empty", "contains none of VASTAdData, AdTagURI, CustomAdData"},
+		{"both", "contains more than one of AdTagURI, CustomAdData"},
+		{"no-vast", "VASTAdData contains no VAST document"},
+	}
+	for i, e := range expected {
+		var asErr *AdSourceError
+		is.True(errors.As(errs[i], &asErr))
+		is.Equal(asErr.BreakId, e.breakId)
+		is.Equal(asErr.Message, e.message)
+		is.Equal(errs[i].Path, "AdBreak["+e.breakId+"].AdSource")
+	}
+
+	err := v.AdBreaks[2].ValidateAdSource()
+	is.Equal(err.Error(), `invalid AdSource in AdBreak "empty": contains none of VASTAdData, AdTagURI, CustomAdData`)
+}
+
+func TestValidateWrapper(t *testing.T) {
+	is := is.New(t)
+	yes := true
+	vast := VAST{Version: "2.0", Ad: []Ad{
+		{Id: "ok", Wrapper: &Wrapper{VASTAdTagURI: "https://secondary.example.com/vast"}},
+		{Id: "no-uri", Wrapper: &Wrapper{VASTAdTagURI: "  "}},
+		{Id: "v3-attrs", Wrapper: &Wrapper{
+			VASTAdTagURI:             "https://secondary.example.com/vast",
+			FollowAdditionalWrappers: &yes,
+			FallbackOnNoAd:           &yes,
+		}},
+	}}
+
+	errs := vast.Validate()
+	is.Equal(len(errs), 3)
+	is.Equal(errs[0].Error(), "Ad[no-uri].Wrapper: missing VASTAdTagURI")
+	is.Equal(errs[1].Error(), "Ad[v3-attrs].Wrapper: followAdditionalWrappers requires VAST 3.0 or later")
+	is.Equal(errs[2].Error(), "Ad[v3-attrs].Wrapper: fallbackOnNoAd requires VAST 3.0 or later")
+
+	vast.Version = "3.0"
+	is.Equal(len(vast.Validate()), 1)
+	vast.Version = "" // unknown versions are not held against the attributes
+	is.Equal(len(vast.Validate()), 1)
+}
+
+func TestValidateAudioAdType(t *testing.T) {
+	is := is.New(t)
+	linear := func(types ...string) *InLine {
+		l := &Linear{}
+		for _, mt := range types {
+			l.MediaFiles = append(l.MediaFiles, MediaFile{MediaType: mt})
+		}
+		return &InLine{Creatives: []Creative{{Linear: l}}}
+	}
+	vast := VAST{Version: "3.0", Ad: []Ad{
+		{Id: "video-only", AdType: AdTypeAudio, InLine: linear("video/mp4", "video/webm")},
+		{Id: "with-audio", AdType: AdTypeAudio, InLine: linear("video/mp4", "audio/mp4")},
+		{Id: "no-media", AdType: AdTypeAudio, InLine: &InLine{}},
+		{Id: "video", AdType: AdTypeVideo, InLine: linear("video/mp4")},
+	}}
+
+	errs := vast.Validate()
+	is.Equal(len(errs), 1)
+	is.Equal(errs[0].Error(), "Ad[video-only]: adType is audio but all MediaFiles are video")
+	is.True(vast.Ad[3].IsVideo())
+	is.True((&Ad{}).IsVideo())
+	is.True(!(&Ad{AdType: AdTypeHybrid}).IsVideo())
+}
+
+func TestValidatePricing(t *testing.T) {
+	is := is.New(t)
+	uri := "https://secondary.example.com/vast"
+	vast := VAST{Version: "3.0", Ad: []Ad{
+		{Id: "ok", InLine: &InLine{Pricing: &Pricing{Model: "CPM", Currency: "USD", Value: "25.00"}}},
+		{Id: "bad-model", InLine: &InLine{Pricing: &Pricing{Model: "flat", Currency: "USD", Value: "1"}}},
+		{Id: "bad-value", Wrapper: &Wrapper{
+			VASTAdTagURI: uri,
+			Pricing:      &Pricing{Model: PricingModelCPV, Value: "free"},
+		}},
+		{Id: "no-pricing", InLine: &InLine{}},
+	}}
+
+	errs := vast.Validate()
+	is.Equal(len(errs), 2)
+	is.Equal(errs[0].Error(), `Ad[bad-model].InLine.Pricing: unknown pricing model "flat"`)
+	is.Equal(errs[1].Error(), `Ad[bad-value].Wrapper.Pricing: invalid pricing value "free"`)
+	is.True(errs[1].Err != nil)
+}
+
+func TestValidateSurvey(t *testing.T) {
+	is := is.New(t)
+	survey := &Survey{URI: "https://survey.example.com/lift"}
+	ad := Ad{Id: "surveyed", InLine: &InLine{AdServingId: "a1", Survey: survey}}
+
+	for _, version := range []string{"3.0", "4.2"} {
+		is.Equal(len((&VAST{Version: version, Ad: []Ad{ad}}).Validate()), 0)
+	}
+
+	errs := (&VAST{Version: "4.3", Ad: []Ad{ad}}).Validate()
+	is.Equal(len(errs), 1)
+	is.Equal(errs[0].Error(), "Ad[surveyed].InLine.Survey: Survey was removed in VAST 4.3")
+}
+
+func TestValidateCategoryAuthority(t *testing.T) {
+	is := is.New(t)
+	ad := Ad{Id: "cat", InLine: &InLine{AdServingId: "a1", Categories: []Category{
+		{Authority: "https://www.iab.com/categories", Value: "IAB1-5"},
+		{Value: "IAB19"},
+		{},
+	}}}
+
+	is.Equal(len((&VAST{Version: "4.0", Ad: []Ad{ad}}).Validate()), 0)
+
+	errs := (&VAST{Version: "4.1", Ad: []Ad{ad}}).Validate()
+	is.Equal(len(errs), 1)
+	is.Equal(errs[0].Error(), `Ad[cat].InLine.Category[1]: category "IAB19" has no authority, required since VAST 4.1`)
+}
+
+func TestValidateTrackingEvents(t *testing.T) {
+	is := is.New(t)
+	five := TimeOffset{Duration: &Duration{5 * time.Second}}
+	events := []TrackingEvent{
+		{Event: EventStart},
+		{Event: "complet"},
+		{Event: EventProgress, Offset: &five},
+		{Event: EventProgress},
+		{Event: EventBreakStart},
+	}
+	vast := VAST{Version: "3.0", Ad: []Ad{
+		{Id: "inline", InLine: &InLine{Creatives: []Creative{{Linear: &Linear{TrackingEvents: events}}}}},
+		{Id: "wrapper", Wrapper: &Wrapper{
+			VASTAdTagURI: "https://secondary.example.com/vast",
+			Creatives:    []WrapperCreative{{Id: "wc", Linear: &WrapperLinear{TrackingEvents: events[:2]}}},
+		}},
+	}}
+
+	errs := vast.Validate()
+	is.Equal(len(errs), 4)
+	is.Equal(errs[0].Error(), `Ad[inline].InLine.Creative[0].Linear: unknown tracking event "complet"`)
+	is.Equal(errs[1].Error(), "Ad[inline].InLine.Creative[0].Linear: progress tracking event without offset")
+	is.Equal(errs[2].Error(), `Ad[inline].InLine.Creative[0].Linear: unknown tracking event "breakStart"`)
+	is.Equal(errs[3].Error(), `Ad[wrapper].Wrapper.Creative[wc].Linear: unknown tracking event "complet"`)
+
+	is.True(TrackingEvent{Event: EventBreakStart}.KnownEvent())
+	is.True(TrackingEvent{Event: EventThirdQuartile}.KnownEvent())
+	is.True(!TrackingEvent{Event: "Complete"}.KnownEvent())
+}
+
+func TestValidateMediaFileDelivery(t *testing.T) {
+	is := is.New(t)
+	linear := &Linear{MediaFiles: []MediaFile{
+		{Delivery: DeliveryProgressive, MediaType: "video/mp4"},
+		{Delivery: DeliveryStreaming, MediaType: "application/x-mpegURL"},
+		{MediaType: "video/mp4"},
+		{Delivery: "Progressive", MediaType: "video/mp4"},
+	}}
+	vast := VAST{Version: "3.0", Ad: []Ad{
+		{Id: "ad", InLine: &InLine{Creatives: []Creative{{Id: "c", Linear: linear}}}},
+	}}
+
+	errs := vast.Validate()
+	is.Equal(len(errs), 1)
+	is.Equal(errs[0].Error(), `Ad[ad].InLine.Creative[c].Linear.MediaFile[3]: unknown delivery "Progressive"`)
+
+	linear.Mezzanine = []Mezzanine{
+		{Delivery: DeliveryProgressive, MediaType: "video/mp4"},
+		{Delivery: "download", MediaType: "video/mp4"},
+	}
+	errs = vast.Validate()
+	is.Equal(len(errs), 2)
+	is.Equal(errs[1].Error(), `Ad[ad].InLine.Creative[c].Linear.Mezzanine[1]: unknown delivery "download"`)
+
+	is.True(DeliveryStreaming.Valid())
+	is.True(!Delivery("").Valid())
+}
+
+func TestValidateServerSideMezzanine(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVast4MediaFiles.xml")
+	is.NoErr(err)
+	vast, err := DecodeVastScan(doc)
+	is.NoErr(err)
+	serverSide := ValidateOptions{ServerSide: true}
+	is.Equal(vast.ValidateWithOptions(serverSide), nil)
+
+	vast.Ad[0].InLine.Creatives[0].Linear.Mezzanine = nil
+	is.Equal(vast.Validate(), nil)
+	errs := vast.ValidateWithOptions(serverSide)
+	is.Equal(len(errs), 1)
+	is.True(strings.HasSuffix(errs[0].Path, ".Linear"))
+	is.Equal(errs[0].Message, "missing Mezzanine, required for server-side ad insertion")
+
+	// Mezzanine is new in VAST 4
+	vast.Version = "3.0"
+	is.Equal(vast.ValidateWithOptions(serverSide), nil)
+
+	vast.Version = "4.1"
+	v := &VMAP{AdBreaks: []AdBreak{{Id: "pre", AdSource: &AdSource{VASTData: &VASTData{VAST: &vast}}}}}
+	is.Equal(v.Validate(), nil)
+	errs = v.ValidateWithOptions(serverSide)
+	is.Equal(len(errs), 1)
+	is.True(strings.HasPrefix(errs[0].Path, "AdBreak[pre].AdSource.VASTAdData.VAST.Ad["))
+}
+
+func TestValidateSkipOffset(t *testing.T) {
+	is := is.New(t)
+	offset := func(text string) *TimeOffset {
+		var to TimeOffset
+		is.NoErr(to.UnmarshalText([]byte(text)))
+		return &to
+	}
+	fifteen := Duration{15 * time.Second}
+	vast := VAST{Version: "3.0", Ad: []Ad{{Id: "ad", InLine: &InLine{Creatives: []Creative{
+		{Id: "skip5", Linear: &Linear{Duration: fifteen, SkipOffset: offset("00:00:05")}},
+		{Id: "skip-end", Linear: &Linear{Duration: fifteen, SkipOffset: offset("00:00:15")}},
+		{Id: "skip20", Linear: &Linear{Duration: fifteen, SkipOffset: offset("00:00:20")}},
+		{Id: "no-duration", Linear: &Linear{SkipOffset: offset("00:00:20")}},
+		{Id: "skip10pct", Linear: &Linear{Duration: fifteen, SkipOffset: offset("10%")}},
+		{Id: "skip150pct", Linear: &Linear{Duration: fifteen, SkipOffset: offset("150%")}},
+		{Id: "unskippable", Linear: &Linear{Duration: fifteen}},
+	}}}}}
+
+	errs := vast.Validate()
+	is.Equal(len(errs), 2)
+	is.Equal(errs[0].Error(),
+		"Ad[ad].InLine.Creative[skip20].Linear: skipoffset 00:00:20 is longer than the duration 00:00:15")
+	is.Equal(errs[1].Error(), "Ad[ad].InLine.Creative[skip150pct].Linear: skipoffset is more than 100%")
+
+}
@@ -0,0 +1,118 @@
+package vmap
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Feature is a part of the VAST spec that is only available from a certain
+// version on.
+type Feature int
+
+const (
+	// FeatureUniversalAdId is the UniversalAdId element of creatives, VAST 3.0.
+	FeatureUniversalAdId Feature = iota
+	// FeatureWrapperControls are the followAdditionalWrappers, allowMultipleAds
+	// and fallbackOnNoAd attributes of Wrapper, VAST 3.0.
+	FeatureWrapperControls
+	// FeatureMezzanine is the Mezzanine element of linear creatives, VAST 4.0.
+	FeatureMezzanine
+	// FeatureAdVerifications is the AdVerifications element, VAST 4.0.
+	FeatureAdVerifications
+	// FeatureAdServingId is the AdServingId element of InLine, VAST 4.1.
+	FeatureAdServingId
+	// FeatureCategoryAuthority is the authority attribute of Category, required
+	// from VAST 4.1.
+	FeatureCategoryAuthority
+)
+
+// featureVersions holds the first VAST version, as major and minor, with
+// each feature.
+var featureVersions = map[Feature][2]int{
+	FeatureUniversalAdId:     {3, 0},
+	FeatureWrapperControls:   {3, 0},
+	FeatureMezzanine:         {4, 0},
+	FeatureAdVerifications:   {4, 0},
+	FeatureAdServingId:       {4, 1},
+	FeatureCategoryAuthority: {4, 1},
+}
+
+// MajorVersion returns the major part of the version attribute, e.g. 4 for "4.1".
+func (v *VAST) MajorVersion() (int, error) {
+	major, _, _ := strings.Cut(strings.TrimSpace(v.Version), ".")
+	n, err := strconv.Atoi(major)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid VAST version %q", v.Version)
+	}
+	return n, nil
+}
+
+// VASTVersion is a value of the version attribute of VAST.
+type VASTVersion string
+
+// Versions of VAST, e.g. for VAST.AtLeast.
+const (
+	VASTVersion2  VASTVersion = "2.0"
+	VASTVersion3  VASTVersion = "3.0"
+	VASTVersion4  VASTVersion = "4.0"
+	VASTVersion41 VASTVersion = "4.1"
+	VASTVersion42 VASTVersion = "4.2"
+	VASTVersion43 VASTVersion = "4.3"
+)
+
+// parse returns the major and minor parts of the version. A version without
+// a minor part, such as "4", has minor 0, and a patch part, as in "4.1.0",
+// is ignored.
+func (ver VASTVersion) parse() (major, minor int, err error) {
+	parts := strings.Split(strings.TrimSpace(string(ver)), ".")
+	if len(parts) > 3 {
+		return 0, 0, fmt.Errorf("invalid VAST version %q", string(ver))
+	}
+	nums := make([]int, len(parts))
+	for i, p := range parts {
+		if nums[i], err = strconv.Atoi(p); err != nil || nums[i] < 0 {
+			return 0, 0, fmt.Errorf("invalid VAST version %q", string(ver))
+		}
+	}
+	if nums[0] < 1 {
+		return 0, 0, fmt.Errorf("invalid VAST version %q", string(ver))
+	}
+	if len(nums) > 1 {
+		minor = nums[1]
+	}
+	return nums[0], minor, nil
+}
+
+// ParsedVersion returns the major and minor parts of the version attribute,
+// e.g. 4 and 1 for "4.1". Unlike MajorVersion it fails unless the whole
+// attribute is a version number.
+func (v *VAST) ParsedVersion() (major, minor int, err error) {
+	return VASTVersion(v.Version).parse()
+}
+
+// AtLeast reports whether the version of the document is ver or later. It
+// returns false if either version cannot be parsed.
+func (v *VAST) AtLeast(ver VASTVersion) bool {
+	major, minor, err := v.ParsedVersion()
+	if err != nil {
+		return false
+	}
+	wantMajor, wantMinor, err := ver.parse()
+	return err == nil && versionAtLeast(major, minor, wantMajor, wantMinor)
+}
+
+func versionAtLeast(major, minor, wantMajor, wantMinor int) bool {
+	return major > wantMajor || major == wantMajor && minor >= wantMinor
+}
+
+// SupportsFeature reports whether the version of the document includes f.
+// It returns false if the version cannot be parsed.
+func (v *VAST) SupportsFeature(f Feature) bool {
+	major, minor, err := v.ParsedVersion()
+	if err != nil {
+		return false
+	}
+	since, ok := featureVersions[f]
+	return ok && versionAtLeast(major, minor, since[0], since[1])
+}
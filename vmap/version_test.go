@@ -0,0 +1,63 @@
+package vmap
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestVASTMajorVersion(t *testing.T) {
+	is := is.New(t)
+	for version, want := range map[string]int{"2.0": 2, "3.0": 3, "4.2": 4, " 4 ": 4} {
+		got, err := (&VAST{Version: version}).MajorVersion()
+		is.NoErr(err)
+		is.Equal(got, want)
+	}
+	for _, version := range []string{"", "v4", "0.9"} {
+		_, err := (&VAST{Version: version}).MajorVersion()
+		is.True(err != nil)
+	}
+}
+
+func TestVASTSupportsFeature(t *testing.T) {
+	is := is.New(t)
+	v2, v3, v4 := &VAST{Version: "2.0"}, &VAST{Version: "3.0"}, &VAST{Version: "4.2"}
+
+	is.True(!v2.SupportsFeature(FeatureUniversalAdId))
+	is.True(v3.SupportsFeature(FeatureUniversalAdId))
+	is.True(!v3.SupportsFeature(FeatureMezzanine))
+	is.True(v4.SupportsFeature(FeatureMezzanine))
+	is.True(v4.SupportsFeature(FeatureAdVerifications))
+	is.True(v4.SupportsFeature(FeatureAdServingId))
+	is.True((&VAST{Version: "4.1"}).SupportsFeature(FeatureAdServingId))
+	is.True(!(&VAST{Version: "4.0"}).SupportsFeature(FeatureAdServingId))
+	is.True(!(&VAST{Version: "4"}).SupportsFeature(FeatureAdServingId))
+	is.True((&VAST{Version: "4.1"}).SupportsFeature(FeatureCategoryAuthority))
+	is.True(!(&VAST{}).SupportsFeature(FeatureUniversalAdId))
+	is.True(!v4.SupportsFeature(Feature(-1)))
+}
+
+func TestVASTParsedVersion(t *testing.T) {
+	is := is.New(t)
+	for version, want := range map[string][2]int{
+		"2.0": {2, 0}, "3.0": {3, 0}, "4.1": {4, 1}, " 4.2 ": {4, 2}, "4": {4, 0}, "4.1.0": {4, 1},
+		string(VASTVersion43): {4, 3},
+	} {
+		major, minor, err := (&VAST{Version: version}).ParsedVersion()
+		is.NoErr(err)
+		is.Equal([2]int{major, minor}, want)
+	}
+	for _, version := range []string{"", "v4", "0.9", "4.x", "4.", "4.-1", "4.1.0.0"} {
+		_, _, err := (&VAST{Version: version}).ParsedVersion()
+		is.True(err != nil)
+	}
+
+	v41 := &VAST{Version: "4.1"}
+	is.True(v41.AtLeast(VASTVersion2))
+	is.True(v41.AtLeast(VASTVersion4))
+	is.True(v41.AtLeast(VASTVersion41))
+	is.True(!v41.AtLeast(VASTVersion42))
+	is.True((&VAST{Version: "5"}).AtLeast(VASTVersion43))
+	is.True(!(&VAST{Version: "4.x"}).AtLeast(VASTVersion2))
+	is.True(!v41.AtLeast("latest"))
+}
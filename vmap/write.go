@@ -0,0 +1,26 @@
+package vmap
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// WriteXML encodes the VMAP as XML directly onto w, without buffering the
+// whole document. The output is the same as that of xml.Marshal.
+func (v *VMAP) WriteXML(w io.Writer) error {
+	if err := xml.NewEncoder(w).Encode(v); err != nil {
+		return fmt.Errorf("error writing VMAP XML: %w", err)
+	}
+	return nil
+}
+
+// WriteJSON encodes the VMAP as JSON directly onto w, followed by a newline
+// as written by json.Encoder.
+func (v *VMAP) WriteJSON(w io.Writer) error {
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		return fmt.Errorf("error writing VMAP JSON: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package vmap
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestVMAPWriteXML(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVmap.xml")
+	is.NoErr(err)
+	var v VMAP
+	is.NoErr(xml.Unmarshal(doc, &v))
+
+	expected, err := xml.Marshal(v)
+	is.NoErr(err)
+	var buf bytes.Buffer
+	is.NoErr(v.WriteXML(&buf))
+	is.Equal(buf.String(), string(expected))
+
+	is.True(v.WriteXML(failingWriter{}) != nil)
+}
+
+func TestVMAPWriteJSON(t *testing.T) {
+	is := is.New(t)
+	doc, err := os.ReadFile("sample-vmap/testVmap.xml")
+	is.NoErr(err)
+	var v VMAP
+	is.NoErr(xml.Unmarshal(doc, &v))
+
+	expected, err := json.Marshal(v)
+	is.NoErr(err)
+	var buf bytes.Buffer
+	is.NoErr(v.WriteJSON(&buf))
+	is.Equal(buf.String(), string(expected)+"\n")
+
+	is.True(v.WriteJSON(failingWriter{}) != nil)
+}